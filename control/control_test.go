@@ -0,0 +1,159 @@
+package control
+
+import (
+	"einclient/engine"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testScene = `version: 1
+frame:
+  width: 8
+  height: 8
+env:
+  size: 2
+  mood: calm
+animations:
+  - name: grow
+    autoplay: false
+    keyframes:
+      - time: 0
+        properties:
+          size: 2
+      - time: 1
+        properties:
+          size: 4
+stateMachine:
+  initial: idle
+  states:
+    idle: {}
+    listening: {}
+`
+
+// fakePlayer runs commands right away instead of between frames.
+type fakePlayer struct {
+	scene  *engine.Scene
+	played string
+}
+
+func (p *fakePlayer) Do(fn func(scene *engine.Scene) error) error {
+	if p.scene == nil {
+		return errors.New("not playing a scene")
+	}
+	return fn(p.scene)
+}
+
+func (p *fakePlayer) Play(path string) error {
+	if !strings.HasSuffix(path, ".yml") {
+		return errors.New("not a scene")
+	}
+	p.played = path
+	return nil
+}
+
+func newTestServer(t *testing.T) (*Server, *fakePlayer) {
+	scene, err := engine.ParseScene([]byte(testScene))
+	if err != nil {
+		t.Fatalf("Failed to parse scene: %v", err)
+	}
+	player := &fakePlayer{scene: scene}
+	return NewServer(player, "scenes"), player
+}
+
+func request(s *Server, method, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return w
+}
+
+func TestEnv(t *testing.T) {
+	s, player := newTestServer(t)
+	w := request(s, http.MethodPatch, "/env", `{"size": 3, "mood": "happy"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if player.scene.Env["size"] != 3 || player.scene.Env["mood"] != "happy" {
+		t.Errorf("Expected the env to be patched, got %v", player.scene.Env)
+	}
+
+	var env map[string]interface{}
+	w = request(s, http.MethodGet, "/env", "")
+	if err := json.Unmarshal(w.Body.Bytes(), &env); err != nil {
+		t.Fatalf("Failed to decode env: %v", err)
+	}
+	expected := map[string]interface{}{"size": 3.0, "mood": "happy", "state": "idle"}
+	if !reflect.DeepEqual(env, expected) {
+		t.Errorf("Expected %v, got %v", expected, env)
+	}
+
+	w = request(s, http.MethodPatch, "/env", `{"colour": "#ff0000"}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `\"colour\" is not defined`) {
+		t.Errorf("Expected a bad request for an undefined variable, got %d: %s", w.Code, w.Body)
+	}
+	w = request(s, http.MethodPatch, "/env", `{"size": "big"}`)
+	if w.Code != http.StatusBadRequest || player.scene.Env["size"] != 3 {
+		t.Errorf("Expected a bad request for a string size, got %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestAnimationsAndState(t *testing.T) {
+	s, player := newTestServer(t)
+	if w := request(s, http.MethodPost, "/animations/grow/start", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204, got %d: %s", w.Code, w.Body)
+	}
+	if w := request(s, http.MethodPut, "/state", `{"state": "listening"}`); w.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204, got %d: %s", w.Code, w.Body)
+	}
+	player.scene.ComputeAnimations(time.Unix(0, 0))
+
+	var status Status
+	w := request(s, http.MethodGet, "/status", "")
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatalf("Failed to decode status: %v", err)
+	}
+	expected := Status{Width: 8, Height: 8, State: "listening", Playing: []string{"grow"}}
+	if !reflect.DeepEqual(status, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, status)
+	}
+
+	for path, code := range map[string]int{
+		"/animations/shrink/start": http.StatusBadRequest,
+		"/animations/grow/pause":   http.StatusNotFound,
+	} {
+		if w := request(s, http.MethodPost, path, ""); w.Code != code {
+			t.Errorf("%s: Expected %d, got %d", path, code, w.Code)
+		}
+	}
+	if w := request(s, http.MethodGet, "/animations/grow/stop", ""); w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != http.MethodPost {
+		t.Errorf("Expected GET to be refused, got %d", w.Code)
+	}
+}
+
+func TestScene(t *testing.T) {
+	s, player := newTestServer(t)
+	if w := request(s, http.MethodPost, "/scene", `{"name": "ein"}`); w.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204, got %d: %s", w.Code, w.Body)
+	}
+	if player.played != "scenes/ein.yml" {
+		t.Errorf("Expected scenes/ein.yml to play, got %q", player.played)
+	}
+	for _, body := range []string{`{"path": "scenes/ein.yml"}`, `{"name": "../secrets"}`, `{"name": "/etc/passwd"}`, `{"name": ".hidden"}`} {
+		if w := request(s, http.MethodPost, "/scene", body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: Expected a bad request, got %d", body, w.Code)
+		}
+	}
+	if player.played != "scenes/ein.yml" {
+		t.Errorf("Expected no other scene to play, got %q", player.played)
+	}
+
+	player.scene = nil
+	if w := request(s, http.MethodGet, "/status", ""); w.Code != http.StatusConflict {
+		t.Errorf("Expected a conflict without a scene, got %d", w.Code)
+	}
+}
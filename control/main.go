@@ -0,0 +1,221 @@
+package control
+
+import (
+	"einclient/engine"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// Player plays scenes, loop.Loop is the one driving the matrix.
+type Player interface {
+	// Do runs fn against the playing scene between two frames.
+	Do(fn func(scene *engine.Scene) error) error
+	// Play swaps the playing scene for the scene file at path.
+	Play(path string) error
+}
+
+// Status is what GET /status answers.
+type Status struct {
+	Scene   string   `json:"scene"`
+	Width   int      `json:"width"`
+	Height  int      `json:"height"`
+	State   string   `json:"state,omitempty"`
+	Playing []string `json:"playing"`
+}
+
+// Server is the HTTP control API of a player:
+//
+//	GET   /status                    the playing scene, see Status
+//	GET   /env                       the scene env
+//	PATCH /env                       sets the env variables of a JSON object
+//	POST  /scene                     plays {"name": "..."} from the scenes directory
+//	PUT   /state                     switches the state machine to {"state": "..."}
+//	POST  /animations/<name>/start   starts an animation
+//	POST  /animations/<name>/stop    stops an animation
+//
+// Errors are answered as {"error": "..."}.
+type Server struct {
+	player Player
+	scenes string
+	mux    *http.ServeMux
+}
+
+// NewServer serves the API of player, playing scenes from the scenes
+// directory.
+func NewServer(player Player, scenes string) *Server {
+	s := &Server{player: player, scenes: scenes, mux: http.NewServeMux()}
+	s.mux.HandleFunc("/status", s.status)
+	s.mux.HandleFunc("/env", s.env)
+	s.mux.HandleFunc("/scene", s.scene)
+	s.mux.HandleFunc("/state", s.state)
+	s.mux.HandleFunc("/animations/", s.animation)
+	return s
+}
+
+// ListenAndServe serves the API of player on addr.
+func ListenAndServe(addr string, player Player, scenes string) error {
+	fmt.Printf("Serving the control API on %s\n", addr)
+	return http.ListenAndServe(addr, NewServer(player, scenes))
+}
+
+// ScenePath returns the file of the scene called name in the scenes
+// directory, names can't reach outside of it.
+func ScenePath(scenes, name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid scene name %q", name)
+	}
+	return filepath.Join(scenes, name+".yml"), nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) status(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodGet) {
+		return
+	}
+	var status Status
+	err := s.player.Do(func(scene *engine.Scene) error {
+		if files := scene.Files(); len(files) > 0 {
+			status.Scene = files[0]
+		}
+		status.Width, status.Height = scene.Frame.Width, scene.Frame.Height
+		status.State = scene.State()
+		status.Playing = scene.Playing()
+		return nil
+	})
+	if err != nil {
+		// Not playing a scene is the only way for it to fail.
+		replyError(w, http.StatusConflict, err)
+		return
+	}
+	reply(w, status)
+}
+
+func (s *Server) env(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodGet, http.MethodPatch) {
+		return
+	}
+	var values map[string]interface{}
+	if r.Method == http.MethodPatch && !decode(w, r, &values) {
+		return
+	}
+	env := make(map[string]interface{})
+	err := s.player.Do(func(scene *engine.Scene) error {
+		if err := scene.SetEnv(values); err != nil {
+			return err
+		}
+		for key, value := range scene.Env {
+			env[key] = value
+		}
+		return nil
+	})
+	if err != nil {
+		replyError(w, http.StatusBadRequest, err)
+		return
+	}
+	reply(w, env)
+}
+
+func (s *Server) scene(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodPost) {
+		return
+	}
+	var body struct {
+		Name string `json:"name"`
+	}
+	if !decode(w, r, &body) {
+		return
+	}
+	if body.Name == "" {
+		replyError(w, http.StatusBadRequest, errors.New("missing name"))
+		return
+	}
+	path, err := ScenePath(s.scenes, body.Name)
+	if err != nil {
+		replyError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := s.player.Play(path); err != nil {
+		replyError(w, http.StatusBadRequest, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) state(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodPut) {
+		return
+	}
+	var body struct {
+		State string `json:"state"`
+	}
+	if !decode(w, r, &body) {
+		return
+	}
+	done(w, s.player.Do(func(scene *engine.Scene) error {
+		return scene.SetState(body.State)
+	}))
+}
+
+func (s *Server) animation(w http.ResponseWriter, r *http.Request) {
+	name, action, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/animations/"), "/")
+	if !ok || name == "" || (action != "start" && action != "stop") {
+		http.NotFound(w, r)
+		return
+	}
+	if !allow(w, r, http.MethodPost) {
+		return
+	}
+	done(w, s.player.Do(func(scene *engine.Scene) error {
+		if action == "start" {
+			return scene.Start(name)
+		}
+		return scene.Stop(name)
+	}))
+}
+
+// allow answers 405 unless the request uses one of methods.
+func allow(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, method := range methods {
+		if r.Method == method {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	replyError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+	return false
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		replyError(w, http.StatusBadRequest, fmt.Errorf("invalid JSON body: %w", err))
+		return false
+	}
+	return true
+}
+
+func reply(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// done answers 204, or the error of a command as a bad request.
+func done(w http.ResponseWriter, err error) {
+	if err != nil {
+		replyError(w, http.StatusBadRequest, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func replyError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
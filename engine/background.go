@@ -0,0 +1,131 @@
+package engine
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"time"
+
+	"github.com/fogleman/gg"
+)
+
+const (
+	ClearModeClear   = "clear"
+	ClearModePersist = "persist"
+	ClearModeFade    = "fade"
+
+	// DefaultDecay is how much of a fading frame is left after a second.
+	DefaultDecay = 0.1
+)
+
+// backgroundObject is the object painting Frame.Background, a rectangle
+// filled with a color or gradient covering the frame, or an image stretched
+// over it when the background is a mapping with src. It is nil without a
+// background.
+func (scene *Scene) backgroundObject() *ObjectWrapper {
+	if scene.Frame.Background == nil {
+		return nil
+	}
+	if scene.background != nil {
+		return scene.background
+	}
+
+	properties := map[string]interface{}{
+		"x":      0,
+		"y":      0,
+		"width":  scene.Frame.Width,
+		"height": scene.Frame.Height,
+	}
+	wrapper := &ObjectWrapper{Name: "background", Type: ObjectTypeRectangle, Properties: properties}
+	if mapping, ok := scene.Frame.Background.(map[string]interface{}); ok && mapping["src"] != nil {
+		wrapper.Type = ObjectTypeImage
+		for key, value := range mapping {
+			properties[key] = value
+		}
+	} else {
+		properties["fill"] = scene.Frame.Background
+	}
+	scene.background = wrapper
+	return wrapper
+}
+
+// clear prepares ctx for the frame at now according to the frame's clear
+// mode. The first frame is always cleared to the background.
+func (scene *Scene) clear(ctx *gg.Context, now time.Time) error {
+	first := !scene.drawn
+	dt := now.Sub(scene.lastFrame)
+	scene.drawn, scene.lastFrame = true, now
+
+	switch scene.Frame.Clear {
+	case ClearModePersist:
+		if !first {
+			return nil
+		}
+	case ClearModeFade:
+		if !first {
+			decay, err := scene.decay()
+			if err != nil {
+				return err
+			}
+			if scene.layer == nil || scene.layer.Width() != ctx.Width() || scene.layer.Height() != ctx.Height() {
+				scene.layer = gg.NewContext(ctx.Width(), ctx.Height())
+			}
+			if err := scene.paintBackground(scene.layer); err != nil {
+				return err
+			}
+			fadeTowards(ctx.Image().(*image.RGBA), scene.layer.Image().(*image.RGBA), math.Pow(decay, dt.Seconds()))
+			return nil
+		}
+	}
+	return scene.paintBackground(ctx)
+}
+
+// paintBackground clears ctx to black and paints the background over it.
+func (scene *Scene) paintBackground(ctx *gg.Context) error {
+	ctx.SetColor(color.Black)
+	ctx.Clear()
+	if wrapper := scene.backgroundObject(); wrapper != nil {
+		if err := wrapper.Render(ctx, scene); err != nil {
+			return fmt.Errorf("background: %w", err)
+		}
+	}
+	return nil
+}
+
+// decay evaluates Frame.Decay, clamped between 0 and 1.
+func (scene *Scene) decay() (float64, error) {
+	if scene.Frame.Decay == "" {
+		return DefaultDecay, nil
+	}
+	value, err := scene.evaluate(scene.Frame.Decay)
+	if err != nil {
+		return 0, fmt.Errorf("decay: %w", err)
+	}
+	decay, ok := toFloat(value)
+	if !ok {
+		return 0, fmt.Errorf("decay: expected a number, got %v", value)
+	}
+	return math.Min(math.Max(decay, 0), 1), nil
+}
+
+// fadeTowards moves every pixel of dst towards bg, keeping keep of the
+// difference. Channels always move by at least one step so trails fade out
+// completely instead of stalling on rounding.
+func fadeTowards(dst, bg *image.RGBA, keep float64) {
+	for i := range dst.Pix {
+		from, to := int(dst.Pix[i]), int(bg.Pix[i])
+		if from == to {
+			continue
+		}
+		next := to + int(math.Round(float64(from-to)*keep))
+		if next == from {
+			if from > to {
+				next--
+			} else {
+				next++
+			}
+		}
+		dst.Pix[i] = uint8(next)
+	}
+}
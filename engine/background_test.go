@@ -0,0 +1,91 @@
+package engine
+
+import (
+	"fmt"
+	"image"
+	"testing"
+	"time"
+
+	"github.com/fogleman/gg"
+)
+
+const clearModeScene = `version: 1
+frame:
+  width: 8
+  height: 8
+  background: "#204060"
+  clear: %s
+  decay: 0.25
+env:
+  x: 0
+objects:
+  - name: dot
+    type: rectangle
+    properties:
+      x: x
+      y: 0
+      width: 1
+      height: 1
+      color: "#ffffff"
+animations:
+  - name: move
+    duration: "1"
+    keyframes:
+      - time: 0
+        properties:
+          x: 0
+      - time: 1
+        properties:
+          x: 7
+`
+
+func TestClearModes(t *testing.T) {
+	tests := []struct {
+		mode     string
+		expected uint8
+	}{
+		{ClearModeClear, 0x20},
+		{ClearModePersist, 0xff},
+		// A second of 0.25 decay keeps a quarter of the 0xdf above 0x20,
+		// rounded.
+		{ClearModeFade, 0x20 + 56},
+	}
+	// The render subcommand starts its clock at the zero time.
+	for _, start := range []time.Time{time.Unix(0, 0), {}} {
+		for _, test := range tests {
+			scene, err := ParseScene([]byte(fmt.Sprintf(clearModeScene, test.mode)))
+			if err != nil {
+				t.Fatalf("Failed to parse scene: %v", err)
+			}
+			ctx := gg.NewContext(8, 8)
+			clock := NewManualClock(start)
+			scene.Render(ctx, clock)
+			clock.Set(start.Add(time.Second))
+			scene.Render(ctx, clock)
+
+			img := ctx.Image().(*image.RGBA)
+			if got := img.RGBAAt(0, 0).R; got != test.expected {
+				t.Errorf("%s from %v: Expected red %#x where the dot was, got %#x", test.mode, start, test.expected, got)
+			}
+			if got := img.RGBAAt(7, 0).R; got != 0xff {
+				t.Errorf("%s from %v: Expected the dot at its new position, got red %#x", test.mode, start, got)
+			}
+			if got := img.RGBAAt(3, 5); got.R != 0x20 || got.G != 0x40 || got.B != 0x60 {
+				t.Errorf("%s from %v: Expected the background, got %v", test.mode, start, got)
+			}
+		}
+	}
+}
+
+func TestFadeTowards(t *testing.T) {
+	dst := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	bg := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	dst.Pix[0] = 3
+	bg.Pix[0] = 0
+	for i := 0; i < 3; i++ {
+		fadeTowards(dst, bg, 0.9)
+	}
+	if dst.Pix[0] != 0 {
+		t.Errorf("Expected the trail to fade out completely, got %d", dst.Pix[0])
+	}
+}
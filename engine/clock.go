@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells a Scene what time it is when a frame is rendered. Animations
+// only ever read time through it, so a scene can be rendered at any timestamp.
+type Clock interface {
+	Now() time.Time
+}
+
+// RealClock is the wall clock, used when driving the matrix.
+type RealClock struct{}
+
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+// FixedStepClock starts at Start and moves forward by Step every time Now is
+// called, so consecutive frames are exactly Step apart no matter how long
+// rendering them takes.
+type FixedStepClock struct {
+	mu      sync.Mutex
+	current time.Time
+	step    time.Duration
+}
+
+func NewFixedStepClock(start time.Time, step time.Duration) *FixedStepClock {
+	return &FixedStepClock{current: start, step: step}
+}
+
+func (c *FixedStepClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.current
+	c.current = c.current.Add(c.step)
+	return now
+}
+
+// ManualClock only moves when told to, for rendering at arbitrary timestamps.
+type ManualClock struct {
+	mu      sync.Mutex
+	current time.Time
+}
+
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{current: start}
+}
+
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.current
+}
+
+// Set moves the clock to t, which may be in the past.
+func (c *ManualClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.current = t
+}
+
+// Advance moves the clock forward by d.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.current = c.current.Add(d)
+}
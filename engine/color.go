@@ -0,0 +1,250 @@
+package engine
+
+import (
+	"einclient/engine/objects"
+	"fmt"
+	"image/color"
+	"math"
+	"strings"
+)
+
+const (
+	ColorSpaceRGB       = "rgb"
+	ColorSpaceLinearRGB = "linear-rgb"
+	ColorSpaceHSL       = "hsl"
+	ColorSpaceOKLab     = "oklab"
+)
+
+// ColorInterpolator blends two colors, t is the (possibly eased) progress
+// between them.
+type ColorInterpolator func(from, to color.NRGBA, t float64) color.NRGBA
+
+var colorSpaces = map[string]ColorInterpolator{
+	ColorSpaceRGB:       interpolateRGB,
+	ColorSpaceLinearRGB: interpolateLinearRGB,
+	ColorSpaceHSL:       interpolateHSL,
+	ColorSpaceOKLab:     interpolateOKLab,
+}
+
+// ParseColorSpace resolves the interpolator for an animation colorSpace, an
+// empty name interpolates in sRGB.
+func ParseColorSpace(name string) (ColorInterpolator, error) {
+	if name == "" {
+		return colorSpaces[ColorSpaceRGB], nil
+	}
+	interpolator, ok := colorSpaces[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown color space: %s", name)
+	}
+	return interpolator, nil
+}
+
+// ParseHexColor parses "#rgb", "#rgba", "#rrggbb" and "#rrggbbaa" colors. The
+// second result is false when s isn't a hex color.
+func ParseHexColor(s string) (color.NRGBA, bool) {
+	return objects.ParseHexColor(s)
+}
+
+// FormatHexColor is the inverse of ParseHexColor, alpha is only written when
+// the color isn't opaque.
+func FormatHexColor(c color.NRGBA) string {
+	if c.A == 255 {
+		return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+	}
+	return fmt.Sprintf("#%02x%02x%02x%02x", c.R, c.G, c.B, c.A)
+}
+
+// InterpolateColor blends two hex colors in the given color space, the second
+// result is false when either value isn't a hex color.
+func InterpolateColor(from, to string, t float64, interpolate ColorInterpolator) (string, bool) {
+	fromColor, ok := ParseHexColor(from)
+	if !ok {
+		return "", false
+	}
+	toColor, ok := ParseHexColor(to)
+	if !ok {
+		return "", false
+	}
+	return FormatHexColor(interpolate(fromColor, toColor, t)), true
+}
+
+func lerp(a, b, t float64) float64 {
+	return a + (b-a)*t
+}
+
+func toUnit(v uint8) float64 {
+	return float64(v) / 255
+}
+
+func fromUnit(v float64) uint8 {
+	return uint8(math.Round(clamp01(v) * 255))
+}
+
+// premultipliedLerp interpolates color channels weighted by alpha so fading
+// from or to a transparent color doesn't pass through its hidden color.
+func premultipliedLerp(from, to [3]float64, fromAlpha, toAlpha, t float64) ([3]float64, float64) {
+	alpha := lerp(fromAlpha, toAlpha, t)
+	var out [3]float64
+	for i := range out {
+		v := lerp(from[i]*fromAlpha, to[i]*toAlpha, t)
+		if alpha > 0 {
+			v /= alpha
+		}
+		out[i] = v
+	}
+	return out, alpha
+}
+
+func interpolateRGB(from, to color.NRGBA, t float64) color.NRGBA {
+	c, a := premultipliedLerp(
+		[3]float64{toUnit(from.R), toUnit(from.G), toUnit(from.B)},
+		[3]float64{toUnit(to.R), toUnit(to.G), toUnit(to.B)},
+		toUnit(from.A), toUnit(to.A), t,
+	)
+	return color.NRGBA{R: fromUnit(c[0]), G: fromUnit(c[1]), B: fromUnit(c[2]), A: fromUnit(a)}
+}
+
+func srgbToLinear(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(v float64) float64 {
+	if v <= 0.0031308 {
+		return v * 12.92
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+func toLinear(c color.NRGBA) [3]float64 {
+	return [3]float64{srgbToLinear(toUnit(c.R)), srgbToLinear(toUnit(c.G)), srgbToLinear(toUnit(c.B))}
+}
+
+func fromLinear(c [3]float64, a float64) color.NRGBA {
+	return color.NRGBA{
+		R: fromUnit(linearToSRGB(clamp01(c[0]))),
+		G: fromUnit(linearToSRGB(clamp01(c[1]))),
+		B: fromUnit(linearToSRGB(clamp01(c[2]))),
+		A: fromUnit(a),
+	}
+}
+
+func interpolateLinearRGB(from, to color.NRGBA, t float64) color.NRGBA {
+	c, a := premultipliedLerp(toLinear(from), toLinear(to), toUnit(from.A), toUnit(to.A), t)
+	return fromLinear(c, a)
+}
+
+func linearToOKLab(c [3]float64) [3]float64 {
+	l := math.Cbrt(0.4122214708*c[0] + 0.5363325363*c[1] + 0.0514459929*c[2])
+	m := math.Cbrt(0.2119034982*c[0] + 0.6806995451*c[1] + 0.1073969566*c[2])
+	s := math.Cbrt(0.0883024619*c[0] + 0.2817188376*c[1] + 0.6299787005*c[2])
+	return [3]float64{
+		0.2104542553*l + 0.7936177850*m - 0.0040720468*s,
+		1.9779984951*l - 2.4285922050*m + 0.4505937099*s,
+		0.0259040371*l + 0.7827717662*m - 0.8086757660*s,
+	}
+}
+
+func okLabToLinear(c [3]float64) [3]float64 {
+	l := math.Pow(c[0]+0.3963377774*c[1]+0.2158037573*c[2], 3)
+	m := math.Pow(c[0]-0.1055613458*c[1]-0.0638541728*c[2], 3)
+	s := math.Pow(c[0]-0.0894841775*c[1]-1.2914855480*c[2], 3)
+	return [3]float64{
+		+4.0767416621*l - 3.3077115913*m + 0.2309699292*s,
+		-1.2684380046*l + 2.6097574011*m - 0.3413193965*s,
+		-0.0041960863*l - 0.7034186147*m + 1.7076147010*s,
+	}
+}
+
+func interpolateOKLab(from, to color.NRGBA, t float64) color.NRGBA {
+	c, a := premultipliedLerp(
+		linearToOKLab(toLinear(from)),
+		linearToOKLab(toLinear(to)),
+		toUnit(from.A), toUnit(to.A), t,
+	)
+	return fromLinear(okLabToLinear(c), a)
+}
+
+// rgbToHSL returns hue in degrees, saturation and lightness in [0, 1].
+func rgbToHSL(c color.NRGBA) (h, s, l float64) {
+	r, g, b := toUnit(c.R), toUnit(c.G), toUnit(c.B)
+	max := math.Max(r, math.Max(g, b))
+	min := math.Min(r, math.Min(g, b))
+	l = (max + min) / 2
+	if max == min {
+		return 0, 0, l
+	}
+	d := max - min
+	if l > 0.5 {
+		s = d / (2 - max - min)
+	} else {
+		s = d / (max + min)
+	}
+	switch max {
+	case r:
+		h = (g - b) / d
+		if g < b {
+			h += 6
+		}
+	case g:
+		h = (b-r)/d + 2
+	default:
+		h = (r-g)/d + 4
+	}
+	return h * 60, s, l
+}
+
+func hslToRGB(h, s, l float64) (r, g, b float64) {
+	h = math.Mod(h, 360)
+	if h < 0 {
+		h += 360
+	}
+	c := (1 - math.Abs(2*l-1)) * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := l - c/2
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+	return r + m, g + m, b + m
+}
+
+func interpolateHSL(from, to color.NRGBA, t float64) color.NRGBA {
+	h1, s1, l1 := rgbToHSL(from)
+	h2, s2, l2 := rgbToHSL(to)
+
+	// Achromatic colors have no meaningful hue, borrow the other one so a
+	// fade from white to red doesn't sweep through the whole wheel.
+	if s1 == 0 {
+		h1 = h2
+	}
+	if s2 == 0 {
+		h2 = h1
+	}
+	// Take the shorter way around the hue wheel.
+	if h2-h1 > 180 {
+		h1 += 360
+	} else if h1-h2 > 180 {
+		h2 += 360
+	}
+
+	r, g, b := hslToRGB(lerp(h1, h2, t), clamp01(lerp(s1, s2, t)), clamp01(lerp(l1, l2, t)))
+	return color.NRGBA{
+		R: fromUnit(r),
+		G: fromUnit(g),
+		B: fromUnit(b),
+		A: fromUnit(lerp(toUnit(from.A), toUnit(to.A), t)),
+	}
+}
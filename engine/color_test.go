@@ -0,0 +1,60 @@
+package engine
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestParseHexColor(t *testing.T) {
+	cases := map[string]color.NRGBA{
+		"#fff":      {255, 255, 255, 255},
+		"#f008":     {255, 0, 0, 136},
+		"#ff0000":   {255, 0, 0, 255},
+		"#00ff0080": {0, 255, 0, 128},
+	}
+	for input, expected := range cases {
+		c, ok := ParseHexColor(input)
+		if !ok {
+			t.Errorf("Failed to parse %q", input)
+			continue
+		}
+		if c != expected {
+			t.Errorf("ParseHexColor(%q) = %v, expected %v", input, c, expected)
+		}
+	}
+
+	for _, input := range []string{"accentColor", "#ff000", "#gggggg", "ff0000"} {
+		if _, ok := ParseHexColor(input); ok {
+			t.Errorf("Expected %q not to parse as a color", input)
+		}
+	}
+}
+
+func TestInterpolateColor(t *testing.T) {
+	for space := range colorSpaces {
+		interpolate, err := ParseColorSpace(space)
+		if err != nil {
+			t.Fatalf("Failed to parse color space %s: %v", space, err)
+		}
+		if c, _ := InterpolateColor("#ffffff", "#ff0000", 0, interpolate); c != "#ffffff" {
+			t.Errorf("%s: expected start color, got %s", space, c)
+		}
+		if c, _ := InterpolateColor("#ffffff", "#ff0000", 1, interpolate); c != "#ff0000" {
+			t.Errorf("%s: expected end color, got %s", space, c)
+		}
+	}
+
+	rgb, _ := ParseColorSpace("")
+	if c, _ := InterpolateColor("#000000", "#ffffff", 0.5, rgb); c != "#808080" {
+		t.Errorf("Expected #808080, got %s", c)
+	}
+	if c, _ := InterpolateColor("#ff000000", "#ff0000ff", 0.5, rgb); c != "#ff000080" {
+		t.Errorf("Expected #ff000080, got %s", c)
+	}
+	if _, ok := InterpolateColor("accentColor", "#ff0000", 0.5, rgb); ok {
+		t.Errorf("Expected non-color strings not to interpolate")
+	}
+	if _, err := ParseColorSpace("cmyk"); err == nil {
+		t.Errorf("Expected error for unknown color space")
+	}
+}
@@ -0,0 +1,111 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+)
+
+// Compile pre-compiles every property, duration, repeat, delay and when
+// expression of the scene, type checked against Env, and parses the easings
+// and color spaces of the animations. Rendering a compiled scene only runs
+// the programs instead of compiling every expression on every frame.
+func (scene *Scene) Compile() error {
+	scene.programs = make(map[string]*vm.Program)
+	if err := scene.compileObjects(scene.Objects); err != nil {
+		return err
+	}
+	if background := scene.backgroundObject(); background != nil {
+		if err := scene.compileProperties(background.Properties, objectSchemas[background.Type]); err != nil {
+			return fmt.Errorf("background: %w", err)
+		}
+	}
+	if scene.Frame.Decay != "" {
+		if _, err := scene.compile(scene.Frame.Decay); err != nil {
+			return fmt.Errorf("decay: %w", err)
+		}
+	}
+	for i := range scene.Animations {
+		animation := &scene.Animations[i]
+		if err := animation.resolve(); err != nil {
+			return fmt.Errorf("animation %s: %w", animation.Name, err)
+		}
+		for _, expression := range []string{animation.Duration, animation.Repeat, animation.Delay, animation.When} {
+			if expression == "" {
+				continue
+			}
+			if _, err := scene.compile(expression); err != nil {
+				return fmt.Errorf("animation %s: %w", animation.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (scene *Scene) compileObjects(wrappers []ObjectWrapper) error {
+	for _, wrapper := range wrappers {
+		if err := scene.compileProperties(wrapper.Properties, objectSchemas[wrapper.Type]); err != nil {
+			return fmt.Errorf("object %s: %w", wrapper.Name, err)
+		}
+		if err := scene.compileObjects(wrapper.Objects); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compileProperties compiles the expressions of properties, skipping those
+// schema says are literal.
+func (scene *Scene) compileProperties(properties map[string]interface{}, schema map[string]propertySpec) error {
+	for key, value := range properties {
+		spec, _, _ := lookupProperty(schema, key)
+		if spec.literal() {
+			continue
+		}
+		if err := scene.compileValue(value, spec.nested()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compileValue compiles a property value, schema is the one of the mappings it
+// holds.
+func (scene *Scene) compileValue(value interface{}, schema map[string]propertySpec) error {
+	switch v := value.(type) {
+	case string:
+		if _, ok := ParseHexColor(v); ok {
+			return nil
+		}
+		_, err := scene.compile(v)
+		return err
+	case map[string]interface{}:
+		return scene.compileProperties(v, schema)
+	case []interface{}:
+		for _, item := range v {
+			if err := scene.compileValue(item, schema); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// compile returns the cached program for an expression, compiling it on a
+// miss.
+func (scene *Scene) compile(expression string) (*vm.Program, error) {
+	if program, ok := scene.programs[expression]; ok {
+		return program, nil
+	}
+	env := scene.Env
+	if env == nil {
+		env = map[string]interface{}{}
+	}
+	program, err := expr.Compile(expression, append(scene.functions(), expr.Env(env))...)
+	if err != nil {
+		return nil, err
+	}
+	scene.programs[expression] = program
+	return program, nil
+}
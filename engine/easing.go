@@ -0,0 +1,276 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	EasingLinear           = "linear"
+	EasingEase             = "ease"
+	EasingEaseIn           = "ease-in"
+	EasingEaseOut          = "ease-out"
+	EasingEaseInOut        = "ease-in-out"
+	EasingEaseInQuad       = "ease-in-quad"
+	EasingEaseOutQuad      = "ease-out-quad"
+	EasingEaseInOutQuad    = "ease-in-out-quad"
+	EasingEaseInCubic      = "ease-in-cubic"
+	EasingEaseOutCubic     = "ease-out-cubic"
+	EasingEaseInOutCubic   = "ease-in-out-cubic"
+	EasingEaseInSine       = "ease-in-sine"
+	EasingEaseOutSine      = "ease-out-sine"
+	EasingEaseInOutSine    = "ease-in-out-sine"
+	EasingEaseInExpo       = "ease-in-expo"
+	EasingEaseOutExpo      = "ease-out-expo"
+	EasingEaseInOutExpo    = "ease-in-out-expo"
+	EasingEaseInBack       = "ease-in-back"
+	EasingEaseOutBack      = "ease-out-back"
+	EasingEaseInOutBack    = "ease-in-out-back"
+	EasingEaseInElastic    = "ease-in-elastic"
+	EasingEaseOutElastic   = "ease-out-elastic"
+	EasingEaseInOutElastic = "ease-in-out-elastic"
+	EasingEaseInBounce     = "ease-in-bounce"
+	EasingEaseOutBounce    = "ease-out-bounce"
+	EasingEaseInOutBounce  = "ease-in-out-bounce"
+	EasingStepStart        = "step-start"
+	EasingStepEnd          = "step-end"
+)
+
+// EasingFunc maps linear progress in [0, 1] to eased progress. The result is
+// 0 at 0 and 1 at 1 but may overshoot in between (back, elastic).
+type EasingFunc func(t float64) float64
+
+const (
+	backC1    = 1.70158
+	backC2    = backC1 * 1.525
+	backC3    = backC1 + 1
+	elasticC4 = 2 * math.Pi / 3
+	elasticC5 = 2 * math.Pi / 4.5
+)
+
+var easings = map[string]EasingFunc{
+	EasingLinear:    func(t float64) float64 { return t },
+	EasingEase:      cubicBezier(0.25, 0.1, 0.25, 1),
+	EasingEaseIn:    cubicBezier(0.42, 0, 1, 1),
+	EasingEaseOut:   cubicBezier(0, 0, 0.58, 1),
+	EasingEaseInOut: cubicBezier(0.42, 0, 0.58, 1),
+
+	EasingEaseInQuad:  func(t float64) float64 { return t * t },
+	EasingEaseOutQuad: func(t float64) float64 { return 1 - (1-t)*(1-t) },
+	EasingEaseInOutQuad: func(t float64) float64 {
+		if t < 0.5 {
+			return 2 * t * t
+		}
+		return 1 - math.Pow(-2*t+2, 2)/2
+	},
+
+	EasingEaseInCubic:  func(t float64) float64 { return t * t * t },
+	EasingEaseOutCubic: func(t float64) float64 { return 1 - math.Pow(1-t, 3) },
+	EasingEaseInOutCubic: func(t float64) float64 {
+		if t < 0.5 {
+			return 4 * t * t * t
+		}
+		return 1 - math.Pow(-2*t+2, 3)/2
+	},
+
+	EasingEaseInSine:    func(t float64) float64 { return 1 - math.Cos(t*math.Pi/2) },
+	EasingEaseOutSine:   func(t float64) float64 { return math.Sin(t * math.Pi / 2) },
+	EasingEaseInOutSine: func(t float64) float64 { return -(math.Cos(math.Pi*t) - 1) / 2 },
+
+	EasingEaseInExpo: func(t float64) float64 {
+		if t <= 0 {
+			return 0
+		}
+		return math.Pow(2, 10*t-10)
+	},
+	EasingEaseOutExpo: func(t float64) float64 {
+		if t >= 1 {
+			return 1
+		}
+		return 1 - math.Pow(2, -10*t)
+	},
+	EasingEaseInOutExpo: func(t float64) float64 {
+		switch {
+		case t <= 0:
+			return 0
+		case t >= 1:
+			return 1
+		case t < 0.5:
+			return math.Pow(2, 20*t-10) / 2
+		}
+		return (2 - math.Pow(2, -20*t+10)) / 2
+	},
+
+	EasingEaseInBack:  func(t float64) float64 { return backC3*t*t*t - backC1*t*t },
+	EasingEaseOutBack: func(t float64) float64 { return 1 + backC3*math.Pow(t-1, 3) + backC1*math.Pow(t-1, 2) },
+	EasingEaseInOutBack: func(t float64) float64 {
+		if t < 0.5 {
+			return (math.Pow(2*t, 2) * ((backC2+1)*2*t - backC2)) / 2
+		}
+		return (math.Pow(2*t-2, 2)*((backC2+1)*(t*2-2)+backC2) + 2) / 2
+	},
+
+	EasingEaseInElastic: func(t float64) float64 {
+		if t <= 0 || t >= 1 {
+			return clamp01(t)
+		}
+		return -math.Pow(2, 10*t-10) * math.Sin((t*10-10.75)*elasticC4)
+	},
+	EasingEaseOutElastic: func(t float64) float64 {
+		if t <= 0 || t >= 1 {
+			return clamp01(t)
+		}
+		return math.Pow(2, -10*t)*math.Sin((t*10-0.75)*elasticC4) + 1
+	},
+	EasingEaseInOutElastic: func(t float64) float64 {
+		switch {
+		case t <= 0 || t >= 1:
+			return clamp01(t)
+		case t < 0.5:
+			return -(math.Pow(2, 20*t-10) * math.Sin((20*t-11.125)*elasticC5)) / 2
+		}
+		return (math.Pow(2, -20*t+10)*math.Sin((20*t-11.125)*elasticC5))/2 + 1
+	},
+
+	EasingEaseInBounce:  func(t float64) float64 { return 1 - bounceOut(1-t) },
+	EasingEaseOutBounce: bounceOut,
+	EasingEaseInOutBounce: func(t float64) float64 {
+		if t < 0.5 {
+			return (1 - bounceOut(1-2*t)) / 2
+		}
+		return (1 + bounceOut(2*t-1)) / 2
+	},
+
+	EasingStepStart: func(t float64) float64 {
+		if t > 0 {
+			return 1
+		}
+		return 0
+	},
+	EasingStepEnd: func(t float64) float64 {
+		if t >= 1 {
+			return 1
+		}
+		return 0
+	},
+}
+
+// ParseEasing resolves an easing name from the catalog above, or a CSS style
+// "cubic-bezier(x1, y1, x2, y2)" or "steps(n)" definition. An empty name is
+// linear so scenes without an easing keep interpolating linearly.
+func ParseEasing(name string) (EasingFunc, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return easings[EasingLinear], nil
+	}
+	if fn, ok := easings[name]; ok {
+		return fn, nil
+	}
+
+	if args, ok := easingArgs(name, "cubic-bezier"); ok {
+		var x1, y1, x2, y2 float64
+		if _, err := fmt.Sscanf(args, "%g,%g,%g,%g", &x1, &y1, &x2, &y2); err != nil {
+			return nil, fmt.Errorf("invalid cubic-bezier easing %q: %w", name, err)
+		}
+		if x1 < 0 || x1 > 1 || x2 < 0 || x2 > 1 {
+			return nil, fmt.Errorf("invalid cubic-bezier easing %q: x values must be in [0, 1]", name)
+		}
+		return cubicBezier(x1, y1, x2, y2), nil
+	}
+
+	if args, ok := easingArgs(name, "steps"); ok {
+		var n int
+		if _, err := fmt.Sscanf(args, "%d", &n); err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid steps easing %q", name)
+		}
+		return func(t float64) float64 {
+			return math.Min(math.Floor(t*float64(n)), float64(n)) / float64(n)
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown easing: %s", name)
+}
+
+// easingArgs returns the argument list of a "fn(args)" definition with all
+// whitespace removed.
+func easingArgs(name, fn string) (string, bool) {
+	if !strings.HasPrefix(name, fn+"(") || !strings.HasSuffix(name, ")") {
+		return "", false
+	}
+	args := name[len(fn)+1 : len(name)-1]
+	return strings.Join(strings.Fields(args), ""), true
+}
+
+func bounceOut(t float64) float64 {
+	const n1, d1 = 7.5625, 2.75
+	switch {
+	case t < 1/d1:
+		return n1 * t * t
+	case t < 2/d1:
+		t -= 1.5 / d1
+		return n1*t*t + 0.75
+	case t < 2.5/d1:
+		t -= 2.25 / d1
+		return n1*t*t + 0.9375
+	}
+	t -= 2.625 / d1
+	return n1*t*t + 0.984375
+}
+
+// cubicBezier builds a CSS style timing function for the curve through
+// (0, 0), (x1, y1), (x2, y2) and (1, 1).
+func cubicBezier(x1, y1, x2, y2 float64) EasingFunc {
+	cx := 3 * x1
+	bx := 3*(x2-x1) - cx
+	ax := 1 - cx - bx
+	cy := 3 * y1
+	by := 3*(y2-y1) - cy
+	ay := 1 - cy - by
+
+	sampleX := func(s float64) float64 { return ((ax*s+bx)*s + cx) * s }
+	sampleY := func(s float64) float64 { return ((ay*s+by)*s + cy) * s }
+	slopeX := func(s float64) float64 { return (3*ax*s+2*bx)*s + cx }
+
+	return func(t float64) float64 {
+		if t <= 0 || t >= 1 {
+			return clamp01(t)
+		}
+
+		// Newton-Raphson first, it converges in a handful of steps for
+		// well-behaved curves.
+		s := t
+		for i := 0; i < 8; i++ {
+			x := sampleX(s) - t
+			if math.Abs(x) < 1e-7 {
+				return sampleY(s)
+			}
+			d := slopeX(s)
+			if math.Abs(d) < 1e-6 {
+				break
+			}
+			s -= x / d
+		}
+
+		// Fall back to bisection when the slope is too flat.
+		lo, hi := 0.0, 1.0
+		s = t
+		for i := 0; i < 32; i++ {
+			x := sampleX(s)
+			if math.Abs(x-t) < 1e-7 {
+				break
+			}
+			if x < t {
+				lo = s
+			} else {
+				hi = s
+			}
+			s = (lo + hi) / 2
+		}
+		return sampleY(s)
+	}
+}
+
+func clamp01(t float64) float64 {
+	return math.Max(0, math.Min(1, t))
+}
@@ -0,0 +1,78 @@
+package engine
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEasingEndpoints(t *testing.T) {
+	for name, fn := range easings {
+		if v := fn(0); math.Abs(v) > 1e-6 {
+			t.Errorf("%s(0) = %v, expected 0", name, v)
+		}
+		if v := fn(1); math.Abs(v-1) > 1e-6 {
+			t.Errorf("%s(1) = %v, expected 1", name, v)
+		}
+	}
+}
+
+func TestParseEasing(t *testing.T) {
+	linear, err := ParseEasing("")
+	if err != nil {
+		t.Fatalf("Failed to parse empty easing: %v", err)
+	}
+	if v := linear(0.3); v != 0.3 {
+		t.Errorf("Expected empty easing to be linear, got %v", v)
+	}
+
+	bezier, err := ParseEasing("cubic-bezier(0, 0, 1, 1)")
+	if err != nil {
+		t.Fatalf("Failed to parse cubic-bezier: %v", err)
+	}
+	for _, x := range []float64{0.1, 0.25, 0.5, 0.75, 0.9} {
+		if v := bezier(x); math.Abs(v-x) > 1e-4 {
+			t.Errorf("cubic-bezier(0, 0, 1, 1)(%v) = %v, expected %v", x, v, x)
+		}
+	}
+
+	steps, err := ParseEasing("steps(4)")
+	if err != nil {
+		t.Fatalf("Failed to parse steps: %v", err)
+	}
+	if v := steps(0.6); v != 0.5 {
+		t.Errorf("steps(4)(0.6) = %v, expected 0.5", v)
+	}
+
+	for _, name := range []string{"wobble", "cubic-bezier(2, 0, 1, 1)", "cubic-bezier(1, 2)", "steps(0)"} {
+		if _, err := ParseEasing(name); err == nil {
+			t.Errorf("Expected error for easing %q", name)
+		}
+	}
+}
+
+func TestCompileResolvesEasings(t *testing.T) {
+	scene := &Scene{
+		Env: map[string]interface{}{"x": 0},
+		Animations: []AnimationWrapper{{
+			Name:       "move",
+			Easing:     "steps(2)",
+			ColorSpace: "oklab",
+			Keyframes:  []KeyframeWrapper{{Time: 0, Easing: "cubic-bezier(0, 0, 1, 1)"}, {Time: 1}},
+		}},
+	}
+	if err := scene.Compile(); err != nil {
+		t.Fatalf("Failed to compile scene: %v", err)
+	}
+	animation := &scene.Animations[0]
+	if animation.curve == nil || animation.interpolateColor == nil || animation.Keyframes[0].curve == nil {
+		t.Fatalf("Expected the easings and color space to be parsed at compile")
+	}
+	if v := animation.Keyframes[1].easing(animation)(0.6); v != 0.5 {
+		t.Errorf("Expected a keyframe without easing to use steps(2), got %v", v)
+	}
+
+	scene.Animations[0] = AnimationWrapper{Name: "move", Easing: "wobble"}
+	if err := scene.Compile(); err == nil {
+		t.Errorf("Expected an error compiling an unknown easing")
+	}
+}
@@ -1,7 +1,9 @@
 package engine
 
 import (
+	"os"
 	"testing"
+	"time"
 
 	"github.com/fogleman/gg"
 )
@@ -36,8 +38,186 @@ func TestRender(t *testing.T) {
 			t.Errorf("Expected a scene but got nil")
 		}
 		ctx := gg.NewContext(scene.Frame.Width, scene.Frame.Height)
-		scene.Render(ctx)
+		scene.Render(ctx, RealClock{})
 	default:
 		t.Errorf("No scene was loaded")
 	}
 }
+
+func TestComputeAnimations(t *testing.T) {
+	seed := int64(1)
+	scene := &Scene{
+		Seed: &seed,
+		Env:  map[string]interface{}{"height": 15, "opacity": 1.0},
+		Animations: []AnimationWrapper{
+			{
+				Name:     "blink",
+				Duration: "1",
+				Repeat:   "true",
+				Delay:    "random(1, 1)",
+				Keyframes: []KeyframeWrapper{
+					{Time: 0, Properties: map[string]interface{}{"height": 0, "opacity": 0}},
+					{Time: 0.5, Properties: map[string]interface{}{"height": 100, "opacity": 0.5}},
+					{Time: 1, Properties: map[string]interface{}{"height": 0, "opacity": 1}},
+				},
+			},
+		},
+	}
+
+	start := time.Unix(1000, 0)
+	clock := NewManualClock(start)
+	cases := []struct {
+		at      time.Duration
+		height  int
+		opacity float64
+	}{
+		{0, 0, 0},
+		{250 * time.Millisecond, 50, 0.25},
+		{750 * time.Millisecond, 50, 0.75},
+		// Held at the last keyframe during the delay between cycles.
+		{1500 * time.Millisecond, 0, 1},
+		// Second cycle starts after duration + delay.
+		{2250 * time.Millisecond, 50, 0.25},
+	}
+	for _, c := range cases {
+		clock.Set(start.Add(c.at))
+		if err := scene.ComputeAnimations(clock.Now()); err != nil {
+			t.Fatalf("Failed to compute animations: %v", err)
+		}
+		if scene.Env["height"] != c.height {
+			t.Errorf("At %v expected height %v, got %v", c.at, c.height, scene.Env["height"])
+		}
+		if opacity, _ := toFloat(scene.Env["opacity"]); opacity != c.opacity {
+			t.Errorf("At %v expected opacity %v, got %v", c.at, c.opacity, scene.Env["opacity"])
+		}
+	}
+}
+
+func TestFixedStepClock(t *testing.T) {
+	start := time.Unix(0, 0)
+	clock := NewFixedStepClock(start, 50*time.Millisecond)
+	for i := 0; i < 3; i++ {
+		if now := clock.Now(); !now.Equal(start.Add(time.Duration(i) * 50 * time.Millisecond)) {
+			t.Errorf("Frame %d at %v", i, now)
+		}
+	}
+}
+
+func TestElapsedFromZeroTime(t *testing.T) {
+	scene, err := ParseScene([]byte("version: 1\nframe:\n  width: 8\n  height: 8\n"))
+	if err != nil {
+		t.Fatalf("Failed to parse scene: %v", err)
+	}
+	ctx := gg.NewContext(8, 8)
+	// The render subcommand starts its clock at the zero time.
+	clock := NewFixedStepClock(time.Time{}, 50*time.Millisecond)
+	scene.Render(ctx, clock)
+	scene.Render(ctx, clock)
+	if scene.elapsed != 50*time.Millisecond {
+		t.Errorf("Expected 50ms elapsed on the second frame, got %v", scene.elapsed)
+	}
+}
+
+func benchmarkRender(b *testing.B, compile bool) {
+	data, err := os.ReadFile("../scenes/ein.yml")
+	if err != nil {
+		b.Fatalf("Failed to read scene: %v", err)
+	}
+	scene, err := ParseScene(data)
+	if err != nil {
+		b.Fatalf("Failed to parse scene: %v", err)
+	}
+	if compile {
+		if err := scene.Compile(); err != nil {
+			b.Fatalf("Failed to compile scene: %v", err)
+		}
+	}
+	ctx := gg.NewContext(scene.Frame.Width, scene.Frame.Height)
+	clock := NewFixedStepClock(time.Unix(0, 0), 50*time.Millisecond)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		scene.Render(ctx, clock)
+	}
+}
+
+// BenchmarkRender is the per-frame cost of a compiled scene, compare with
+// BenchmarkRenderUncompiled which compiles every expression on every frame.
+func BenchmarkRender(b *testing.B) {
+	benchmarkRender(b, true)
+}
+
+func BenchmarkRenderUncompiled(b *testing.B) {
+	benchmarkRender(b, false)
+}
+
+func TestSetEnv(t *testing.T) {
+	scene := &Scene{Env: map[string]interface{}{"size": 4, "mood": "calm"}}
+	if err := scene.SetEnv(map[string]interface{}{"size": 6.0, "mood": "happy"}); err != nil {
+		t.Fatalf("Failed to set env: %v", err)
+	}
+	if scene.Env["size"] != 6 || scene.Env["mood"] != "happy" {
+		t.Errorf("Expected size 6 and mood happy, got %v", scene.Env)
+	}
+	if err := scene.SetEnv(map[string]interface{}{"mood": "sad", "colour": "#ff0000"}); err == nil {
+		t.Errorf("Expected an error setting an undefined variable")
+	}
+	if scene.Env["mood"] != "happy" {
+		t.Errorf("Expected no variable to change on error, got mood %v", scene.Env["mood"])
+	}
+	for _, values := range []map[string]interface{}{
+		{"mood": "sad", "size": "big"},
+		{"mood": true},
+		{"size": 6.5},
+	} {
+		if err := scene.SetEnv(values); err == nil {
+			t.Errorf("Expected an error setting %v", values)
+		}
+	}
+	if scene.Env["size"] != 6 || scene.Env["mood"] != "happy" {
+		t.Errorf("Expected no variable to change on a type error, got %v", scene.Env)
+	}
+}
+
+func TestLiteralProperties(t *testing.T) {
+	evaluate := func(expression string) (interface{}, error) {
+		return EvaluateExpression(expression, map[string]interface{}{"d": 2})
+	}
+	tests := []struct {
+		objectType string
+		properties map[string]interface{}
+		path       []interface{}
+		expected   interface{}
+	}{
+		{ObjectTypeImage, map[string]interface{}{"src": "face.png"}, []interface{}{"src"}, "face.png"},
+		{ObjectTypePath, map[string]interface{}{"d": "M 0 0 L 4 4"}, []interface{}{"d"}, "M 0 0 L 4 4"},
+		// d is only path data on paths.
+		{ObjectTypeCircle, map[string]interface{}{"radius": "d"}, []interface{}{"radius"}, 2},
+		{ObjectTypePath, map[string]interface{}{"segments": []interface{}{
+			map[string]interface{}{"type": "move", "x": "d", "y": "0"},
+		}}, []interface{}{"segments", 0, "type"}, "move"},
+		{ObjectTypeCircle, map[string]interface{}{"fill": map[string]interface{}{
+			"type": "radial", "x": "d", "y": "0", "r": "d",
+		}}, []interface{}{"fill", "type"}, "radial"},
+		{ObjectTypeCircle, map[string]interface{}{"fill": map[string]interface{}{
+			"type": "radial", "x": "d", "y": "0", "r": "d",
+		}}, []interface{}{"fill", "x"}, 2},
+	}
+	for _, test := range tests {
+		computed, err := processProperties(test.properties, objectSchemas[test.objectType], evaluate)
+		if err != nil {
+			t.Fatalf("Failed to process %v: %v", test.properties, err)
+		}
+		var got interface{} = computed
+		for _, step := range test.path {
+			switch step := step.(type) {
+			case string:
+				got = got.(map[string]interface{})[step]
+			case int:
+				got = got.([]interface{})[step]
+			}
+		}
+		if got != test.expected {
+			t.Errorf("%s %v: Expected %v, got %v", test.objectType, test.path, test.expected, got)
+		}
+	}
+}
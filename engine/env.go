@@ -0,0 +1,35 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// SetEnv sets env variables from outside the scene, all of them or none when
+// one isn't defined in the scene's env or holds another type. Whole numbers
+// set where the env holds an int stay ints, so compiled expressions keep the
+// types they were checked against.
+func (scene *Scene) SetEnv(values map[string]interface{}) error {
+	converted := make(map[string]interface{}, len(values))
+	for _, key := range sortedKeys(values) {
+		current, ok := scene.Env[key]
+		if !ok {
+			return fmt.Errorf("env variable %q is not defined in the scene", key)
+		}
+		value := values[key]
+		if _, isInt := current.(int); isInt {
+			if f, ok := value.(float64); ok && f == math.Trunc(f) {
+				value = int(f)
+			}
+		}
+		if reflect.TypeOf(value) != reflect.TypeOf(current) {
+			return fmt.Errorf("env variable %q is a %T, got a %T", key, current, values[key])
+		}
+		converted[key] = value
+	}
+	for key, value := range converted {
+		scene.Env[key] = value
+	}
+	return nil
+}
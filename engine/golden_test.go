@@ -0,0 +1,164 @@
+package engine
+
+import (
+	"einclient/engine/objects"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fogleman/gg"
+)
+
+var (
+	update    = flag.Bool("update", false, "regenerate the golden images in testdata/golden")
+	tolerance = flag.Int("tolerance", 2, "maximum per-channel difference between a render and its golden image")
+)
+
+func init() {
+	// Scenes name fonts and images relative to the repository root.
+	objects.FontDirs = append(objects.FontDirs, "../rgbmatrix/lib/rpi-rgb-led-matrix/fonts")
+	objects.ImageDirs = append(objects.ImageDirs, "..")
+}
+
+const (
+	goldenDir = "testdata/golden"
+	failedDir = "testdata/failed"
+)
+
+// goldenTimestamps are the scene clock offsets every scene is rendered at,
+// picked to land inside and around the blink in ein.yml.
+var goldenTimestamps = []time.Duration{
+	0,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	150 * time.Millisecond,
+	time.Second,
+}
+
+func TestGoldenScenes(t *testing.T) {
+	paths, err := filepath.Glob("../scenes/*.yml")
+	if err != nil {
+		t.Fatalf("Failed to list scenes: %v", err)
+	}
+	if len(paths) == 0 {
+		t.Fatalf("No scenes found")
+	}
+	for _, path := range paths {
+		path := path
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		t.Run(name, func(t *testing.T) {
+			scene, err := ReadScene(path)
+			if err != nil {
+				t.Fatalf("Failed to load scene: %v", err)
+			}
+			seed := int64(1)
+			scene.Seed = &seed
+
+			start := time.Unix(0, 0)
+			clock := NewManualClock(start)
+			ctx := gg.NewContext(scene.Frame.Width, scene.Frame.Height)
+			for _, at := range goldenTimestamps {
+				clock.Set(start.Add(at))
+				if err := scene.Render(ctx, clock); err != nil {
+					t.Fatalf("Failed to render at %v: %v", at, err)
+				}
+				checkGolden(t, fmt.Sprintf("%s-%04dms", name, at.Milliseconds()), ctx.Image())
+			}
+		})
+	}
+}
+
+// checkGolden compares img against testdata/golden/<name>.png, or rewrites
+// it with -update. Mismatches leave the render and a diff image in
+// testdata/failed.
+func checkGolden(t *testing.T, name string, img image.Image) {
+	t.Helper()
+	goldenPath := filepath.Join(goldenDir, name+".png")
+	if *update {
+		if err := writePNG(goldenPath, img); err != nil {
+			t.Fatalf("Failed to update golden image: %v", err)
+		}
+		return
+	}
+
+	golden, err := readPNG(goldenPath)
+	if err != nil {
+		t.Fatalf("Failed to read golden image (run with -update to create it): %v", err)
+	}
+	if golden.Bounds() != img.Bounds() {
+		t.Errorf("%s: size %v doesn't match golden %v", name, img.Bounds(), golden.Bounds())
+		return
+	}
+
+	diff, count := diffImages(golden, img, *tolerance)
+	if count == 0 {
+		return
+	}
+	t.Errorf("%s: %d pixels differ from the golden image by more than %d, see %s", name, count, *tolerance, failedDir)
+	if err := writePNG(filepath.Join(failedDir, name+".png"), img); err != nil {
+		t.Errorf("Failed to write render: %v", err)
+	}
+	if err := writePNG(filepath.Join(failedDir, name+"-diff.png"), diff); err != nil {
+		t.Errorf("Failed to write diff image: %v", err)
+	}
+}
+
+// diffImages highlights the pixels of b that differ from a in red over a
+// dimmed copy of a, and counts them.
+func diffImages(a, b image.Image, tolerance int) (*image.RGBA, int) {
+	bounds := a.Bounds()
+	diff := image.NewRGBA(bounds)
+	count := 0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			ca := color.RGBAModel.Convert(a.At(x, y)).(color.RGBA)
+			cb := color.RGBAModel.Convert(b.At(x, y)).(color.RGBA)
+			if channelDelta(ca.R, cb.R) > tolerance || channelDelta(ca.G, cb.G) > tolerance ||
+				channelDelta(ca.B, cb.B) > tolerance || channelDelta(ca.A, cb.A) > tolerance {
+				diff.Set(x, y, color.RGBA{255, 0, 0, 255})
+				count++
+				continue
+			}
+			diff.Set(x, y, color.RGBA{ca.R / 4, ca.G / 4, ca.B / 4, 255})
+		}
+	}
+	return diff, count
+}
+
+func channelDelta(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
+
+func readPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
+
+func writePNG(path string, img image.Image) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
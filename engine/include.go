@@ -0,0 +1,488 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/expr-lang/expr/file"
+	"github.com/expr-lang/expr/parser/lexer"
+	"gopkg.in/yaml.v3"
+)
+
+// maxComponentDepth stops components that instantiate each other.
+const maxComponentDepth = 16
+
+var (
+	// includeKeys are the top level keys of included files.
+	includeKeys   = []string{"version", "include", "env", "components", "objects", "animations"}
+	componentKeys = []string{"params", "objects"}
+	instanceKeys  = []string{"name", "component", "params"}
+)
+
+// expandedScene is a scene document with its includes merged and its
+// component instances replaced by their objects.
+type expandedScene struct {
+	root *yaml.Node
+	// files are the scene file and every file it includes.
+	files []string
+	// origins maps nodes coming from included files to their file.
+	origins map[*yaml.Node]string
+}
+
+type component struct {
+	params  *yaml.Node
+	objects []*yaml.Node
+}
+
+// sceneParts is what a file adds to the scene, in the order it is drawn.
+type sceneParts struct {
+	env        []*yaml.Node
+	objects    []*yaml.Node
+	animations []*yaml.Node
+}
+
+type expander struct {
+	origins    map[*yaml.Node]string
+	files      []string
+	components map[string]*component
+	including  []string
+	errors     ValidationErrors
+}
+
+func (e *expander) errorf(node *yaml.Node, format string, args ...interface{}) {
+	e.errors = append(e.errors, &ValidationError{
+		File:    e.origins[node],
+		Line:    node.Line,
+		Column:  node.Column,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// expandScene resolves the includes of a scene file, relative to the file
+// including them, and instantiates its components. Included env values are
+// overridden by the including file, their objects and animations come first.
+func expandScene(filePath string, data []byte) (*expandedScene, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("file is empty")
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, fmt.Errorf("file is empty")
+	}
+	root := doc.Content[0]
+
+	e := &expander{
+		origins:    make(map[*yaml.Node]string),
+		files:      []string{filePath},
+		components: make(map[string]*component),
+		including:  []string{filepath.Clean(filePath)},
+	}
+	e.mark(root, filePath)
+	if root.Kind != yaml.MappingNode {
+		return &expandedScene{root: root, files: e.files, origins: e.origins}, nil
+	}
+	parts := e.load(filePath, root, false)
+
+	expanded := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: root.Line, Column: root.Column}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		switch root.Content[i].Value {
+		case "include", "components", "env", "objects", "animations":
+			continue
+		}
+		expanded.Content = append(expanded.Content, root.Content[i], root.Content[i+1])
+	}
+	if env := mergeEnv(parts.env); env != nil {
+		expanded.Content = append(expanded.Content, scalarNode("env"), env)
+	}
+	if objects := e.instantiate(parts.objects, "", nil, 0); len(objects) > 0 {
+		expanded.Content = append(expanded.Content, scalarNode("objects"), sequenceNode(objects))
+	}
+	if len(parts.animations) > 0 {
+		expanded.Content = append(expanded.Content, scalarNode("animations"), sequenceNode(parts.animations))
+	}
+
+	if len(e.errors) > 0 {
+		e.errors.sort()
+		return nil, e.errors
+	}
+	return &expandedScene{root: expanded, files: e.files, origins: e.origins}, nil
+}
+
+// load collects the parts of a file, its includes first.
+func (e *expander) load(filePath string, node *yaml.Node, included bool) sceneParts {
+	var parts sceneParts
+	pairs := make(map[string]*yaml.Node)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i]
+		if included && !contains(includeKeys, key.Value) {
+			e.errorf(key, "unknown key %q in an included file", key.Value)
+			continue
+		}
+		pairs[key.Value] = node.Content[i+1]
+	}
+
+	if include, ok := pairs["include"]; ok {
+		for _, path := range e.list(include, "include") {
+			sub := e.include(filePath, path)
+			parts.env = append(parts.env, sub.env...)
+			parts.objects = append(parts.objects, sub.objects...)
+			parts.animations = append(parts.animations, sub.animations...)
+		}
+	}
+	if components, ok := pairs["components"]; ok {
+		e.defineComponents(components)
+	}
+	if env, ok := pairs["env"]; ok {
+		if env.Kind != yaml.MappingNode {
+			e.errorf(env, "env must be a mapping")
+		} else {
+			parts.env = append(parts.env, env.Content...)
+		}
+	}
+	if objects, ok := pairs["objects"]; ok {
+		parts.objects = append(parts.objects, e.list(objects, "objects")...)
+	}
+	if animations, ok := pairs["animations"]; ok {
+		parts.animations = append(parts.animations, e.list(animations, "animations")...)
+	}
+	return parts
+}
+
+func (e *expander) list(node *yaml.Node, what string) []*yaml.Node {
+	if node.Kind != yaml.SequenceNode {
+		e.errorf(node, "%s must be a list", what)
+		return nil
+	}
+	return node.Content
+}
+
+func (e *expander) include(from string, node *yaml.Node) sceneParts {
+	if node.Kind != yaml.ScalarNode {
+		e.errorf(node, "include must be a path")
+		return sceneParts{}
+	}
+	path := node.Value
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(from), path)
+	}
+	path = filepath.Clean(path)
+	if contains(e.including, path) {
+		e.errorf(node, "%s includes itself through %s", path, strings.Join(e.including, " -> "))
+		return sceneParts{}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		e.errorf(node, "failed to include %s: %v", node.Value, err)
+		return sceneParts{}
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		e.errorf(node, "failed to include %s: %v", node.Value, err)
+		return sceneParts{}
+	}
+	if !contains(e.files, path) {
+		e.files = append(e.files, path)
+	}
+	if len(doc.Content) == 0 {
+		return sceneParts{}
+	}
+	root := doc.Content[0]
+	e.mark(root, path)
+	if root.Kind != yaml.MappingNode {
+		e.errorf(root, "included file must be a mapping")
+		return sceneParts{}
+	}
+
+	e.including = append(e.including, path)
+	defer func() { e.including = e.including[:len(e.including)-1] }()
+	return e.load(path, root, true)
+}
+
+// defineComponents registers components, a later definition replaces an
+// earlier one with the same name.
+func (e *expander) defineComponents(node *yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		e.errorf(node, "components must be a mapping")
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		name, definition := node.Content[i], node.Content[i+1]
+		if definition.Kind != yaml.MappingNode {
+			e.errorf(definition, "component %s must be a mapping", name.Value)
+			continue
+		}
+		c := &component{}
+		for j := 0; j+1 < len(definition.Content); j += 2 {
+			key, value := definition.Content[j], definition.Content[j+1]
+			switch key.Value {
+			case "params":
+				if value.Kind != yaml.MappingNode {
+					e.errorf(value, "component params must be a mapping")
+					continue
+				}
+				c.params = value
+			case "objects":
+				c.objects = e.list(value, "component objects")
+			default:
+				e.errorf(key, "unknown component key %q, expected one of %s", key.Value, strings.Join(componentKeys, ", "))
+			}
+		}
+		e.components[name.Value] = c
+	}
+}
+
+// instantiate copies objects with params substituted, replacing component
+// instances by the component objects named <instance>.<object>.
+func (e *expander) instantiate(objects []*yaml.Node, prefix string, params map[string]*yaml.Node, depth int) []*yaml.Node {
+	var expanded []*yaml.Node
+	for _, object := range objects {
+		instance := mappingValue(object, "component")
+		if instance == nil {
+			copied := e.substituteObject(object, params)
+			if name := mappingValue(copied, "name"); name != nil && prefix != "" {
+				name.Value = prefix + name.Value
+			}
+			// Group children may be component instances too.
+			if children := mappingValue(object, "objects"); children != nil && children.Kind == yaml.SequenceNode {
+				setMappingValue(copied, "objects", sequenceNode(e.instantiate(children.Content, prefix, params, depth)))
+			}
+			expanded = append(expanded, copied)
+			continue
+		}
+
+		if depth >= maxComponentDepth {
+			e.errorf(instance, "components nest more than %d levels deep", maxComponentDepth)
+			continue
+		}
+		c, ok := e.components[instance.Value]
+		if !ok {
+			e.errorf(instance, "unknown component %q", instance.Value)
+			continue
+		}
+		for i := 0; i+1 < len(object.Content); i += 2 {
+			if key := object.Content[i]; !contains(instanceKeys, key.Value) {
+				e.errorf(key, "unknown component instance key %q, expected one of %s", key.Value, strings.Join(instanceKeys, ", "))
+			}
+		}
+		values := e.params(object, c, params)
+		if values == nil {
+			continue
+		}
+		name := instance.Value
+		if n := mappingValue(object, "name"); n != nil {
+			name = n.Value
+		}
+		expanded = append(expanded, e.instantiate(c.objects, prefix+name+".", values, depth+1)...)
+	}
+	return expanded
+}
+
+// params resolves the params of a component instance, given values replace
+// the defaults and may use the params of the enclosing component.
+func (e *expander) params(object *yaml.Node, c *component, outer map[string]*yaml.Node) map[string]*yaml.Node {
+	values := make(map[string]*yaml.Node)
+	if c.params != nil {
+		for i := 0; i+1 < len(c.params.Content); i += 2 {
+			if value := c.params.Content[i+1]; value.Tag != "!!null" {
+				values[c.params.Content[i].Value] = value
+			}
+		}
+	}
+
+	ok := true
+	if given := mappingValue(object, "params"); given != nil {
+		if given.Kind != yaml.MappingNode {
+			e.errorf(given, "component params must be a mapping")
+			return nil
+		}
+		for i := 0; i+1 < len(given.Content); i += 2 {
+			key, value := given.Content[i], given.Content[i+1]
+			if c.params == nil || mappingValue(c.params, key.Value) == nil {
+				e.errorf(key, "unknown param %q of component %s", key.Value, mappingValue(object, "component").Value)
+				ok = false
+				continue
+			}
+			values[key.Value] = e.substitute(value, outer, nil, false)
+		}
+	}
+	if c.params != nil {
+		for i := 0; i+1 < len(c.params.Content); i += 2 {
+			if _, set := values[c.params.Content[i].Value]; !set {
+				e.errorf(object, "component %s is missing param %q", mappingValue(object, "component").Value, c.params.Content[i].Value)
+				ok = false
+			}
+		}
+	}
+	if !ok {
+		return nil
+	}
+	return values
+}
+
+// substituteObject deep copies an object replacing params in the
+// expressions of its properties, the literal ones of its type excepted.
+func (e *expander) substituteObject(node *yaml.Node, params map[string]*yaml.Node) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return e.substitute(node, params, nil, false)
+	}
+	copied := *node
+	e.origins[&copied] = e.origins[node]
+	var schema map[string]propertySpec
+	if objectType := mappingValue(node, "type"); objectType != nil {
+		schema = objectSchemas[objectType.Value]
+	}
+	copied.Content = make([]*yaml.Node, len(node.Content))
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		copied.Content[i] = key
+		switch key.Value {
+		case "name", "type":
+			verbatim := *value
+			e.origins[&verbatim] = e.origins[value]
+			copied.Content[i+1] = &verbatim
+		case "properties":
+			copied.Content[i+1] = e.substitute(value, params, schema, false)
+		default:
+			copied.Content[i+1] = e.substitute(value, params, nil, false)
+		}
+	}
+	return &copied
+}
+
+// substitute deep copies node replacing params in expressions, schema tells
+// which keys of its mappings are literal. A value that is just a param,
+// literal properties included, becomes the param value as written.
+func (e *expander) substitute(node *yaml.Node, params map[string]*yaml.Node, schema map[string]propertySpec, literal bool) *yaml.Node {
+	copied := *node
+	e.origins[&copied] = e.origins[node]
+	switch node.Kind {
+	case yaml.MappingNode:
+		copied.Content = make([]*yaml.Node, len(node.Content))
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			copied.Content[i] = key
+			spec, _, _ := lookupProperty(schema, key.Value)
+			copied.Content[i+1] = e.substitute(node.Content[i+1], params, spec.nested(), spec.literal())
+		}
+	case yaml.SequenceNode:
+		copied.Content = make([]*yaml.Node, len(node.Content))
+		for i, item := range node.Content {
+			copied.Content[i] = e.substitute(item, params, schema, literal)
+		}
+	case yaml.ScalarNode:
+		if len(params) == 0 || node.Tag != "!!str" {
+			break
+		}
+		if value, ok := params[node.Value]; ok && value.Kind == yaml.ScalarNode {
+			copied.Tag, copied.Value, copied.Style = value.Tag, value.Value, value.Style
+			break
+		}
+		if !literal {
+			copied.Value = substituteParams(node.Value, params)
+		}
+	}
+	return &copied
+}
+
+// substituteParams replaces the identifiers of an expression naming params
+// by their parenthesized values, hex colors become string literals.
+func substituteParams(expression string, params map[string]*yaml.Node) string {
+	if _, ok := ParseHexColor(expression); ok {
+		return expression
+	}
+	tokens, err := lexer.Lex(file.NewSource(expression))
+	if err != nil {
+		return expression
+	}
+	source := []rune(expression)
+	var b strings.Builder
+	last := 0
+	for idx, token := range tokens {
+		if token.Kind != lexer.Identifier {
+			continue
+		}
+		value, ok := params[token.Value]
+		if !ok || value.Kind != yaml.ScalarNode {
+			continue
+		}
+		// Skip member accesses like point.side.
+		if idx > 0 && tokens[idx-1].Kind == lexer.Operator && (tokens[idx-1].Value == "." || tokens[idx-1].Value == "?.") {
+			continue
+		}
+		replacement := "(" + value.Value + ")"
+		if _, isColor := ParseHexColor(value.Value); isColor {
+			replacement = fmt.Sprintf("%q", value.Value)
+		}
+		b.WriteString(string(source[last:token.From]))
+		b.WriteString(replacement)
+		last = token.To
+	}
+	if last == 0 {
+		return expression
+	}
+	b.WriteString(string(source[last:]))
+	return b.String()
+}
+
+// mark records the file every node of a document comes from.
+func (e *expander) mark(node *yaml.Node, filePath string) {
+	e.origins[node] = filePath
+	for _, child := range node.Content {
+		e.mark(child, filePath)
+	}
+}
+
+// mergeEnv builds the env mapping, later keys override earlier ones.
+func mergeEnv(pairs []*yaml.Node) *yaml.Node {
+	if len(pairs) == 0 {
+		return nil
+	}
+	env := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	index := make(map[string]int)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		key, value := pairs[i], pairs[i+1]
+		if at, ok := index[key.Value]; ok {
+			env.Content[at+1] = value
+			continue
+		}
+		index[key.Value] = len(env.Content)
+		env.Content = append(env.Content, key, value)
+	}
+	return env
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func setMappingValue(node *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			value.Line, value.Column = node.Content[i+1].Line, node.Content[i+1].Column
+			node.Content[i+1] = value
+			return
+		}
+	}
+}
+
+func scalarNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+func sequenceNode(content []*yaml.Node) *yaml.Node {
+	return &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: content}
+}
@@ -0,0 +1,205 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func writeSceneFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+const featuresFile = `env:
+  offset: 10
+  size: 1
+components:
+  dot:
+    params:
+      side: 1
+      color: "#ffffff"
+    objects:
+      - name: dot
+        type: circle
+        properties:
+          x: 50-side*offset
+          y: point.side
+          radius: size
+          color: color
+  pair:
+    params:
+      gap:
+    objects:
+      - name: left
+        component: dot
+        params:
+          side: -gap
+      - name: right
+        component: dot
+        params:
+          side: gap
+`
+
+func TestExpandScene(t *testing.T) {
+	dir := writeSceneFiles(t, map[string]string{
+		"lib/features.yml": featuresFile,
+		"scene.yml": `version: 1
+include:
+  - lib/features.yml
+frame:
+  width: 100
+  height: 100
+env:
+  size: 3
+  point: {side: 7}
+objects:
+  - name: eyes
+    component: pair
+    params:
+      gap: 2
+  - name: red
+    component: dot
+    params:
+      color: "#ff0000"
+`,
+	})
+	scene, err := ReadScene(filepath.Join(dir, "scene.yml"))
+	if err != nil {
+		t.Fatalf("Failed to load scene: %v", err)
+	}
+
+	if scene.Env["size"] != 3 || scene.Env["offset"] != 10 {
+		t.Errorf("Expected the scene env to override the included one, got %v", scene.Env)
+	}
+	expected := []struct {
+		name  string
+		x     string
+		color string
+	}{
+		{"eyes.left.dot", "50-(-(2))*offset", "#ffffff"},
+		{"eyes.right.dot", "50-(2)*offset", "#ffffff"},
+		{"red.dot", "50-(1)*offset", "#ff0000"},
+	}
+	if len(scene.Objects) != len(expected) {
+		t.Fatalf("Expected %d objects, got %d", len(expected), len(scene.Objects))
+	}
+	for i, e := range expected {
+		object := scene.Objects[i]
+		if object.Name != e.name || object.Properties["x"] != e.x || object.Properties["color"] != e.color {
+			t.Errorf("Expected %s with x %q and color %s, got %s with %v", e.name, e.x, e.color, object.Name, object.Properties)
+		}
+		if object.Properties["y"] != "point.side" {
+			t.Errorf("Expected member accesses to be left alone, got %v", object.Properties["y"])
+		}
+	}
+	x, err := scene.evaluate(scene.Objects[0].Properties["x"].(string))
+	if err != nil || x != 70 {
+		t.Errorf("Expected the left dot at 70, got %v (%v)", x, err)
+	}
+
+	files := scene.Files()
+	if len(files) != 2 || files[1] != filepath.Join(dir, "lib", "features.yml") {
+		t.Errorf("Expected the included file to be watched, got %v", files)
+	}
+}
+
+func TestExpandSceneErrors(t *testing.T) {
+	dir := writeSceneFiles(t, map[string]string{
+		"lib/features.yml": featuresFile + `objects:
+  - name: broken
+    type: circle
+    properties:
+      x: offset +
+      y: 1
+      radius: 1
+`,
+		"loop.yml": "include:\n  - scene.yml\n",
+		"scene.yml": `version: 1
+include:
+  - lib/features.yml
+  - loop.yml
+frame:
+  width: 100
+  height: 100
+objects:
+  - name: missing
+    component: pair
+  - name: unknown
+    component: nose
+`,
+	})
+	path := filepath.Join(dir, "scene.yml")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read scene: %v", err)
+	}
+
+	err = ValidateScene(path, data)
+	var validationErrors ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		t.Fatalf("Expected validation errors, got %v", err)
+	}
+	expected := []string{
+		path + ":9:5: component pair is missing param \"gap\"",
+		path + ":12:16: unknown component \"nose\"",
+		filepath.Join(dir, "loop.yml") + ":2:5: " + path + " includes itself through " + path + " -> " + filepath.Join(dir, "loop.yml"),
+	}
+	if len(validationErrors) != len(expected) {
+		t.Fatalf("Expected %d errors, got %d:\n%v", len(expected), len(validationErrors), err)
+	}
+	// Errors are sorted by file name, the scene comes after loop.yml.
+	got := map[string]bool{}
+	for _, e := range validationErrors {
+		got[e.Error()] = true
+	}
+	for _, message := range expected {
+		if !got[message] {
+			t.Errorf("Expected %q in:\n%v", message, err)
+		}
+	}
+
+	// Once expanded, errors in included files point into them.
+	if err := os.WriteFile(path, []byte("version: 1\ninclude: [lib/features.yml]\nframe: {width: 1, height: 1}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write scene: %v", err)
+	}
+	data, _ = os.ReadFile(path)
+	err = ValidateScene(path, data)
+	message := filepath.Join(dir, "lib", "features.yml") + `:33:10: invalid expression "offset +": unexpected token EOF (1:8)`
+	if !errors.As(err, &validationErrors) || len(validationErrors) != 1 || validationErrors[0].Error() != message {
+		t.Errorf("Expected %q, got %v", message, err)
+	}
+}
+
+func TestSubstituteParams(t *testing.T) {
+	params := map[string]*yaml.Node{
+		"side":  {Kind: yaml.ScalarNode, Tag: "!!int", Value: "-1"},
+		"width": {Kind: yaml.ScalarNode, Tag: "!!str", Value: "a + b"},
+		"tint":  {Kind: yaml.ScalarNode, Tag: "!!str", Value: "#ff0000"},
+	}
+	cases := map[string]string{
+		"x - side*width":     "x - (-1)*(a + b)",
+		"sides + side":       "sides + (-1)",
+		"p.side + side":      "p.side + (-1)",
+		"ok ? tint : 'x'":    `ok ? "#ff0000" : 'x'`,
+		"'side' + string(1)": "'side' + string(1)",
+	}
+	for expression, want := range cases {
+		if got := substituteParams(expression, params); got != want {
+			t.Errorf("Expected %q to become %q, got %q", expression, want, got)
+		}
+	}
+}
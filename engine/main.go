@@ -1,13 +1,17 @@
 package engine
 
 import (
+	"context"
 	"einclient/engine/objects"
+	"einclient/watch"
 	"encoding/json"
 	"fmt"
+	"math/rand"
 	"os"
 	"time"
 
 	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
 	"github.com/fogleman/gg"
 	"gopkg.in/yaml.v3"
 )
@@ -19,114 +23,371 @@ const (
 	ObjectTypeLine          = "line"
 	ObjectTypeSimplePolygon = "simple"
 	ObjectTypePolygon       = "polygon"
+	ObjectTypeText          = "text"
+	ObjectTypeImage         = "image"
+	ObjectTypeGroup         = "group"
+	ObjectTypePath          = "path"
 )
 
 type Scene struct {
 	Version    string                 `yaml:"version"`
+	Seed       *int64                 `yaml:"seed"`
 	Env        map[string]interface{} `yaml:"env"`
 	Frame      Frame                  `yaml:"frame"`
 	Objects    []ObjectWrapper        `yaml:"objects"`
 	Animations []AnimationWrapper     `yaml:"animations"`
+	// StateMachine switches animations between named states.
+	StateMachine *StateMachine `yaml:"stateMachine"`
+
+	rand      *rand.Rand
+	programs  map[string]*vm.Program
+	started   bool
+	startedAt time.Time
+	elapsed   time.Duration
+	files     []string
+
+	background *ObjectWrapper
+	layer      *gg.Context
+	drawn      bool
+	lastFrame  time.Time
+
+	sequenced  bool
+	state      string
+	transition *Transition
 }
 
 type Frame struct {
 	Width  int `yaml:"width"`
 	Height int `yaml:"height"`
+	// Background is painted under the objects, a color expression, a
+	// gradient or an image mapping with src. Black when empty.
+	Background interface{} `yaml:"background"`
+	// Clear is one of the ClearMode constants, ClearModeClear when empty.
+	Clear string `yaml:"clear"`
+	// Decay is how much of the previous frame is left after a second with
+	// ClearModeFade, an expression.
+	Decay string `yaml:"decay"`
 }
 
 type ObjectWrapper struct {
 	Name       string                 `yaml:"name"`
 	Type       string                 `yaml:"type"`
 	Properties map[string]interface{} `yaml:"properties"`
-	Object     objects.Renderable
+	// Objects are the children of a group.
+	Objects []ObjectWrapper `yaml:"objects"`
+	Object  objects.Renderable
 }
 
 type AnimationWrapper struct {
-	Name      string            `yaml:"name"`
-	Duration  string            `yaml:"duration"`
-	Repeat    string            `yaml:"repeat"`
-	Delay     string            `yaml:"delay"`
-	Keyframes []KeyframeWrapper `yaml:"keyframes"`
-	PlayedAt  int64
+	Name       string            `yaml:"name"`
+	Duration   string            `yaml:"duration"`
+	Repeat     string            `yaml:"repeat"`
+	Delay      string            `yaml:"delay"`
+	Easing     string            `yaml:"easing"`
+	ColorSpace string            `yaml:"colorSpace"`
+	Keyframes  []KeyframeWrapper `yaml:"keyframes"`
+	// After starts the animation when the named one finishes.
+	After string `yaml:"after"`
+	// When starts the animation every time the expression turns true, a
+	// repeating animation stops when it turns false again.
+	When string `yaml:"when"`
+	// Parallel makes the animation a group without keyframes that plays the
+	// named animations together. It finishes once all of them have.
+	Parallel []string `yaml:"parallel"`
+	// Autoplay starts the animation with the scene. It defaults to true for
+	// animations nothing else starts, see autoplays.
+	Autoplay *bool `yaml:"autoplay"`
+
+	state animationState
+	// whenWas is the value of When on the previous frame.
+	whenWas bool
+	// curve and interpolateColor are Easing and ColorSpace parsed by resolve.
+	curve            EasingFunc
+	interpolateColor ColorInterpolator
+	resolved         bool
+}
+
+// animationState is what an animation remembers between frames.
+type animationState struct {
+	playing  bool
+	started  bool
+	playedAt time.Time
+	delay    time.Duration
+	finished bool
+	repeat   bool
 }
 
 type KeyframeWrapper struct {
 	Time       float64                `yaml:"time"`
+	Easing     string                 `yaml:"easing"`
 	Properties map[string]interface{} `yaml:"properties"`
+
+	// curve is Easing parsed by resolve, nil without one.
+	curve EasingFunc
 }
 
-func (a *AnimationWrapper) getKeyframe(t int64) (int, *KeyframeWrapper) {
-	for idx, keyframe := range a.Keyframes {
-		if t-a.PlayedAt <= int64(keyframe.Time*float64(time.Second)) {
-			return idx, &keyframe
+// easing returns the curve used for the segment that starts at this keyframe,
+// falling back to the animation-wide easing like CSS keyframes do.
+func (kf *KeyframeWrapper) easing(animation *AnimationWrapper) EasingFunc {
+	if kf.curve != nil {
+		return kf.curve
+	}
+	return animation.curve
+}
+
+// resolve parses the easings and the color space of the animation, once:
+// Compile resolves them at load and uncompiled scenes on the first frame.
+func (a *AnimationWrapper) resolve() error {
+	if a.resolved {
+		return nil
+	}
+	var err error
+	if a.curve, err = ParseEasing(a.Easing); err != nil {
+		return err
+	}
+	if a.interpolateColor, err = ParseColorSpace(a.ColorSpace); err != nil {
+		return err
+	}
+	for i := range a.Keyframes {
+		kf := &a.Keyframes[i]
+		if kf.Easing == "" {
+			continue
+		}
+		if kf.curve, err = ParseEasing(kf.Easing); err != nil {
+			return err
 		}
 	}
-	return -1, nil
+	a.resolved = true
+	return nil
 }
-func (scene *Scene) ComputeAnimations() error {
-	var t int64 = time.Now().UnixNano()
-	for _, animation := range scene.Animations {
-		duration, err := EvaluateExpression(animation.Duration, scene.Env)
+
+// segment returns the keyframes surrounding elapsed seconds and the linear
+// progress between them. Once the last keyframe is passed it is returned a
+// single time as both ends so its values land exactly.
+func (a *AnimationWrapper) segment(elapsed float64) (*KeyframeWrapper, *KeyframeWrapper, float64) {
+	if len(a.Keyframes) == 0 || elapsed < a.Keyframes[0].Time {
+		return nil, nil, 0
+	}
+	for idx := 1; idx < len(a.Keyframes); idx++ {
+		prev, next := &a.Keyframes[idx-1], &a.Keyframes[idx]
+		if elapsed < next.Time {
+			return prev, next, (elapsed - prev.Time) / (next.Time - prev.Time)
+		}
+	}
+	if a.state.finished {
+		return nil, nil, 0
+	}
+	a.state.finished = true
+	last := &a.Keyframes[len(a.Keyframes)-1]
+	return last, last, 1
+}
+
+// duration is the length of one cycle, an empty duration lasts until the
+// last keyframe.
+func (a *AnimationWrapper) duration(scene *Scene) (time.Duration, error) {
+	if a.Duration == "" {
+		if len(a.Keyframes) == 0 {
+			return 0, nil
+		}
+		return seconds(a.Keyframes[len(a.Keyframes)-1].Time), nil
+	}
+	return scene.evaluateSeconds(a.Duration)
+}
+
+// advance starts the animation on its first frame and, for repeating
+// animations, rolls playedAt over every cycle that ended before now.
+func (a *AnimationWrapper) advance(scene *Scene, now time.Time) error {
+	duration, err := a.duration(scene)
+	if err != nil {
+		return err
+	}
+	repeat := false
+	if a.Repeat != "" {
+		value, err := scene.evaluate(a.Repeat)
 		if err != nil {
-			continue
+			return err
 		}
-		repeat, err := EvaluateExpression(animation.Repeat, scene.Env)
+		repeat = value == true
+	}
+
+	if !a.state.started {
+		delay, err := scene.evaluateSeconds(a.Delay)
 		if err != nil {
-			continue
+			return err
 		}
-		delay, err := EvaluateExpression(animation.Delay, scene.Env)
+		a.state = animationState{playing: true, started: true, playedAt: now, delay: delay}
+	}
+	a.state.repeat = repeat
+	if !repeat {
+		return nil
+	}
+	for cycle := duration + a.state.delay; cycle > 0 && !now.Before(a.state.playedAt.Add(cycle)); cycle = duration + a.state.delay {
+		delay, err := scene.evaluateSeconds(a.Delay)
 		if err != nil {
+			return err
+		}
+		a.state.playedAt = a.state.playedAt.Add(cycle)
+		a.state.delay = delay
+		a.state.finished = false
+	}
+	return nil
+}
+
+// ComputeAnimations starts and stops animations, see sequence, then writes the
+// values of every playing animation at now into the scene env.
+func (scene *Scene) ComputeAnimations(now time.Time) error {
+	scene.sequence()
+	for i := range scene.Animations {
+		animation := &scene.Animations[i]
+		if !animation.state.playing || len(animation.Parallel) > 0 {
+			continue
+		}
+		if err := animation.resolve(); err != nil {
 			continue
 		}
-		startAt := animation.PlayedAt + int64(duration.(float64)*float64(time.Second)) + int64(delay.(float64)*float64(time.Second))
-		if animation.PlayedAt == 0 || (repeat == true && startAt < t) {
-			animation.PlayedAt = t
+		if err := animation.advance(scene, now); err != nil {
+			continue
 		}
-		idx, kf := animation.getKeyframe(t)
+		prevKeyframe, kf, progress := animation.segment(now.Sub(animation.state.playedAt).Seconds())
 		if kf == nil {
 			continue
 		}
-		prevKeyframe := animation.Keyframes[idx-1]
+		progress = prevKeyframe.easing(animation)(clamp01(progress))
 		for key, targetValue := range kf.Properties {
-			var value interface{}
-			if prevKeyframe.Properties[key] != nil {
-				prevValue := prevKeyframe.Properties[key]
-				if prevValue != nil {
-					if targetValue == nil {
-						targetValue = prevValue
-					}
-					prevDurations := time.Duration(0)
-					for _, pkf := range animation.Keyframes[:idx] {
-						prevDurations += time.Duration(pkf.Time * float64(time.Second))
-					}
-					switch prevValueTyped := prevValue.(type) {
-					case float64:
-						kfDuration := time.Duration((kf.Time - prevKeyframe.Time) * float64(time.Second))
-						elapsed := time.Duration(t - animation.PlayedAt - prevDurations.Nanoseconds())
-						progress := float64(elapsed) / float64(kfDuration)
-						value = prevValueTyped + progress*(targetValue.(float64)-prevValueTyped)
-					case int:
-						kfDuration := time.Duration((kf.Time - prevKeyframe.Time) * float64(time.Second))
-						elapsed := time.Duration(t - animation.PlayedAt - prevDurations.Nanoseconds())
-						progress := float64(elapsed) / float64(kfDuration)
-						prevValueFloat := float64(prevValueTyped)
-						targetValueFloat := float64(targetValue.(int))
-						value = int(prevValueFloat + progress*(targetValueFloat-prevValueFloat))
-					case string:
-						// String interpolation isn't straightforward; leaving this as-is or handling it differently
-						value = targetValue.(string)
-					}
-				}
-			} else {
-				value = targetValue
+			prevValue, ok := prevKeyframe.Properties[key]
+			if !ok || prevValue == nil {
+				scene.Env[key] = targetValue
+				continue
 			}
-			scene.Env[key] = value
+			if targetValue == nil {
+				targetValue = prevValue
+			}
+			scene.Env[key] = interpolateValue(prevValue, targetValue, progress, animation.interpolateColor)
+		}
+		if animation.state.finished && !animation.state.repeat {
+			scene.finish(animation)
 		}
 	}
 	return nil
 }
 
-func (wrapper *ObjectWrapper) Render(ctx *gg.Context, env map[string]interface{}) error {
+// interpolateValue blends two keyframe values. Numbers are interpolated
+// (staying ints when both ends are ints), colors are blended, shapes are
+// morphed and anything else snaps to the target.
+func interpolateValue(prevValue, targetValue interface{}, progress float64, interpolateColor ColorInterpolator) interface{} {
+	if shape, ok := morph(prevValue, targetValue, progress); ok {
+		return shape
+	}
+	switch prevValueTyped := prevValue.(type) {
+	case string:
+		// Only colors can be blended, any other string snaps to the target.
+		if target, ok := targetValue.(string); ok {
+			if color, ok := InterpolateColor(prevValueTyped, target, progress, interpolateColor); ok {
+				return color
+			}
+		}
+		return targetValue
+	}
+
+	from, ok := toFloat(prevValue)
+	if !ok {
+		return targetValue
+	}
+	to, ok := toFloat(targetValue)
+	if !ok {
+		return targetValue
+	}
+	value := from + progress*(to-from)
+	_, prevInt := prevValue.(int)
+	_, targetInt := targetValue.(int)
+	if prevInt && targetInt {
+		return int(value)
+	}
+	return value
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// functions are the helpers available to every scene expression on top of
+// the expr builtins.
+func (scene *Scene) functions() []expr.Option {
+	return []expr.Option{
+		expr.Function("random", func(params ...any) (any, error) {
+			min, max := params[0].(float64), params[1].(float64)
+			return min + scene.random().Float64()*(max-min), nil
+		}, new(func(float64, float64) float64)),
+	}
+}
+
+// random is seeded from the scene's seed when set, so a scene renders the
+// same frames on every run.
+func (scene *Scene) random() *rand.Rand {
+	if scene.rand == nil {
+		seed := time.Now().UnixNano()
+		if scene.Seed != nil {
+			seed = *scene.Seed
+		}
+		scene.rand = rand.New(rand.NewSource(seed))
+	}
+	return scene.rand
+}
+
+// evaluate runs an expression against the scene env, using the compiled
+// program when the scene has been compiled.
+func (scene *Scene) evaluate(expression string) (interface{}, error) {
+	if scene.programs == nil {
+		return EvaluateExpression(expression, scene.Env, scene.functions()...)
+	}
+	program, err := scene.compile(expression)
+	if err != nil {
+		return nil, err
+	}
+	return expr.Run(program, scene.Env)
+}
+
+// evaluateSeconds evaluates an expression in seconds, empty is zero.
+func (scene *Scene) evaluateSeconds(expression string) (time.Duration, error) {
+	if expression == "" {
+		return 0, nil
+	}
+	value, err := scene.evaluate(expression)
+	if err != nil {
+		return 0, err
+	}
+	s, ok := toFloat(value)
+	if !ok {
+		return 0, fmt.Errorf("expected a number of seconds, got %v", value)
+	}
+	return seconds(s), nil
+}
+
+func (wrapper *ObjectWrapper) Render(ctx *gg.Context, scene *Scene) error {
+	if err := wrapper.build(scene); err != nil {
+		return err
+	}
+	wrapper.Object.Render(ctx)
+	return nil
+}
+
+// build evaluates the properties of the object, and of its children for
+// groups, into wrapper.Object.
+func (wrapper *ObjectWrapper) build(scene *Scene) error {
 
 	typeConstructorMap := map[string]func() objects.Renderable{
 		ObjectTypeCircle:    func() objects.Renderable { return new(objects.Circle) },
@@ -137,8 +398,15 @@ func (wrapper *ObjectWrapper) Render(ctx *gg.Context, env map[string]interface{}
 		ObjectTypeSimplePolygon: func() objects.Renderable {
 			return new(objects.SimplePolygon)
 		},
+		ObjectTypeText:  func() objects.Renderable { return new(objects.Text) },
+		ObjectTypeImage: func() objects.Renderable { return new(objects.Image) },
+		ObjectTypeGroup: func() objects.Renderable { return new(objects.Group) },
+		ObjectTypePath:  func() objects.Renderable { return new(objects.Path) },
+	}
+	computedProperties, err := processProperties(wrapper.Properties, objectSchemas[wrapper.Type], scene.evaluate)
+	if err != nil {
+		return err
 	}
-	computedProperties, err := Process(wrapper.Properties, env)
 	data, err := json.Marshal(computedProperties)
 	if err != nil {
 		return err
@@ -152,7 +420,18 @@ func (wrapper *ObjectWrapper) Render(ctx *gg.Context, env map[string]interface{}
 	if err := unmarshalObject(data, &wrapper.Object, constructor()); err != nil {
 		return err
 	}
-	wrapper.Object.Render(ctx)
+	if clocked, ok := wrapper.Object.(objects.Clocked); ok {
+		clocked.SetElapsed(scene.elapsed)
+	}
+	if group, ok := wrapper.Object.(*objects.Group); ok {
+		for i := range wrapper.Objects {
+			child := &wrapper.Objects[i]
+			if err := child.build(scene); err != nil {
+				return fmt.Errorf("%s: %w", child.Name, err)
+			}
+			group.Children = append(group.Children, child.Object)
+		}
+	}
 	return nil
 }
 
@@ -164,8 +443,8 @@ func unmarshalObject(data []byte, target *objects.Renderable, obj objects.Render
 	return nil
 }
 
-func EvaluateExpression(expression string, variables map[string]interface{}) (interface{}, error) {
-	program, err := expr.Compile(expression)
+func EvaluateExpression(expression string, variables map[string]interface{}, options ...expr.Option) (interface{}, error) {
+	program, err := expr.Compile(expression, options...)
 	if err != nil {
 		return nil, err
 	}
@@ -177,16 +456,37 @@ func EvaluateExpression(expression string, variables map[string]interface{}) (in
 	return output, nil
 }
 
+// Process evaluates the properties of an object against env.
 func Process(obj map[string]interface{}, env map[string]interface{}) (map[string]interface{}, error) {
+	return processProperties(obj, nil, func(expression string) (interface{}, error) {
+		return EvaluateExpression(expression, env)
+	})
+}
+
+// processProperties evaluates properties, those schema says are literal are
+// taken as written.
+func processProperties(obj map[string]interface{}, schema map[string]propertySpec, evaluate func(string) (interface{}, error)) (map[string]interface{}, error) {
 	var err error
 	var res interface{}
 	computed := make(map[string]interface{})
 	for key, value := range obj {
+		spec, _, _ := lookupProperty(schema, key)
 		switch key {
 		case "points":
+			if expression, ok := value.(string); ok {
+				// A point list from the env, like a morphing shape.
+				if value, err = evaluate(expression); err != nil {
+					fmt.Printf("Error processing expression %v\n", err.Error())
+					return nil, err
+				}
+			}
+			items, ok := value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("points must be a list, got %v", value)
+			}
 			var output []interface{}
-			for _, item := range value.([]interface{}) {
-				pItem, err := Process(item.(map[string]interface{}), env)
+			for _, item := range items {
+				pItem, err := processProperties(item.(map[string]interface{}), nil, evaluate)
 				if err != nil {
 					fmt.Printf("Error processing points %v\n", item)
 					return nil, err
@@ -196,15 +496,22 @@ func Process(obj map[string]interface{}, env map[string]interface{}) (map[string
 			res = output
 			break
 		case "startPoint", "endPoint":
-			res, err = Process(value.(map[string]interface{}), env)
+			res, err = processProperties(value.(map[string]interface{}), nil, evaluate)
 			if err != nil {
 				return nil, err
 			}
 			break
 		default:
-			res, err = EvaluateExpression(value.(string), env)
+			switch v := value.(type) {
+			case map[string]interface{}:
+				// Gradients and other nested values.
+				res, err = processProperties(v, spec.nested(), evaluate)
+			case []interface{}:
+				res, err = processList(v, spec.nested(), evaluate)
+			default:
+				res, err = processValue(value, spec.literal(), evaluate)
+			}
 			if err != nil {
-				fmt.Printf("Error processing expression %v\n", err.Error())
 				return nil, err
 			}
 			break
@@ -215,70 +522,123 @@ func Process(obj map[string]interface{}, env map[string]interface{}) (map[string
 	return computed, nil
 }
 
-func LoadScene(filePath string, reloadChan chan *Scene) error {
-	load := func() (*Scene, error) {
-		var data []byte
+// processList evaluates the items of a list property like dash or the stops
+// of a gradient, schema is the one of mapping items.
+func processList(items []interface{}, schema map[string]propertySpec, evaluate func(string) (interface{}, error)) ([]interface{}, error) {
+	output := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		var res interface{}
 		var err error
-		for len(data) == 0 {
-			data, err = os.ReadFile(filePath)
-			if err != nil {
-				return nil, err
-			}
+		if properties, ok := item.(map[string]interface{}); ok {
+			res, err = processProperties(properties, schema, evaluate)
+		} else {
+			res, err = processValue(item, false, evaluate)
 		}
-
-		if len(data) == 0 {
-			return nil, fmt.Errorf("file is empty")
-		}
-		var scene Scene
-		err = yaml.Unmarshal(data, &scene)
 		if err != nil {
 			return nil, err
 		}
-		return &scene, nil
+		output = append(output, res)
 	}
+	return output, nil
+}
 
-	scene, err := load()
+// processValue evaluates a single property value unless it is literal.
+func processValue(value interface{}, literal bool, evaluate func(string) (interface{}, error)) (interface{}, error) {
+	expression, ok := value.(string)
+	if !ok || literal {
+		// Plain YAML numbers and booleans don't need evaluating.
+		return value, nil
+	}
+	if _, ok := ParseHexColor(expression); ok {
+		return expression, nil
+	}
+	res, err := evaluate(expression)
 	if err != nil {
-		return err
+		fmt.Printf("Error processing expression %v\n", err.Error())
+		return nil, err
 	}
-	reloadChan <- scene
+	return res, nil
+}
 
-	go func() {
-		watcher, err := os.Stat(filePath)
-		if err != nil {
-			fmt.Println("Error watching file:", err)
-			return
+// ParseScene decodes a scene from its YAML source.
+func ParseScene(data []byte) (*Scene, error) {
+	doc, err := expandScene("", data)
+	if err != nil {
+		return nil, err
+	}
+	scene, err := decodeScene(doc.root)
+	if err != nil {
+		return nil, err
+	}
+	scene.files = doc.files
+	return scene, nil
+}
+
+func decodeScene(root *yaml.Node) (*Scene, error) {
+	var scene Scene
+	if err := root.Decode(&scene); err != nil {
+		return nil, err
+	}
+	if machine := scene.StateMachine; machine != nil && machine.Initial != "" {
+		// The state variable is set up front so expressions using it compile.
+		if scene.Env == nil {
+			scene.Env = make(map[string]interface{})
+		}
+		if _, ok := scene.Env[machine.variable()]; !ok {
+			scene.Env[machine.variable()] = machine.Initial
 		}
-		modTime := watcher.ModTime()
+	}
+	return &scene, nil
+}
 
-		for {
-			watcher, err := os.Stat(filePath)
-			if err != nil {
-				fmt.Println("Error watching file:", err)
-				continue
-			}
+// ReadScene loads, validates and compiles a scene file once, without watching
+// it for changes.
+func ReadScene(filePath string) (*Scene, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return loadScene(filePath, data)
+}
 
-			if watcher.ModTime() != modTime {
-				scene, err := load()
-				if err != nil {
-					fmt.Println("Error loading scene:", err)
-				} else {
-					fmt.Printf("Reloading scene %s\n", filePath)
-					fmt.Printf("Loaded %d objects\n", len(scene.Objects))
-					reloadChan <- scene
-					modTime = watcher.ModTime()
-				}
-			}
-		}
-	}()
+func loadScene(filePath string, data []byte) (*Scene, error) {
+	doc, err := expandScene(filePath, data)
+	if err != nil {
+		return nil, wrapFileError(filePath, err)
+	}
+	if err := validateExpanded(filePath, doc); err != nil {
+		return nil, err
+	}
+	scene, err := decodeScene(doc.root)
+	if err != nil {
+		return nil, err
+	}
+	if err := scene.Compile(); err != nil {
+		return nil, err
+	}
+	scene.files = doc.files
+	return scene, nil
+}
 
-	return nil
+// LoadScene sends the scene to reloadChan, then again every time its files
+// change, see WatchScene.
+func LoadScene(filePath string, reloadChan chan *Scene) error {
+	return WatchScene(context.Background(), filePath, reloadChan, watch.Options{})
 }
 
-func (s *Scene) Render(ctx *gg.Context) error {
-	s.ComputeAnimations()
+// Render draws the scene as it is at clock.Now().
+func (s *Scene) Render(ctx *gg.Context, clock Clock) error {
+	now := clock.Now()
+	if !s.started {
+		s.started, s.startedAt = true, now
+	}
+	s.elapsed = now.Sub(s.startedAt)
+	s.ComputeAnimations(now)
+	if err := s.clear(ctx, now); err != nil {
+		fmt.Printf("Error clearing frame: %v\n", err)
+	}
 	for _, wrapper := range s.Objects {
-		wrapper.Render(ctx, s.Env)
+		wrapper.Render(ctx, s)
 	}
 	return nil
 }
@@ -0,0 +1,327 @@
+package engine
+
+import (
+	"einclient/engine/objects"
+	"encoding/json"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/fogleman/gg"
+)
+
+// morph blends two shapes for keyframes. Shapes are point lists, lists of
+// path segments or SVG path data. Point lists are resampled to the same
+// number of points and blended point by point, the result is a point list.
+// Anything involving a path is turned into cubic curves split to the same
+// count and the result is a segment list. The second result is false when
+// either value isn't a shape.
+func morph(from, to interface{}, progress float64) (interface{}, bool) {
+	fromPoints, fromIsPoints := toPoints(from)
+	toPointList, toIsPoints := toPoints(to)
+	if fromIsPoints && toIsPoints {
+		if len(fromPoints) == 0 || len(toPointList) == 0 {
+			return to, true
+		}
+		n := len(fromPoints)
+		if len(toPointList) > n {
+			n = len(toPointList)
+		}
+		a, b := resamplePoints(fromPoints, n), resamplePoints(toPointList, n)
+		points := make([]interface{}, n)
+		for i := range points {
+			points[i] = map[string]interface{}{
+				"x": a[i].X + (b[i].X-a[i].X)*progress,
+				"y": a[i].Y + (b[i].Y-a[i].Y)*progress,
+			}
+		}
+		return points, true
+	}
+
+	fromSegments, ok := toSegments(from)
+	if !ok {
+		return nil, false
+	}
+	toSegmentList, ok := toSegments(to)
+	if !ok {
+		return nil, false
+	}
+	a, b := subpaths(fromSegments), subpaths(toSegmentList)
+	if len(a) != len(b) {
+		// Shapes with different numbers of subpaths snap to the target.
+		return encodeSegments(toSegmentList), true
+	}
+	var segments []objects.PathSegment
+	for i := range a {
+		segments = append(segments, a[i].blend(b[i], progress)...)
+	}
+	return encodeSegments(segments), true
+}
+
+// toPoints reads a list of mappings with numeric x and y.
+func toPoints(value interface{}) ([]gg.Point, bool) {
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	points := make([]gg.Point, len(items))
+	for i, item := range items {
+		point, ok := item.(map[string]interface{})
+		if !ok || point["type"] != nil {
+			return nil, false
+		}
+		x, xOK := toFloat(point["x"])
+		y, yOK := toFloat(point["y"])
+		if !xOK || !yOK {
+			return nil, false
+		}
+		points[i] = gg.Point{X: x, Y: y}
+	}
+	return points, true
+}
+
+// toSegments reads SVG path data, a list of segment mappings or a point
+// list, which is the polyline through its points.
+func toSegments(value interface{}) ([]objects.PathSegment, bool) {
+	if d, ok := value.(string); ok {
+		if _, isColor := ParseHexColor(d); isColor || strings.TrimSpace(d) == "" {
+			return nil, false
+		}
+		segments, err := objects.ParsePathData(d)
+		return segments, err == nil
+	}
+	if points, ok := toPoints(value); ok && len(points) > 0 {
+		segments := []objects.PathSegment{{Type: objects.SegmentMove, X: points[0].X, Y: points[0].Y}}
+		for _, point := range points[1:] {
+			segments = append(segments, objects.PathSegment{Type: objects.SegmentLine, X: point.X, Y: point.Y})
+		}
+		return segments, true
+	}
+	items, ok := value.([]interface{})
+	if !ok || len(items) == 0 {
+		return nil, false
+	}
+	for _, item := range items {
+		segment, ok := item.(map[string]interface{})
+		if !ok || segment["type"] == nil {
+			return nil, false
+		}
+	}
+	data, err := json.Marshal(items)
+	if err != nil {
+		return nil, false
+	}
+	var segments []objects.PathSegment
+	if err := json.Unmarshal(data, &segments); err != nil {
+		return nil, false
+	}
+	return segments, true
+}
+
+// encodeSegments turns segments back into the mappings scenes write.
+func encodeSegments(segments []objects.PathSegment) []interface{} {
+	out := make([]interface{}, len(segments))
+	for i, s := range segments {
+		segment := map[string]interface{}{"type": s.Type}
+		switch s.Type {
+		case objects.SegmentMove, objects.SegmentLine:
+			segment["x"], segment["y"] = s.X, s.Y
+		case objects.SegmentCubic:
+			segment["x1"], segment["y1"] = s.X1, s.Y1
+			segment["x2"], segment["y2"] = s.X2, s.Y2
+			segment["x"], segment["y"] = s.X, s.Y
+		case objects.SegmentQuad:
+			segment["x1"], segment["y1"] = s.X1, s.Y1
+			segment["x"], segment["y"] = s.X, s.Y
+		case objects.SegmentArc:
+			segment["rx"], segment["ry"], segment["rotation"] = s.RX, s.RY, s.Rotation
+			segment["largeArc"], segment["sweep"] = s.LargeArc, s.Sweep
+			segment["x"], segment["y"] = s.X, s.Y
+		}
+		out[i] = segment
+	}
+	return out
+}
+
+// resamplePoints adds points along the edges of a polyline until it has n,
+// spread by edge length. The original points stay where they are.
+func resamplePoints(points []gg.Point, n int) []gg.Point {
+	if len(points) >= n {
+		return points
+	}
+	if len(points) == 1 {
+		out := make([]gg.Point, n)
+		for i := range out {
+			out[i] = points[0]
+		}
+		return out
+	}
+	lengths := make([]float64, len(points)-1)
+	for i := range lengths {
+		lengths[i] = points[i].Distance(points[i+1])
+	}
+	extra := distribute(lengths, n-len(points))
+
+	out := make([]gg.Point, 0, n)
+	for i, count := range extra {
+		a, b := points[i], points[i+1]
+		out = append(out, a)
+		for j := 1; j <= count; j++ {
+			t := float64(j) / float64(count+1)
+			out = append(out, gg.Point{X: a.X + (b.X-a.X)*t, Y: a.Y + (b.Y-a.Y)*t})
+		}
+	}
+	return append(out, points[len(points)-1])
+}
+
+// distribute splits extra between items in proportion to their weights,
+// rounding by largest remainder. Without any weight they share evenly.
+func distribute(weights []float64, extra int) []int {
+	counts := make([]int, len(weights))
+	if len(weights) == 0 || extra <= 0 {
+		return counts
+	}
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+	remainders := make([]float64, len(weights))
+	given := 0
+	for i, w := range weights {
+		share := float64(extra) / float64(len(weights))
+		if total > 0 {
+			share = float64(extra) * w / total
+		}
+		counts[i] = int(math.Floor(share))
+		remainders[i] = share - float64(counts[i])
+		given += counts[i]
+	}
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return remainders[order[i]] > remainders[order[j]]
+	})
+	for i := 0; given < extra; i = (i + 1) % len(order) {
+		counts[order[i]]++
+		given++
+	}
+	return counts
+}
+
+// subpath is a run of cubic curves from a move, closed or not.
+type subpath struct {
+	start  gg.Point
+	curves []objects.PathSegment
+	closed bool
+}
+
+func subpaths(segments []objects.PathSegment) []subpath {
+	var out []subpath
+	for _, s := range objects.CubicSegments(segments) {
+		switch s.Type {
+		case objects.SegmentMove:
+			out = append(out, subpath{start: gg.Point{X: s.X, Y: s.Y}})
+		case objects.SegmentCubic:
+			out[len(out)-1].curves = append(out[len(out)-1].curves, s)
+		case objects.SegmentClose:
+			out[len(out)-1].closed = true
+		}
+	}
+	return out
+}
+
+// blend moves every control point of p towards the matching one of target,
+// after splitting curves so both have as many.
+func (p subpath) blend(target subpath, progress float64) []objects.PathSegment {
+	n := len(p.curves)
+	if len(target.curves) > n {
+		n = len(target.curves)
+	}
+	a, b := p.split(n), target.split(n)
+	lerp := func(from, to float64) float64 {
+		return from + (to-from)*progress
+	}
+
+	out := []objects.PathSegment{{
+		Type: objects.SegmentMove,
+		X:    lerp(p.start.X, target.start.X),
+		Y:    lerp(p.start.Y, target.start.Y),
+	}}
+	for i := range a {
+		out = append(out, objects.PathSegment{
+			Type: objects.SegmentCubic,
+			X1:   lerp(a[i].X1, b[i].X1),
+			Y1:   lerp(a[i].Y1, b[i].Y1),
+			X2:   lerp(a[i].X2, b[i].X2),
+			Y2:   lerp(a[i].Y2, b[i].Y2),
+			X:    lerp(a[i].X, b[i].X),
+			Y:    lerp(a[i].Y, b[i].Y),
+		})
+	}
+	closed := p.closed
+	if progress >= 0.5 {
+		closed = target.closed
+	}
+	if closed {
+		out = append(out, objects.PathSegment{Type: objects.SegmentClose})
+	}
+	return out
+}
+
+// split cuts the curves into n, the longest curves being cut the most. A
+// subpath without curves becomes n curves standing still at its start.
+func (p subpath) split(n int) []objects.PathSegment {
+	if len(p.curves) >= n {
+		return p.curves
+	}
+	if len(p.curves) == 0 {
+		still := lineCubicAt(p.start)
+		out := make([]objects.PathSegment, n)
+		for i := range out {
+			out[i] = still
+		}
+		return out
+	}
+
+	lengths := make([]float64, len(p.curves))
+	current := p.start
+	for i, c := range p.curves {
+		// The control polygon is long enough a measure to share cuts.
+		lengths[i] = current.Distance(gg.Point{X: c.X1, Y: c.Y1}) +
+			gg.Point{X: c.X1, Y: c.Y1}.Distance(gg.Point{X: c.X2, Y: c.Y2}) +
+			gg.Point{X: c.X2, Y: c.Y2}.Distance(gg.Point{X: c.X, Y: c.Y})
+		current = gg.Point{X: c.X, Y: c.Y}
+	}
+	cuts := distribute(lengths, n-len(p.curves))
+
+	out := make([]objects.PathSegment, 0, n)
+	current = p.start
+	for i, c := range p.curves {
+		out = append(out, splitCubic(current, c, cuts[i]+1)...)
+		current = gg.Point{X: c.X, Y: c.Y}
+	}
+	return out
+}
+
+func lineCubicAt(p gg.Point) objects.PathSegment {
+	return objects.PathSegment{Type: objects.SegmentCubic, X1: p.X, Y1: p.Y, X2: p.X, Y2: p.Y, X: p.X, Y: p.Y}
+}
+
+// splitCubic cuts the cubic from p0 into pieces of equal parameter length
+// with de Casteljau's algorithm.
+func splitCubic(p0 gg.Point, c objects.PathSegment, pieces int) []objects.PathSegment {
+	out := make([]objects.PathSegment, 0, pieces)
+	p1, p2, p3 := gg.Point{X: c.X1, Y: c.Y1}, gg.Point{X: c.X2, Y: c.Y2}, gg.Point{X: c.X, Y: c.Y}
+	for i := pieces; i > 1; i-- {
+		// Cut off the first of the i remaining pieces.
+		t := 1 / float64(i)
+		a, b, e := p0.Interpolate(p1, t), p1.Interpolate(p2, t), p2.Interpolate(p3, t)
+		ab, be := a.Interpolate(b, t), b.Interpolate(e, t)
+		mid := ab.Interpolate(be, t)
+		out = append(out, objects.PathSegment{Type: objects.SegmentCubic, X1: a.X, Y1: a.Y, X2: ab.X, Y2: ab.Y, X: mid.X, Y: mid.Y})
+		p0, p1, p2 = mid, be, e
+	}
+	return append(out, objects.PathSegment{Type: objects.SegmentCubic, X1: p1.X, Y1: p1.Y, X2: p2.X, Y2: p2.Y, X: p3.X, Y: p3.Y})
+}
@@ -0,0 +1,118 @@
+package engine
+
+import (
+	"einclient/engine/objects"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fogleman/gg"
+)
+
+func TestResamplePoints(t *testing.T) {
+	points := []gg.Point{{X: 0, Y: 0}, {X: 30, Y: 0}, {X: 30, Y: 10}}
+	resampled := resamplePoints(points, 6)
+	expected := []gg.Point{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 20, Y: 0}, {X: 30, Y: 0}, {X: 30, Y: 5}, {X: 30, Y: 10}}
+	if !reflect.DeepEqual(resampled, expected) {
+		t.Errorf("Expected %v, got %v", expected, resampled)
+	}
+}
+
+func TestMorphPoints(t *testing.T) {
+	from := []interface{}{
+		map[string]interface{}{"x": 0, "y": 0},
+		map[string]interface{}{"x": 10, "y": 0},
+	}
+	to := []interface{}{
+		map[string]interface{}{"x": 0, "y": 10},
+		map[string]interface{}{"x": 5, "y": 20},
+		map[string]interface{}{"x": 10, "y": 10},
+	}
+	value, ok := morph(from, to, 0.5)
+	if !ok {
+		t.Fatalf("Expected point lists to morph")
+	}
+	expected := []interface{}{
+		map[string]interface{}{"x": 0.0, "y": 5.0},
+		map[string]interface{}{"x": 5.0, "y": 10.0},
+		map[string]interface{}{"x": 10.0, "y": 5.0},
+	}
+	if !reflect.DeepEqual(value, expected) {
+		t.Errorf("Expected %v, got %v", expected, value)
+	}
+}
+
+func TestMorphPaths(t *testing.T) {
+	value, ok := morph("M 0 0 L 30 0", "M 0 10 C 10 20 20 20 30 10 L 30 0 Z", 1)
+	if !ok {
+		t.Fatalf("Expected paths to morph")
+	}
+	segments, ok := toSegments(value)
+	if !ok {
+		t.Fatalf("Expected segments, got %v", value)
+	}
+	// The line is split to match the curve, the line and the way back to
+	// the start, and the end of the morph is the target itself.
+	if len(segments) != 5 || segments[0].Type != objects.SegmentMove || segments[4].Type != objects.SegmentClose {
+		t.Fatalf("Expected a move, three curves and a close, got %+v", segments)
+	}
+	last := segments[3]
+	if math.Abs(last.X) > 1e-9 || math.Abs(last.Y-10) > 1e-9 {
+		t.Errorf("Expected the closing curve to end at 0,10, got %v,%v", last.X, last.Y)
+	}
+
+	halfway, _ := morph("M 0 0 L 30 0", "M 0 10 L 30 10", 0.5)
+	expected, _ := toSegments("M 0 5 C 10 5 20 5 30 5")
+	if got, _ := toSegments(halfway); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestMorphNotShapes(t *testing.T) {
+	for _, values := range [][2]interface{}{
+		{1.0, 2.0},
+		{"#ff0000", "#0000ff"},
+		{"open", "closed"},
+	} {
+		if _, ok := morph(values[0], values[1], 0.5); ok {
+			t.Errorf("Expected %v and %v not to morph", values[0], values[1])
+		}
+	}
+}
+
+func TestComputeAnimationsMorph(t *testing.T) {
+	scene, err := ParseScene([]byte(`version: 1
+frame:
+  width: 8
+  height: 8
+env:
+  shape: []
+animations:
+  - name: grow
+    duration: "1"
+    keyframes:
+      - time: 0
+        properties:
+          shape:
+            - {x: 0, y: 0}
+            - {x: 2, y: 0}
+      - time: 1
+        properties:
+          shape:
+            - {x: 0, y: 4}
+            - {x: 4, y: 4}
+            - {x: 8, y: 4}
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse scene: %v", err)
+	}
+	start := time.Unix(0, 0)
+	scene.ComputeAnimations(start)
+	scene.ComputeAnimations(start.Add(500 * time.Millisecond))
+	shape, ok := toPoints(scene.Env["shape"])
+	expected := []gg.Point{{X: 0, Y: 2}, {X: 2.5, Y: 2}, {X: 5, Y: 2}}
+	if !ok || !reflect.DeepEqual(shape, expected) {
+		t.Errorf("Expected %v, got %v", expected, scene.Env["shape"])
+	}
+}
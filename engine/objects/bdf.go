@@ -0,0 +1,173 @@
+package objects
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// BDFFont is a bitmap font in the Glyph Bitmap Distribution Format, the
+// format of the fonts shipped with rpi-rgb-led-matrix.
+type BDFFont struct {
+	Ascent  int
+	Descent int
+	Glyphs  map[rune]*BDFGlyph
+	// Default is drawn for runes the font has no glyph for, it may be nil.
+	Default *BDFGlyph
+}
+
+// BDFGlyph is a single bitmap, Bitmap holds one row per line padded to whole
+// bytes with the leftmost pixel in the highest bit.
+type BDFGlyph struct {
+	Advance int
+	Width   int
+	Height  int
+	OffsetX int
+	OffsetY int
+	Bitmap  [][]byte
+}
+
+// Set reports whether the pixel at col, row of the bitmap is lit.
+func (g *BDFGlyph) Set(col, row int) bool {
+	line := g.Bitmap[row]
+	if col/8 >= len(line) {
+		return false
+	}
+	return line[col/8]&(0x80>>(col%8)) != 0
+}
+
+// LoadBDF reads a BDF font file.
+func LoadBDF(path string) (*BDFFont, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	font, err := ParseBDF(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return font, nil
+}
+
+// ParseBDF decodes a BDF font.
+func ParseBDF(r io.Reader) (*BDFFont, error) {
+	font := &BDFFont{Glyphs: make(map[rune]*BDFGlyph)}
+	defaultChar := -1
+	var boundingBox [4]int
+
+	var glyph *BDFGlyph
+	encoding := -1
+	inBitmap := false
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		if inBitmap {
+			if fields[0] != "ENDCHAR" {
+				row, err := hex.DecodeString(fields[0])
+				if err != nil {
+					return nil, fmt.Errorf("line %d: invalid bitmap row: %w", line, err)
+				}
+				glyph.Bitmap = append(glyph.Bitmap, row)
+				continue
+			}
+			inBitmap = false
+		}
+
+		var err error
+		switch fields[0] {
+		case "FONTBOUNDINGBOX":
+			boundingBox, err = parseInts4(fields)
+		case "FONT_ASCENT":
+			font.Ascent, err = parseInt(fields)
+		case "FONT_DESCENT":
+			font.Descent, err = parseInt(fields)
+		case "DEFAULT_CHAR":
+			defaultChar, err = parseInt(fields)
+		case "STARTCHAR":
+			glyph = &BDFGlyph{}
+			encoding = -1
+		case "ENCODING":
+			encoding, err = parseInt(fields)
+		case "DWIDTH":
+			if glyph != nil {
+				glyph.Advance, err = parseInt(fields)
+			}
+		case "BBX":
+			if glyph != nil {
+				var bbx [4]int
+				bbx, err = parseInts4(fields)
+				glyph.Width, glyph.Height, glyph.OffsetX, glyph.OffsetY = bbx[0], bbx[1], bbx[2], bbx[3]
+			}
+		case "BITMAP":
+			if glyph == nil {
+				return nil, fmt.Errorf("line %d: BITMAP outside of a glyph", line)
+			}
+			inBitmap = true
+		case "ENDCHAR":
+			if glyph != nil && encoding >= 0 {
+				font.Glyphs[rune(encoding)] = glyph
+			}
+			glyph = nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(font.Glyphs) == 0 {
+		return nil, fmt.Errorf("font has no glyphs")
+	}
+
+	// Fonts without ascent and descent properties still have a bounding box.
+	if font.Ascent == 0 && font.Descent == 0 {
+		font.Ascent = boundingBox[1] + boundingBox[3]
+		font.Descent = -boundingBox[3]
+	}
+	if defaultChar >= 0 {
+		font.Default = font.Glyphs[rune(defaultChar)]
+	}
+	return font, nil
+}
+
+// Glyph returns the glyph for r, or the default glyph.
+func (f *BDFFont) Glyph(r rune) (*BDFGlyph, bool) {
+	if g, ok := f.Glyphs[r]; ok {
+		return g, true
+	}
+	return f.Default, false
+}
+
+func parseInt(fields []string) (int, error) {
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("%s is missing a value", fields[0])
+	}
+	return strconv.Atoi(fields[1])
+}
+
+func parseInts4(fields []string) ([4]int, error) {
+	var values [4]int
+	if len(fields) < 5 {
+		return values, fmt.Errorf("%s expects 4 values", fields[0])
+	}
+	for i := range values {
+		v, err := strconv.Atoi(fields[i+1])
+		if err != nil {
+			return values, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
@@ -0,0 +1,52 @@
+package objects
+
+import (
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/fogleman/gg"
+)
+
+// ParseHexColor parses "#rgb", "#rgba", "#rrggbb" and "#rrggbbaa" colors. The
+// second result is false when s isn't a hex color.
+func ParseHexColor(s string) (color.NRGBA, bool) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "#") {
+		return color.NRGBA{}, false
+	}
+	s = s[1:]
+	if len(s) == 3 || len(s) == 4 {
+		var expanded strings.Builder
+		for _, c := range s {
+			expanded.WriteRune(c)
+			expanded.WriteRune(c)
+		}
+		s = expanded.String()
+	}
+	if len(s) == 6 {
+		s += "ff"
+	}
+	if len(s) != 8 {
+		return color.NRGBA{}, false
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, false
+	}
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, true
+}
+
+// setColor sets a hex color with its alpha scaled by opacity. Anything that
+// isn't a hex color is opaque black, like gg's SetHexColor.
+func setColor(ctx *gg.Context, hex string, opacity float64) {
+	c, ok := ParseHexColor(hex)
+	if !ok {
+		c = color.NRGBA{A: 255}
+	}
+	if opacity < 1 {
+		c.A = uint8(math.Round(float64(c.A) * math.Max(opacity, 0)))
+	}
+	ctx.SetColor(c)
+}
@@ -0,0 +1,232 @@
+package objects
+
+import (
+	"encoding/json"
+	"image/color"
+	"math"
+	"sort"
+
+	"github.com/fogleman/gg"
+)
+
+const (
+	GradientLinear = "linear"
+	GradientRadial = "radial"
+	GradientConic  = "conic"
+)
+
+// Paint is the value of a color property, a hex color or a gradient.
+type Paint struct {
+	Hex      string
+	Gradient *Gradient
+}
+
+// Hex is a Paint of a single hex color.
+func Hex(s string) Paint {
+	return Paint{Hex: s}
+}
+
+// UnmarshalJSON takes a string as a hex color and an object as a gradient.
+func (p *Paint) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '{' {
+		p.Gradient = &Gradient{}
+		return json.Unmarshal(data, p.Gradient)
+	}
+	return json.Unmarshal(data, &p.Hex)
+}
+
+// IsZero reports whether the paint isn't set.
+func (p Paint) IsZero() bool {
+	return p.Hex == "" && p.Gradient == nil
+}
+
+// Gradient blends its Stops along a line from X0,Y0 to X1,Y1, outwards from
+// a focal circle at FX,FY with radius FR to the circle at X,Y with radius R,
+// or around X,Y starting at Angle radians. Coordinates are in the space of
+// the object, so gradients move, rotate and scale with their group.
+type Gradient struct {
+	Type  string
+	X0    float64
+	Y0    float64
+	X1    float64
+	Y1    float64
+	X     float64
+	Y     float64
+	R     float64
+	FX    *float64
+	FY    *float64
+	FR    float64
+	Angle float64
+	Stops []GradientStop
+}
+
+// GradientStop is the color of a gradient at Offset, from 0 to 1.
+type GradientStop struct {
+	Offset float64
+	Color  string
+}
+
+// offset is where the point x,y falls on the gradient, false when it is
+// outside a radial gradient's cone.
+func (g *Gradient) offset(x, y float64) (float64, bool) {
+	switch g.Type {
+	case GradientRadial:
+		fx, fy := g.X, g.Y
+		if g.FX != nil {
+			fx = *g.FX
+		}
+		if g.FY != nil {
+			fy = *g.FY
+		}
+		return radialOffset(x, y, fx, fy, g.FR, g.X, g.Y, g.R)
+	case GradientConic:
+		t := math.Mod((math.Atan2(y-g.Y, x-g.X)-g.Angle)/(2*math.Pi), 1)
+		if t < 0 {
+			t++
+		}
+		return t, true
+	default:
+		dx, dy := g.X1-g.X0, g.Y1-g.Y0
+		length := dx*dx + dy*dy
+		if length == 0 {
+			return 0, true
+		}
+		return ((x-g.X0)*dx + (y-g.Y0)*dy) / length, true
+	}
+}
+
+// radialOffset finds the largest t for which x,y is on the circle
+// interpolated between the focal circle at t=0 and the outer one at t=1, the
+// same two circle gradient as canvas and SVG.
+func radialOffset(x, y, fx, fy, fr, cx, cy, r float64) (float64, bool) {
+	cdx, cdy, dr := cx-fx, cy-fy, r-fr
+	pdx, pdy := x-fx, y-fy
+	a := cdx*cdx + cdy*cdy - dr*dr
+	b := pdx*cdx + pdy*cdy + fr*dr
+	c := pdx*pdx + pdy*pdy - fr*fr
+	if a == 0 {
+		if b == 0 {
+			return 0, false
+		}
+		t := c / (2 * b)
+		return t, fr+t*dr >= 0
+	}
+	discriminant := b*b - a*c
+	if discriminant < 0 {
+		return 0, false
+	}
+	root := math.Sqrt(discriminant)
+	t1, t2 := (b+root)/a, (b-root)/a
+	if t1 < t2 {
+		t1, t2 = t2, t1
+	}
+	if fr+t1*dr >= 0 {
+		return t1, true
+	}
+	return t2, fr+t2*dr >= 0
+}
+
+// gradientPattern is a gg.Pattern for a Gradient. gg's own gradients are
+// evaluated in pixels, ignoring the transform, so the pattern maps pixels
+// back to the coordinates of the object itself.
+type gradientPattern struct {
+	gradient *Gradient
+	inverse  gg.Matrix
+	offsets  []float64
+	colors   [][4]float64
+}
+
+// newGradientPattern captures the current transform of ctx. Stop colors are
+// premultiplied and scaled by opacity.
+func newGradientPattern(ctx *gg.Context, g *Gradient, opacity float64) *gradientPattern {
+	stops := append([]GradientStop(nil), g.Stops...)
+	sort.SliceStable(stops, func(i, j int) bool {
+		return stops[i].Offset < stops[j].Offset
+	})
+	p := &gradientPattern{gradient: g, inverse: inverseTransform(ctx)}
+	for _, stop := range stops {
+		c, ok := ParseHexColor(stop.Color)
+		if !ok {
+			c = color.NRGBA{A: 255}
+		}
+		a := float64(c.A) / 255 * math.Min(math.Max(opacity, 0), 1)
+		p.offsets = append(p.offsets, stop.Offset)
+		p.colors = append(p.colors, [4]float64{float64(c.R) * a, float64(c.G) * a, float64(c.B) * a, 255 * a})
+	}
+	return p
+}
+
+func (p *gradientPattern) ColorAt(x, y int) color.Color {
+	if len(p.colors) == 0 {
+		return color.Transparent
+	}
+	ux, uy := p.inverse.TransformPoint(float64(x)+0.5, float64(y)+0.5)
+	t, ok := p.gradient.offset(ux, uy)
+	if !ok {
+		return color.Transparent
+	}
+
+	last := len(p.offsets) - 1
+	var c [4]float64
+	switch {
+	case t <= p.offsets[0]:
+		c = p.colors[0]
+	case t >= p.offsets[last]:
+		c = p.colors[last]
+	default:
+		i := sort.SearchFloat64s(p.offsets, t)
+		from, to := p.offsets[i-1], p.offsets[i]
+		f := 0.0
+		if to > from {
+			f = (t - from) / (to - from)
+		}
+		for ch := range c {
+			c[ch] = p.colors[i-1][ch] + (p.colors[i][ch]-p.colors[i-1][ch])*f
+		}
+	}
+	return color.RGBA{R: round8(c[0]), G: round8(c[1]), B: round8(c[2]), A: round8(c[3])}
+}
+
+func round8(v float64) uint8 {
+	return uint8(math.Round(math.Min(math.Max(v, 0), 255)))
+}
+
+// inverseTransform maps pixels to the current coordinates of ctx, read back
+// from transformed points like copyTransform.
+func inverseTransform(ctx *gg.Context) gg.Matrix {
+	ox, oy := ctx.TransformPoint(0, 0)
+	ax, ay := ctx.TransformPoint(1, 0)
+	cx, cy := ctx.TransformPoint(0, 1)
+	a, b := ax-ox, ay-oy
+	c, d := cx-ox, cy-oy
+	det := a*d - b*c
+	if det == 0 {
+		return gg.Identity()
+	}
+	// The inverse of x' = a*x + c*y + ox, y' = b*x + d*y + oy.
+	return gg.Matrix{
+		XX: d / det,
+		YX: -b / det,
+		XY: -c / det,
+		YY: a / det,
+		X0: (c*oy - d*ox) / det,
+		Y0: (b*ox - a*oy) / det,
+	}
+}
+
+// setPaint sets a paint as both the fill and stroke style with its alpha
+// scaled by opacity. Gradients also set the color to their first stop, which
+// is what TrueType text is drawn with.
+func setPaint(ctx *gg.Context, paint Paint, opacity float64) {
+	if paint.Gradient == nil {
+		setColor(ctx, paint.Hex, opacity)
+		return
+	}
+	pattern := newGradientPattern(ctx, paint.Gradient, opacity)
+	if len(pattern.colors) > 0 {
+		first := pattern.colors[0]
+		ctx.SetColor(color.RGBA{R: round8(first[0]), G: round8(first[1]), B: round8(first[2]), A: round8(first[3])})
+	}
+	ctx.SetFillStyle(pattern)
+	ctx.SetStrokeStyle(pattern)
+}
@@ -0,0 +1,74 @@
+package objects
+
+import (
+	"encoding/json"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/fogleman/gg"
+)
+
+func TestPaintUnmarshal(t *testing.T) {
+	var circle Circle
+	data := `{"color": "#ff0000", "fill": {"type": "radial", "x": 1, "y": 2, "r": 3, "stops": [{"offset": 0, "color": "#fff"}]}}`
+	if err := json.Unmarshal([]byte(data), &circle); err != nil {
+		t.Fatalf("Failed to unmarshal circle: %v", err)
+	}
+	if circle.Color.Hex != "#ff0000" || circle.Color.Gradient != nil {
+		t.Errorf("Expected a hex color, got %+v", circle.Color)
+	}
+	g := circle.Fill.Gradient
+	if g == nil || g.Type != GradientRadial || g.R != 3 || len(g.Stops) != 1 || g.Stops[0].Color != "#fff" {
+		t.Errorf("Expected a radial gradient, got %+v", g)
+	}
+}
+
+func TestGradientOffset(t *testing.T) {
+	focal := 0.0
+	tests := []struct {
+		name     string
+		gradient Gradient
+		x, y     float64
+		expected float64
+	}{
+		{"linear start", Gradient{Type: GradientLinear, X1: 10}, 0, 5, 0},
+		{"linear middle", Gradient{Type: GradientLinear, X1: 10}, 5, -3, 0.5},
+		{"radial center", Gradient{Type: GradientRadial, X: 5, Y: 5, R: 4}, 5, 5, 0},
+		{"radial edge", Gradient{Type: GradientRadial, X: 5, Y: 5, R: 4}, 5, 9, 1},
+		{"radial focal", Gradient{Type: GradientRadial, X: 0, Y: 0, R: 4, FX: &focal, FY: &focal, FR: 2}, 3, 0, 0.5},
+		{"conic quarter", Gradient{Type: GradientConic}, 0, 1, 0.25},
+		{"conic start angle", Gradient{Type: GradientConic, Angle: math.Pi}, 0, 1, 0.75},
+	}
+	for _, test := range tests {
+		offset, ok := test.gradient.offset(test.x, test.y)
+		if !ok || math.Abs(offset-test.expected) > 1e-9 {
+			t.Errorf("%s: Expected offset %v, got %v (%v)", test.name, test.expected, offset, ok)
+		}
+	}
+}
+
+func TestGradientPatternTransform(t *testing.T) {
+	g := &Gradient{
+		Type:  GradientLinear,
+		X1:    4,
+		Stops: []GradientStop{{Offset: 0, Color: "#000000"}, {Offset: 1, Color: "#ffffff"}},
+	}
+	ctx := gg.NewContext(20, 20)
+	ctx.Translate(10, 0)
+	ctx.Scale(2, 1)
+	pattern := newGradientPattern(ctx, g, 1)
+
+	// The gradient runs from x 10 to 18 in pixels, the center of pixel 13 is
+	// at 1.75 of 4.
+	got := color.RGBAModel.Convert(pattern.ColorAt(13, 0)).(color.RGBA)
+	if got.R != 112 || got.A != 255 {
+		t.Errorf("Expected gray 112, got %v", got)
+	}
+	if got := color.RGBAModel.Convert(pattern.ColorAt(2, 0)).(color.RGBA); got.R != 0 {
+		t.Errorf("Expected the first stop before the gradient, got %v", got)
+	}
+	if got := color.RGBAModel.Convert(pattern.ColorAt(19, 0)).(color.RGBA); got.R != 255 {
+		t.Errorf("Expected the last stop after the gradient, got %v", got)
+	}
+}
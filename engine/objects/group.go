@@ -0,0 +1,95 @@
+package objects
+
+import (
+	"math"
+
+	"github.com/fogleman/gg"
+)
+
+// Group draws its children in its own coordinate space, moved by X and Y,
+// rotated by Rotation radians and scaled around OriginX and OriginY. Scale
+// applies to both axes, ScaleX and ScaleY to one. With Opacity below 1 the
+// children are drawn to a layer faded as a whole, so overlapping children
+// don't show through each other.
+type Group struct {
+	X        float64
+	Y        float64
+	Rotation float64
+	Scale    *float64
+	ScaleX   *float64
+	ScaleY   *float64
+	OriginX  float64
+	OriginY  float64
+	Opacity  *float64
+
+	Children []Renderable `json:"-"`
+}
+
+func (g Group) Render(ctx *gg.Context) {
+	opacity := 1.0
+	if g.Opacity != nil {
+		opacity = *g.Opacity
+	}
+	if opacity <= 0 {
+		return
+	}
+
+	target := ctx
+	if opacity < 1 {
+		target = gg.NewContext(ctx.Width(), ctx.Height())
+		copyTransform(target, ctx)
+	}
+
+	sx, sy := 1.0, 1.0
+	if g.Scale != nil {
+		sx, sy = *g.Scale, *g.Scale
+	}
+	if g.ScaleX != nil {
+		sx = *g.ScaleX
+	}
+	if g.ScaleY != nil {
+		sy = *g.ScaleY
+	}
+
+	target.Push()
+	target.Translate(g.X+g.OriginX, g.Y+g.OriginY)
+	target.Rotate(g.Rotation)
+	target.Scale(sx, sy)
+	target.Translate(-g.OriginX, -g.OriginY)
+	for _, child := range g.Children {
+		child.Render(target)
+	}
+	target.Pop()
+
+	if target != ctx {
+		ctx.Push()
+		ctx.Identity()
+		ctx.DrawImage(fade(target.Image(), opacity), 0, 0)
+		ctx.Pop()
+	}
+}
+
+// copyTransform gives dst the current matrix of src. gg doesn't expose it so
+// it is read back from transformed points and rebuilt as a translation,
+// rotation, shear and scale.
+func copyTransform(dst, src *gg.Context) {
+	ox, oy := src.TransformPoint(0, 0)
+	ax, ay := src.TransformPoint(1, 0)
+	cx, cy := src.TransformPoint(0, 1)
+	a, b := ax-ox, ay-oy
+	c, d := cx-ox, cy-oy
+
+	theta := math.Atan2(b, a)
+	sx := math.Hypot(a, b)
+	cos, sin := math.Cos(theta), math.Sin(theta)
+	shear := cos*c + sin*d
+	sy := -sin*c + cos*d
+
+	dst.Identity()
+	dst.Translate(ox, oy)
+	dst.Rotate(theta)
+	if sy != 0 {
+		dst.Shear(shear/sy, 0)
+	}
+	dst.Scale(sx, sy)
+}
@@ -0,0 +1,52 @@
+package objects
+
+import (
+	"image"
+	"math"
+	"testing"
+
+	"github.com/fogleman/gg"
+)
+
+func TestCopyTransform(t *testing.T) {
+	src := gg.NewContext(10, 10)
+	src.Translate(3, -2)
+	src.RotateAbout(0.7, 5, 5)
+	src.Shear(0.3, 0)
+	src.Scale(2, -0.5)
+
+	dst := gg.NewContext(10, 10)
+	copyTransform(dst, src)
+	for _, p := range []gg.Point{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 0, Y: 1}, {X: 4, Y: -7}} {
+		wx, wy := src.TransformPoint(p.X, p.Y)
+		gx, gy := dst.TransformPoint(p.X, p.Y)
+		if math.Abs(wx-gx) > 1e-9 || math.Abs(wy-gy) > 1e-9 {
+			t.Errorf("Expected %v to map to (%v, %v), got (%v, %v)", p, wx, wy, gx, gy)
+		}
+	}
+}
+
+func TestGroupRender(t *testing.T) {
+	half, double := 0.5, 2.0
+	square := Rectangle{BaseObject: BaseObject{Color: Hex("#ffffff")}, Width: 2, Height: 2}
+	group := Group{
+		X:        4,
+		Y:        4,
+		Scale:    &double,
+		Opacity:  &half,
+		Children: []Renderable{square, square},
+	}
+
+	ctx := gg.NewContext(10, 10)
+	group.Render(ctx)
+	// The square covers 4,4 to 8,8, drawn twice but faded once as a whole.
+	for _, p := range []image.Point{{X: 5, Y: 5}, {X: 7, Y: 7}} {
+		_, _, _, a := ctx.Image().At(p.X, p.Y).RGBA()
+		if a>>8 < 126 || a>>8 > 128 {
+			t.Errorf("Expected half opacity at %v, got alpha %d", p, a>>8)
+		}
+	}
+	if _, _, _, a := ctx.Image().At(9, 9).RGBA(); a != 0 {
+		t.Errorf("Expected nothing outside the scaled square, got alpha %d", a>>8)
+	}
+}
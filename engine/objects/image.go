@@ -0,0 +1,272 @@
+package objects
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fogleman/gg"
+)
+
+// ImageDirs are searched in order for relative image paths.
+var ImageDirs = []string{"."}
+
+// defaultGIFDelay is used for GIF frames without a delay, like browsers do.
+const defaultGIFDelay = 100 * time.Millisecond
+
+// Clocked is implemented by objects that animate on their own, the engine
+// tells them how long the scene has been running before rendering them.
+type Clocked interface {
+	SetElapsed(elapsed time.Duration)
+}
+
+// Image draws a PNG, GIF or JPEG file. X and Y are the top left corner, a
+// zero Width or Height is derived from the other one, or the image size when
+// both are zero. Rotation in radians turns the image around its center.
+// Animated GIFs play by the scene clock unless Frame picks a frame.
+type Image struct {
+	X        float64
+	Y        float64
+	Src      string
+	Width    float64
+	Height   float64
+	Rotation float64
+	Opacity  *float64
+	Frame    *float64
+
+	elapsed time.Duration
+}
+
+func (i *Image) SetElapsed(elapsed time.Duration) {
+	i.elapsed = elapsed
+}
+
+func (i Image) Render(ctx *gg.Context) {
+	sprite, err := LoadSprite(i.Src)
+	if err != nil {
+		fmt.Printf("Error loading image %s: %v\n", i.Src, err)
+		return
+	}
+
+	var frame image.Image
+	if i.Frame != nil {
+		frame = sprite.Frames[positiveMod(int(*i.Frame), len(sprite.Frames))]
+	} else {
+		frame = sprite.FrameAt(i.elapsed)
+	}
+
+	opacity := 1.0
+	if i.Opacity != nil {
+		opacity = *i.Opacity
+	}
+	if opacity <= 0 {
+		return
+	}
+	if opacity < 1 {
+		frame = fade(frame, opacity)
+	}
+
+	bounds := frame.Bounds()
+	iw, ih := float64(bounds.Dx()), float64(bounds.Dy())
+	w, h := i.Width, i.Height
+	switch {
+	case w == 0 && h == 0:
+		w, h = iw, ih
+	case w == 0:
+		w = h * iw / ih
+	case h == 0:
+		h = w * ih / iw
+	}
+
+	ctx.Push()
+	ctx.Translate(i.X+w/2, i.Y+h/2)
+	ctx.Rotate(i.Rotation)
+	ctx.Scale(w/iw, h/ih)
+	ctx.DrawImageAnchored(frame, 0, 0, 0.5, 0.5)
+	ctx.Pop()
+}
+
+// fade returns a copy of img with its alpha multiplied by opacity.
+func fade(img image.Image, opacity float64) image.Image {
+	bounds := img.Bounds()
+	faded := image.NewRGBA(bounds)
+	mask := image.NewUniform(color.Alpha{A: uint8(opacity * 255)})
+	draw.DrawMask(faded, bounds, img, bounds.Min, mask, image.Point{}, draw.Over)
+	return faded
+}
+
+// Sprite is a decoded image file, still images have a single frame.
+type Sprite struct {
+	Frames []image.Image
+	Delays []time.Duration
+	// LoopCount follows image/gif, 0 loops forever, -1 plays once and n
+	// plays n+1 times.
+	LoopCount int
+}
+
+// Duration is the length of one loop of the sprite.
+func (s *Sprite) Duration() time.Duration {
+	var total time.Duration
+	for _, delay := range s.Delays {
+		total += delay
+	}
+	return total
+}
+
+// FrameAt returns the frame shown elapsed after the sprite started playing,
+// finished animations hold their last frame.
+func (s *Sprite) FrameAt(elapsed time.Duration) image.Image {
+	total := s.Duration()
+	if len(s.Frames) == 1 || total <= 0 {
+		return s.Frames[0]
+	}
+	if s.LoopCount != 0 {
+		plays := s.LoopCount + 1
+		if s.LoopCount < 0 {
+			plays = 1
+		}
+		if elapsed >= total*time.Duration(plays) {
+			return s.Frames[len(s.Frames)-1]
+		}
+	}
+	elapsed %= total
+	for idx, delay := range s.Delays {
+		if elapsed < delay {
+			return s.Frames[idx]
+		}
+		elapsed -= delay
+	}
+	return s.Frames[len(s.Frames)-1]
+}
+
+// cachedSprite is a decoded image file and the size and modification time it
+// had, so edits to the file are picked up.
+type cachedSprite struct {
+	sprite  *Sprite
+	size    int64
+	modTime time.Time
+}
+
+var (
+	spritesMu sync.Mutex
+	sprites   = make(map[string]cachedSprite)
+)
+
+// LoadSprite decodes and caches an image file, resolving relative paths
+// against ImageDirs. The file is decoded again once it changed.
+func LoadSprite(path string) (*Sprite, error) {
+	resolved, err := findImage(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return nil, err
+	}
+
+	spritesMu.Lock()
+	defer spritesMu.Unlock()
+	if cached, ok := sprites[resolved]; ok && cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+		return cached.sprite, nil
+	}
+	sprite, err := DecodeSprite(resolved)
+	if err != nil {
+		return nil, err
+	}
+	sprites[resolved] = cachedSprite{sprite: sprite, size: info.Size(), modTime: info.ModTime()}
+	return sprite, nil
+}
+
+func findImage(path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("image has no src")
+	}
+	if filepath.IsAbs(path) {
+		return path, nil
+	}
+	for _, dir := range ImageDirs {
+		candidate := filepath.Join(dir, path)
+		if fileExists(candidate) {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("image %s not found in %s", path, strings.Join(ImageDirs, ", "))
+}
+
+// DecodeSprite decodes an image file without caching it, for images shown
+// once like the GIFs of a playlist.
+func DecodeSprite(path string) (*Sprite, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".gif") {
+		g, err := gif.DecodeAll(f)
+		if err != nil {
+			return nil, err
+		}
+		return SpriteFromGIF(g), nil
+	}
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	return &Sprite{Frames: []image.Image{img}, Delays: []time.Duration{0}}, nil
+}
+
+// SpriteFromGIF composes the frames of a GIF, which are usually only the
+// part that changed, into full images honoring their disposal methods.
+func SpriteFromGIF(g *gif.GIF) *Sprite {
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if bounds.Empty() && len(g.Image) > 0 {
+		bounds = g.Image[0].Bounds()
+	}
+	sprite := &Sprite{LoopCount: g.LoopCount}
+	canvas := image.NewRGBA(bounds)
+	for idx, frame := range g.Image {
+		var previous *image.RGBA
+		disposal := byte(0)
+		if idx < len(g.Disposal) {
+			disposal = g.Disposal[idx]
+		}
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewRGBA(bounds)
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		composed := image.NewRGBA(bounds)
+		copy(composed.Pix, canvas.Pix)
+		sprite.Frames = append(sprite.Frames, composed)
+
+		delay := defaultGIFDelay
+		if idx < len(g.Delay) && g.Delay[idx] > 0 {
+			delay = time.Duration(g.Delay[idx]) * 10 * time.Millisecond
+		}
+		sprite.Delays = append(sprite.Delays, delay)
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	return sprite
+}
+
+func positiveMod(a, n int) int {
+	return ((a % n) + n) % n
+}
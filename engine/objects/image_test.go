@@ -0,0 +1,90 @@
+package objects
+
+import (
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSpriteFromGIF(t *testing.T) {
+	palette := color.Palette{color.Transparent, color.White}
+	full := image.NewPaletted(image.Rect(0, 0, 2, 1), palette)
+	full.SetColorIndex(0, 0, 1)
+	full.SetColorIndex(1, 0, 1)
+	// The second frame only covers the right pixel and is disposed to the
+	// background, the third covers nothing.
+	patch := image.NewPaletted(image.Rect(1, 0, 2, 1), palette)
+	empty := image.NewPaletted(image.Rect(0, 0, 1, 1), palette)
+
+	sprite := SpriteFromGIF(&gif.GIF{
+		Image:     []*image.Paletted{full, patch, empty},
+		Delay:     []int{10, 0, 20},
+		Disposal:  []byte{gif.DisposalNone, gif.DisposalBackground, gif.DisposalNone},
+		LoopCount: -1,
+		Config:    image.Config{Width: 2, Height: 1},
+	})
+
+	expected := []time.Duration{100 * time.Millisecond, defaultGIFDelay, 200 * time.Millisecond}
+	for idx, delay := range expected {
+		if sprite.Delays[idx] != delay {
+			t.Errorf("Expected delay %v for frame %d, got %v", delay, idx, sprite.Delays[idx])
+		}
+	}
+
+	_, _, _, a := sprite.Frames[1].At(1, 0).RGBA()
+	if a == 0 {
+		t.Errorf("Expected the transparent patch to keep the right pixel")
+	}
+	_, _, _, a = sprite.Frames[2].At(1, 0).RGBA()
+	if a != 0 {
+		t.Errorf("Expected the right pixel to be cleared by disposal, got alpha %d", a)
+	}
+	_, _, _, a = sprite.Frames[2].At(0, 0).RGBA()
+	if a == 0 {
+		t.Errorf("Expected the left pixel to persist")
+	}
+
+	if sprite.FrameAt(150*time.Millisecond) != sprite.Frames[1] {
+		t.Errorf("Expected frame 1 at 150ms")
+	}
+	if sprite.FrameAt(time.Second) != sprite.Frames[2] {
+		t.Errorf("Expected a sprite that plays once to hold its last frame")
+	}
+}
+
+func TestLoadSpriteReloadsEditedFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dot.png")
+	write := func(c color.Color, modTime time.Time) {
+		img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+		img.Set(0, 0, c)
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatalf("Failed to create image: %v", err)
+		}
+		png.Encode(f, img)
+		f.Close()
+		os.Chtimes(path, modTime, modTime)
+	}
+
+	write(color.White, time.Unix(1000, 0))
+	first, err := LoadSprite(path)
+	if err != nil {
+		t.Fatalf("Failed to load sprite: %v", err)
+	}
+	if again, _ := LoadSprite(path); again != first {
+		t.Errorf("Expected an unchanged file to come from the cache")
+	}
+	write(color.Black, time.Unix(2000, 0))
+	edited, err := LoadSprite(path)
+	if err != nil {
+		t.Fatalf("Failed to load sprite: %v", err)
+	}
+	if r, _, _, _ := edited.Frames[0].At(0, 0).RGBA(); r != 0 {
+		t.Errorf("Expected the edited black pixel, got red %d", r)
+	}
+}
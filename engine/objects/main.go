@@ -9,80 +9,93 @@ type Renderable interface {
 type BaseObject struct {
 	X     float64
 	Y     float64
-	Color string
+	Color Paint
 }
 
 type Circle struct {
 	BaseObject
+	Style
 	Radius float64
 }
 
 func (c Circle) Render(ctx *gg.Context) {
-	ctx.SetHexColor(c.Color)
 	ctx.DrawCircle(c.X, c.Y, c.Radius)
-	ctx.Fill()
+	c.paint(ctx, c.Color, true)
 }
 
 type Rectangle struct {
 	BaseObject
+	Style
 	Width  float64
 	Height float64
 }
 
 func (r Rectangle) Render(ctx *gg.Context) {
-	ctx.SetHexColor(r.Color)
 	ctx.DrawRectangle(r.X, r.Y, r.Width, r.Height)
-	ctx.Fill()
+	r.paint(ctx, r.Color, true)
 }
 
 type Line struct {
+	Style
 	StartPoint gg.Point
 	EndPoint   gg.Point
-	Color      string
+	Color      Paint
 }
 
 func (l Line) Render(ctx *gg.Context) {
-	ctx.SetHexColor(l.Color)
 	ctx.DrawLine(l.StartPoint.X, l.StartPoint.Y, l.EndPoint.X, l.EndPoint.Y)
-	ctx.Stroke()
+	l.paint(ctx, l.Color, false)
 }
 
+// Arc is stroked along its curve, with a Fill it is a pie slice.
 type Arc struct {
 	BaseObject
+	Style
 	Radius     float64
 	StartAngle float64
 	EndAngle   float64
 }
 
 func (a Arc) Render(ctx *gg.Context) {
-	ctx.SetHexColor(a.Color)
+	if a.Fill.IsZero() {
+		ctx.DrawArc(a.X, a.Y, a.Radius, a.StartAngle, a.EndAngle)
+		a.paint(ctx, a.Color, false)
+		return
+	}
+	ctx.NewSubPath()
+	ctx.MoveTo(a.X, a.Y)
 	ctx.DrawArc(a.X, a.Y, a.Radius, a.StartAngle, a.EndAngle)
-	ctx.Stroke()
+	ctx.ClosePath()
+	a.paint(ctx, a.Color, true)
 }
 
 type SimplePolygon struct {
 	BaseObject
+	Style
 	N        int
 	R        float64
 	Rotation float64
 }
 
 func (p SimplePolygon) Render(ctx *gg.Context) {
-	ctx.SetHexColor(p.Color)
 	ctx.DrawRegularPolygon(p.N, p.X, p.Y, p.R, p.Rotation)
-	ctx.Fill()
+	p.paint(ctx, p.Color, true)
 }
 
 type Polygon struct {
+	Style
 	Points []gg.Point
-	Color  string
+	Color  Paint
 }
 
 func (p Polygon) Render(ctx *gg.Context) {
-	ctx.SetHexColor(p.Color)
+	if len(p.Points) == 0 {
+		return
+	}
 	ctx.MoveTo(p.Points[0].X, p.Points[0].Y)
 	for _, point := range p.Points[1:] {
 		ctx.LineTo(point.X, point.Y)
 	}
-	ctx.Fill()
+	ctx.ClosePath()
+	p.paint(ctx, p.Color, true)
 }
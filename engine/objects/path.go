@@ -0,0 +1,509 @@
+package objects
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/fogleman/gg"
+)
+
+const (
+	SegmentMove  = "move"
+	SegmentLine  = "line"
+	SegmentCubic = "cubic"
+	SegmentQuad  = "quad"
+	SegmentArc   = "arc"
+	SegmentClose = "close"
+)
+
+// Path draws SVG path data from D, or Segments when D is empty. A path with a
+// close segment or a Fill is filled, others are stroked, see Style.
+type Path struct {
+	Style
+	Color    Paint
+	D        string
+	Segments Segments
+}
+
+// Segments decode from a list of segments or from SVG path data, so an
+// expression can give either.
+type Segments []PathSegment
+
+func (s *Segments) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var d string
+		if err := json.Unmarshal(data, &d); err != nil {
+			return err
+		}
+		segments, err := loadPathData(d)
+		if err != nil {
+			return err
+		}
+		*s = segments
+		return nil
+	}
+	return json.Unmarshal(data, (*[]PathSegment)(s))
+}
+
+// PathSegment is one step of a path in absolute coordinates. Cubic curves
+// have the control points X1,Y1 and X2,Y2, quadratic curves only X1,Y1. Arcs
+// go along an ellipse with radii RX and RY turned by Rotation radians, the
+// flags pick one of the four possible arcs like in SVG.
+type PathSegment struct {
+	Type     string
+	X        float64
+	Y        float64
+	X1       float64
+	Y1       float64
+	X2       float64
+	Y2       float64
+	RX       float64
+	RY       float64
+	Rotation float64
+	LargeArc bool
+	Sweep    bool
+}
+
+func (p Path) Render(ctx *gg.Context) {
+	segments := p.Segments
+	if p.D != "" {
+		var err error
+		segments, err = loadPathData(p.D)
+		if err != nil {
+			fmt.Printf("Error parsing path data %q: %v\n", p.D, err)
+			return
+		}
+	}
+	if len(segments) == 0 {
+		return
+	}
+	closed := tracePath(ctx, segments)
+	p.paint(ctx, p.Color, closed || !p.Fill.IsZero())
+}
+
+// tracePath adds segments to the current path of ctx, it reports whether any
+// of them closes it.
+func tracePath(ctx *gg.Context, segments []PathSegment) bool {
+	closed := false
+	var current, start gg.Point
+	for _, s := range segments {
+		end := gg.Point{X: s.X, Y: s.Y}
+		switch s.Type {
+		case SegmentMove:
+			ctx.MoveTo(s.X, s.Y)
+			start = end
+		case SegmentLine:
+			ctx.LineTo(s.X, s.Y)
+		case SegmentCubic:
+			ctx.CubicTo(s.X1, s.Y1, s.X2, s.Y2, s.X, s.Y)
+		case SegmentQuad:
+			ctx.QuadraticTo(s.X1, s.Y1, s.X, s.Y)
+		case SegmentArc:
+			for _, c := range arcSegments(current, s) {
+				if c.Type == SegmentLine {
+					ctx.LineTo(c.X, c.Y)
+				} else {
+					ctx.CubicTo(c.X1, c.Y1, c.X2, c.Y2, c.X, c.Y)
+				}
+			}
+		case SegmentClose:
+			ctx.ClosePath()
+			closed = true
+			end = start
+		default:
+			continue
+		}
+		current = end
+	}
+	return closed
+}
+
+// CubicSegments rewrites segments with only moves, cubic curves and closes,
+// the same shape in a form that can be blended point by point. Closed
+// subpaths get an explicit curve back to their start.
+func CubicSegments(segments []PathSegment) []PathSegment {
+	var out []PathSegment
+	var current, start gg.Point
+	for _, s := range segments {
+		end := gg.Point{X: s.X, Y: s.Y}
+		if len(out) == 0 && s.Type != SegmentMove {
+			out = append(out, PathSegment{Type: SegmentMove, X: current.X, Y: current.Y})
+		}
+		switch s.Type {
+		case SegmentMove:
+			out = append(out, PathSegment{Type: SegmentMove, X: s.X, Y: s.Y})
+			start = end
+		case SegmentLine:
+			out = append(out, lineCubic(current, end))
+		case SegmentQuad:
+			// Degree elevation, the control points are 2/3 of the way to
+			// the quadratic one.
+			out = append(out, PathSegment{
+				Type: SegmentCubic,
+				X1:   current.X + 2.0/3*(s.X1-current.X),
+				Y1:   current.Y + 2.0/3*(s.Y1-current.Y),
+				X2:   s.X + 2.0/3*(s.X1-s.X),
+				Y2:   s.Y + 2.0/3*(s.Y1-s.Y),
+				X:    s.X,
+				Y:    s.Y,
+			})
+		case SegmentCubic:
+			out = append(out, PathSegment{Type: SegmentCubic, X1: s.X1, Y1: s.Y1, X2: s.X2, Y2: s.Y2, X: s.X, Y: s.Y})
+		case SegmentArc:
+			for _, c := range arcSegments(current, s) {
+				if c.Type == SegmentLine {
+					c = lineCubic(current, end)
+				}
+				out = append(out, c)
+			}
+		case SegmentClose:
+			if current != start {
+				out = append(out, lineCubic(current, start))
+			}
+			out = append(out, PathSegment{Type: SegmentClose, X: start.X, Y: start.Y})
+			end = start
+		default:
+			continue
+		}
+		current = end
+	}
+	return out
+}
+
+// lineCubic is the straight line from a to b as a cubic curve.
+func lineCubic(a, b gg.Point) PathSegment {
+	return PathSegment{
+		Type: SegmentCubic,
+		X1:   a.X + (b.X-a.X)/3,
+		Y1:   a.Y + (b.Y-a.Y)/3,
+		X2:   a.X + 2*(b.X-a.X)/3,
+		Y2:   a.Y + 2*(b.Y-a.Y)/3,
+		X:    b.X,
+		Y:    b.Y,
+	}
+}
+
+// arcSegments turns the elliptical arc s from the current point into cubic
+// curves, with the endpoint to center conversion of the SVG implementation
+// notes. Arcs without a radius are a line.
+func arcSegments(from gg.Point, s PathSegment) []PathSegment {
+	if from.X == s.X && from.Y == s.Y {
+		return nil
+	}
+	rx, ry := math.Abs(s.RX), math.Abs(s.RY)
+	if rx == 0 || ry == 0 {
+		return []PathSegment{{Type: SegmentLine, X: s.X, Y: s.Y}}
+	}
+
+	cos, sin := math.Cos(s.Rotation), math.Sin(s.Rotation)
+	dx, dy := (from.X-s.X)/2, (from.Y-s.Y)/2
+	x1 := cos*dx + sin*dy
+	y1 := -sin*dx + cos*dy
+
+	// Radii too small to reach the end point are scaled up.
+	if lambda := x1*x1/(rx*rx) + y1*y1/(ry*ry); lambda > 1 {
+		rx *= math.Sqrt(lambda)
+		ry *= math.Sqrt(lambda)
+	}
+	numerator := rx*rx*ry*ry - rx*rx*y1*y1 - ry*ry*x1*x1
+	denominator := rx*rx*y1*y1 + ry*ry*x1*x1
+	coef := math.Sqrt(math.Max(0, numerator/denominator))
+	if s.LargeArc == s.Sweep {
+		coef = -coef
+	}
+	cx1 := coef * rx * y1 / ry
+	cy1 := -coef * ry * x1 / rx
+	cx := cos*cx1 - sin*cy1 + (from.X+s.X)/2
+	cy := sin*cx1 + cos*cy1 + (from.Y+s.Y)/2
+
+	ux, uy := (x1-cx1)/rx, (y1-cy1)/ry
+	vx, vy := (-x1-cx1)/rx, (-y1-cy1)/ry
+	theta := math.Atan2(uy, ux)
+	delta := math.Atan2(ux*vy-uy*vx, ux*vx+uy*vy)
+	if !s.Sweep && delta > 0 {
+		delta -= 2 * math.Pi
+	} else if s.Sweep && delta < 0 {
+		delta += 2 * math.Pi
+	}
+
+	// Quarter turns at most, the usual cubic approximation of a circle.
+	n := math.Ceil(math.Abs(delta) / (math.Pi / 2))
+	step := delta / n
+	k := 4.0 / 3 * math.Tan(step/4)
+	point := func(x, y float64) (float64, float64) {
+		return cx + rx*x*cos - ry*y*sin, cy + rx*x*sin + ry*y*cos
+	}
+	curves := make([]PathSegment, int(n))
+	for i := range curves {
+		a1 := theta + float64(i)*step
+		a2 := a1 + step
+		c1, s1 := math.Cos(a1), math.Sin(a1)
+		c2, s2 := math.Cos(a2), math.Sin(a2)
+		c := PathSegment{Type: SegmentCubic}
+		c.X1, c.Y1 = point(c1-k*s1, s1+k*c1)
+		c.X2, c.Y2 = point(c2+k*s2, s2-k*c2)
+		c.X, c.Y = point(c2, s2)
+		curves[i] = c
+	}
+	// End exactly on the end point.
+	curves[len(curves)-1].X, curves[len(curves)-1].Y = s.X, s.Y
+	return curves
+}
+
+var (
+	pathDataMu sync.Mutex
+	pathData   = make(map[string][]PathSegment)
+)
+
+// loadPathData caches parsed path data, it doesn't change between frames.
+func loadPathData(d string) ([]PathSegment, error) {
+	pathDataMu.Lock()
+	defer pathDataMu.Unlock()
+	if segments, ok := pathData[d]; ok {
+		return segments, nil
+	}
+	segments, err := ParsePathData(d)
+	if err != nil {
+		return nil, err
+	}
+	pathData[d] = segments
+	return segments, nil
+}
+
+// ParsePathData parses the d attribute of an SVG path into absolute
+// segments. All commands are understood, relative ones included, H and V
+// become lines and the smooth S and T curves get their reflected control
+// point.
+func ParsePathData(d string) ([]PathSegment, error) {
+	sc := &pathScanner{s: d}
+	var segments []PathSegment
+	var command byte
+	var current, start gg.Point
+	for {
+		sc.skip()
+		if sc.done() {
+			break
+		}
+		if c := sc.s[sc.pos]; isPathCommand(c) {
+			command = c
+			sc.pos++
+		} else if command == 0 {
+			return nil, sc.errorf("path data must start with a command")
+		} else if command == 'Z' || command == 'z' {
+			return nil, sc.errorf("unexpected %q after close", c)
+		}
+		if len(segments) == 0 && command != 'M' && command != 'm' {
+			return nil, sc.errorf("path data must start with M")
+		}
+
+		relative := command >= 'a'
+		abs := func(x, y float64) (float64, float64) {
+			if relative {
+				return current.X + x, current.Y + y
+			}
+			return x, y
+		}
+		var numbers []float64
+		read := func(count int) error {
+			numbers = numbers[:0]
+			for i := 0; i < count; i++ {
+				v, err := sc.number()
+				if err != nil {
+					return err
+				}
+				numbers = append(numbers, v)
+			}
+			return nil
+		}
+
+		var s PathSegment
+		switch command {
+		case 'M', 'm':
+			if err := read(2); err != nil {
+				return nil, err
+			}
+			s.Type = SegmentMove
+			s.X, s.Y = abs(numbers[0], numbers[1])
+			start = gg.Point{X: s.X, Y: s.Y}
+			// Further pairs are lines.
+			if relative {
+				command = 'l'
+			} else {
+				command = 'L'
+			}
+		case 'L', 'l':
+			if err := read(2); err != nil {
+				return nil, err
+			}
+			s.Type = SegmentLine
+			s.X, s.Y = abs(numbers[0], numbers[1])
+		case 'H', 'h':
+			if err := read(1); err != nil {
+				return nil, err
+			}
+			s.Type = SegmentLine
+			s.X, s.Y = numbers[0], current.Y
+			if relative {
+				s.X += current.X
+			}
+		case 'V', 'v':
+			if err := read(1); err != nil {
+				return nil, err
+			}
+			s.Type = SegmentLine
+			s.X, s.Y = current.X, numbers[0]
+			if relative {
+				s.Y += current.Y
+			}
+		case 'C', 'c':
+			if err := read(6); err != nil {
+				return nil, err
+			}
+			s.Type = SegmentCubic
+			s.X1, s.Y1 = abs(numbers[0], numbers[1])
+			s.X2, s.Y2 = abs(numbers[2], numbers[3])
+			s.X, s.Y = abs(numbers[4], numbers[5])
+		case 'S', 's':
+			if err := read(4); err != nil {
+				return nil, err
+			}
+			s.Type = SegmentCubic
+			s.X1, s.Y1 = reflectControl(segments, SegmentCubic, current)
+			s.X2, s.Y2 = abs(numbers[0], numbers[1])
+			s.X, s.Y = abs(numbers[2], numbers[3])
+		case 'Q', 'q':
+			if err := read(4); err != nil {
+				return nil, err
+			}
+			s.Type = SegmentQuad
+			s.X1, s.Y1 = abs(numbers[0], numbers[1])
+			s.X, s.Y = abs(numbers[2], numbers[3])
+		case 'T', 't':
+			if err := read(2); err != nil {
+				return nil, err
+			}
+			s.Type = SegmentQuad
+			s.X1, s.Y1 = reflectControl(segments, SegmentQuad, current)
+			s.X, s.Y = abs(numbers[0], numbers[1])
+		case 'A', 'a':
+			if err := read(3); err != nil {
+				return nil, err
+			}
+			s.Type = SegmentArc
+			s.RX, s.RY, s.Rotation = numbers[0], numbers[1], numbers[2]*math.Pi/180
+			var err error
+			if s.LargeArc, err = sc.flag(); err != nil {
+				return nil, err
+			}
+			if s.Sweep, err = sc.flag(); err != nil {
+				return nil, err
+			}
+			if err := read(2); err != nil {
+				return nil, err
+			}
+			s.X, s.Y = abs(numbers[0], numbers[1])
+		case 'Z', 'z':
+			s.Type = SegmentClose
+			s.X, s.Y = start.X, start.Y
+		}
+		segments = append(segments, s)
+		current = gg.Point{X: s.X, Y: s.Y}
+	}
+	return segments, nil
+}
+
+// reflectControl mirrors the last control point of the previous segment
+// around the current point when it is a curve of the same kind, S and T
+// curves start smoothly that way.
+func reflectControl(segments []PathSegment, kind string, current gg.Point) (float64, float64) {
+	if len(segments) == 0 {
+		return current.X, current.Y
+	}
+	previous := segments[len(segments)-1]
+	switch {
+	case previous.Type == kind && kind == SegmentCubic:
+		return 2*current.X - previous.X2, 2*current.Y - previous.Y2
+	case previous.Type == kind && kind == SegmentQuad:
+		return 2*current.X - previous.X1, 2*current.Y - previous.Y1
+	}
+	return current.X, current.Y
+}
+
+func isPathCommand(c byte) bool {
+	return strings.IndexByte("MmLlHhVvCcSsQqTtAaZz", c) >= 0
+}
+
+// pathScanner reads the numbers of path data, which may be separated by
+// whitespace, commas or nothing at all like "1-2.5.5".
+type pathScanner struct {
+	s   string
+	pos int
+}
+
+func (sc *pathScanner) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("column %d: %s", sc.pos+1, fmt.Sprintf(format, args...))
+}
+
+func (sc *pathScanner) skip() {
+	for sc.pos < len(sc.s) && strings.IndexByte(" \t\r\n,", sc.s[sc.pos]) >= 0 {
+		sc.pos++
+	}
+}
+
+func (sc *pathScanner) done() bool {
+	return sc.pos >= len(sc.s)
+}
+
+func (sc *pathScanner) number() (float64, error) {
+	sc.skip()
+	begin := sc.pos
+	if sc.pos < len(sc.s) && (sc.s[sc.pos] == '+' || sc.s[sc.pos] == '-') {
+		sc.pos++
+	}
+	digits := sc.digits()
+	if sc.pos < len(sc.s) && sc.s[sc.pos] == '.' {
+		sc.pos++
+		digits += sc.digits()
+	}
+	if digits == 0 {
+		sc.pos = begin
+		if sc.done() {
+			return 0, sc.errorf("expected a number, got the end")
+		}
+		return 0, sc.errorf("expected a number, got %q", sc.s[sc.pos])
+	}
+	if sc.pos < len(sc.s) && (sc.s[sc.pos] == 'e' || sc.s[sc.pos] == 'E') {
+		exponent := sc.pos
+		sc.pos++
+		if sc.pos < len(sc.s) && (sc.s[sc.pos] == '+' || sc.s[sc.pos] == '-') {
+			sc.pos++
+		}
+		if sc.digits() == 0 {
+			sc.pos = exponent
+		}
+	}
+	return strconv.ParseFloat(sc.s[begin:sc.pos], 64)
+}
+
+func (sc *pathScanner) digits() int {
+	count := 0
+	for sc.pos < len(sc.s) && sc.s[sc.pos] >= '0' && sc.s[sc.pos] <= '9' {
+		sc.pos++
+		count++
+	}
+	return count
+}
+
+// flag reads an arc flag, a single 0 or 1 that needs no separator.
+func (sc *pathScanner) flag() (bool, error) {
+	sc.skip()
+	if sc.pos < len(sc.s) && (sc.s[sc.pos] == '0' || sc.s[sc.pos] == '1') {
+		sc.pos++
+		return sc.s[sc.pos-1] == '1', nil
+	}
+	return false, sc.errorf("expected an arc flag")
+}
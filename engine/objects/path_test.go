@@ -0,0 +1,86 @@
+package objects
+
+import (
+	"image"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/fogleman/gg"
+)
+
+func TestParsePathData(t *testing.T) {
+	segments, err := ParsePathData("M10,20l5-5.5.5,0H0v2 C1 2 3 4 5 6 s1 1 2 2 Q0 0 1 1 T3 3 a2 2 90 1010 0 z")
+	if err != nil {
+		t.Fatalf("Failed to parse path data: %v", err)
+	}
+	expected := []PathSegment{
+		{Type: SegmentMove, X: 10, Y: 20},
+		{Type: SegmentLine, X: 15, Y: 14.5},
+		{Type: SegmentLine, X: 15.5, Y: 14.5},
+		{Type: SegmentLine, X: 0, Y: 14.5},
+		{Type: SegmentLine, X: 0, Y: 16.5},
+		{Type: SegmentCubic, X1: 1, Y1: 2, X2: 3, Y2: 4, X: 5, Y: 6},
+		{Type: SegmentCubic, X1: 7, Y1: 8, X2: 6, Y2: 7, X: 7, Y: 8},
+		{Type: SegmentQuad, X1: 0, Y1: 0, X: 1, Y: 1},
+		{Type: SegmentQuad, X1: 2, Y1: 2, X: 3, Y: 3},
+		{Type: SegmentArc, RX: 2, RY: 2, Rotation: math.Pi / 2, LargeArc: true, Sweep: false, X: 13, Y: 3},
+		{Type: SegmentClose, X: 10, Y: 20},
+	}
+	if !reflect.DeepEqual(segments, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, segments)
+	}
+}
+
+func TestParsePathDataErrors(t *testing.T) {
+	for d, expected := range map[string]string{
+		"10 10":                 "column 1: path data must start with a command",
+		"L 1 1":                 "column 2: path data must start with M",
+		"M 1":                   "column 4: expected a number, got the end",
+		"M 1 1 Z 2":             "column 9: unexpected '2' after close",
+		"M 0 0 A 1 1 0 2 0 1 1": "column 15: expected an arc flag",
+	} {
+		_, err := ParsePathData(d)
+		if err == nil || err.Error() != expected {
+			t.Errorf("%q: Expected error %q, got %v", d, expected, err)
+		}
+	}
+}
+
+func TestPathArc(t *testing.T) {
+	// A half circle from 2,10 to 18,10 bulging down, closed into a half disc.
+	path := Path{Color: Hex("#ffffff"), D: "M2 10 A8 8 0 0 0 18 10 Z"}
+	ctx := gg.NewContext(20, 20)
+	path.Render(ctx)
+	for _, p := range []image.Point{{X: 10, Y: 16}, {X: 5, Y: 12}} {
+		if a := alphaAt(ctx, p); a != 255 {
+			t.Errorf("Expected %v inside the half disc, got alpha %d", p, a)
+		}
+	}
+	for _, p := range []image.Point{{X: 10, Y: 6}, {X: 10, Y: 19}} {
+		if a := alphaAt(ctx, p); a != 0 {
+			t.Errorf("Expected %v outside the half disc, got alpha %d", p, a)
+		}
+	}
+}
+
+func TestPathSegmentsStroke(t *testing.T) {
+	width := 2.0
+	path := Path{
+		Color: Hex("#ffffff"),
+		Style: Style{StrokeWidth: &width},
+		Segments: []PathSegment{
+			{Type: SegmentMove, X: 2, Y: 2},
+			{Type: SegmentLine, X: 18, Y: 2},
+			{Type: SegmentLine, X: 18, Y: 18},
+		},
+	}
+	ctx := gg.NewContext(20, 20)
+	path.Render(ctx)
+	if a := alphaAt(ctx, image.Pt(10, 2)); a == 0 {
+		t.Errorf("Expected the open path to be stroked")
+	}
+	if a := alphaAt(ctx, image.Pt(12, 8)); a != 0 {
+		t.Errorf("Expected an open path without fill to stay empty, got alpha %d", a)
+	}
+}
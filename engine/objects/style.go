@@ -0,0 +1,82 @@
+package objects
+
+import "github.com/fogleman/gg"
+
+const (
+	LineCapButt   = "butt"
+	LineCapRound  = "round"
+	LineCapSquare = "square"
+
+	LineJoinRound = "round"
+	LineJoinBevel = "bevel"
+)
+
+// Style is how a shape is painted. Fill and Stroke are paints, closed
+// shapes fill with Color when Fill isn't set and open shapes stroke with it
+// when Stroke isn't. A closed shape with only a Stroke is just outlined.
+// Opacity scales the alpha of both.
+type Style struct {
+	Fill        Paint
+	Stroke      Paint
+	StrokeWidth *float64
+	LineCap     string
+	LineJoin    string
+	Dash        []float64
+	DashOffset  float64
+	Opacity     *float64
+}
+
+// paint fills and strokes the current path and clears it.
+func (s Style) paint(ctx *gg.Context, color Paint, closed bool) {
+	opacity := 1.0
+	if s.Opacity != nil {
+		opacity = *s.Opacity
+	}
+
+	fill, stroke := s.Fill, s.Stroke
+	if closed && fill.IsZero() && (!color.IsZero() || stroke.IsZero()) {
+		fill = color
+	}
+	if !closed && stroke.IsZero() {
+		stroke = color
+	}
+	if opacity <= 0 {
+		ctx.ClearPath()
+		return
+	}
+
+	ctx.Push()
+	defer ctx.Pop()
+	if closed && !fill.IsZero() {
+		setPaint(ctx, fill, opacity)
+		if stroke.IsZero() {
+			ctx.Fill()
+			return
+		}
+		ctx.FillPreserve()
+	}
+
+	if s.StrokeWidth != nil {
+		ctx.SetLineWidth(*s.StrokeWidth)
+	}
+	switch s.LineCap {
+	case LineCapButt:
+		ctx.SetLineCap(gg.LineCapButt)
+	case LineCapRound:
+		ctx.SetLineCap(gg.LineCapRound)
+	case LineCapSquare:
+		ctx.SetLineCap(gg.LineCapSquare)
+	}
+	switch s.LineJoin {
+	case LineJoinRound:
+		ctx.SetLineJoin(gg.LineJoinRound)
+	case LineJoinBevel:
+		ctx.SetLineJoin(gg.LineJoinBevel)
+	}
+	if len(s.Dash) > 0 {
+		ctx.SetDash(s.Dash...)
+		ctx.SetDashOffset(s.DashOffset)
+	}
+	setPaint(ctx, stroke, opacity)
+	ctx.Stroke()
+}
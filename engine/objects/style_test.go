@@ -0,0 +1,98 @@
+package objects
+
+import (
+	"image"
+	"testing"
+
+	"github.com/fogleman/gg"
+)
+
+func alphaAt(ctx *gg.Context, p image.Point) uint32 {
+	_, _, _, a := ctx.Image().At(p.X, p.Y).RGBA()
+	return a >> 8
+}
+
+func TestStylePaint(t *testing.T) {
+	half, width := 0.5, 2.0
+	tests := []struct {
+		name   string
+		object Renderable
+		filled []image.Point
+		empty  []image.Point
+		alpha  uint32
+	}{
+		{
+			name:   "legacy fill",
+			object: Circle{BaseObject: BaseObject{X: 10, Y: 10, Color: Hex("#ffffff")}, Radius: 6},
+			filled: []image.Point{{X: 10, Y: 10}},
+			alpha:  255,
+		},
+		{
+			name:   "outline only",
+			object: Circle{BaseObject: BaseObject{X: 10, Y: 10}, Style: Style{Stroke: Hex("#ffffff"), StrokeWidth: &width}, Radius: 6},
+			filled: []image.Point{{X: 16, Y: 10}},
+			empty:  []image.Point{{X: 10, Y: 10}},
+			alpha:  255,
+		},
+		{
+			name:   "pie slice",
+			object: Arc{BaseObject: BaseObject{X: 10, Y: 10}, Style: Style{Fill: Hex("#ffffff")}, Radius: 8, EndAngle: 3.14159},
+			filled: []image.Point{{X: 10, Y: 14}},
+			empty:  []image.Point{{X: 10, Y: 6}},
+			alpha:  255,
+		},
+		{
+			name:   "faded",
+			object: Rectangle{BaseObject: BaseObject{X: 4, Y: 4, Color: Hex("#ffffff")}, Style: Style{Opacity: &half}, Width: 12, Height: 12},
+			filled: []image.Point{{X: 10, Y: 10}},
+			alpha:  128,
+		},
+	}
+	for _, test := range tests {
+		ctx := gg.NewContext(20, 20)
+		test.object.Render(ctx)
+		for _, p := range test.filled {
+			if a := alphaAt(ctx, p); a != test.alpha {
+				t.Errorf("%s: Expected alpha %d at %v, got %d", test.name, test.alpha, p, a)
+			}
+		}
+		for _, p := range test.empty {
+			if a := alphaAt(ctx, p); a != 0 {
+				t.Errorf("%s: Expected nothing at %v, got alpha %d", test.name, p, a)
+			}
+		}
+	}
+}
+
+func TestLineCapAndDash(t *testing.T) {
+	width := 4.0
+	line := Line{
+		StartPoint: gg.Point{X: 4, Y: 10},
+		EndPoint:   gg.Point{X: 16, Y: 10},
+		Color:      Hex("#ffffff"),
+		Style:      Style{StrokeWidth: &width, LineCap: LineCapButt},
+	}
+	ctx := gg.NewContext(20, 20)
+	line.Render(ctx)
+	if a := alphaAt(ctx, image.Pt(2, 10)); a != 0 {
+		t.Errorf("Expected a butt cap to stop at the end point, got alpha %d", a)
+	}
+
+	line.LineCap = LineCapSquare
+	ctx = gg.NewContext(20, 20)
+	line.Render(ctx)
+	if a := alphaAt(ctx, image.Pt(2, 10)); a != 255 {
+		t.Errorf("Expected a square cap past the end point, got alpha %d", a)
+	}
+
+	line.LineCap = LineCapButt
+	line.Dash = []float64{4, 4}
+	ctx = gg.NewContext(20, 20)
+	line.Render(ctx)
+	if a := alphaAt(ctx, image.Pt(6, 10)); a != 255 {
+		t.Errorf("Expected the first dash to be drawn, got alpha %d", a)
+	}
+	if a := alphaAt(ctx, image.Pt(10, 10)); a != 0 {
+		t.Errorf("Expected a gap after the first dash, got alpha %d", a)
+	}
+}
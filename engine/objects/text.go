@@ -0,0 +1,351 @@
+package objects
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/fogleman/gg"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+)
+
+const (
+	AlignLeft   = "left"
+	AlignCenter = "center"
+	AlignRight  = "right"
+
+	OverflowWrap     = "wrap"
+	OverflowEllipsis = "ellipsis"
+)
+
+// FontDirs are searched in order for fonts given by name, "6x10" resolves to
+// the first 6x10.bdf, 6x10.ttf or 6x10.otf found.
+var FontDirs = []string{
+	"./fonts",
+	"./rgbmatrix/lib/rpi-rgb-led-matrix/fonts",
+}
+
+// Text draws a string with a BDF bitmap font or a TrueType font. X and Y are
+// the top of the text box at its left edge, center or right edge depending on
+// Align. Lines longer than MaxWidth are wrapped or cut with an ellipsis.
+// Glyphs are filled with Fill, or Color when it isn't set, over an outline of
+// Stroke StrokeWidth pixels wide, so labels stay readable on busy scenes.
+type Text struct {
+	BaseObject
+	Text interface{}
+	// Font is a font name looked up in FontDirs or a path to a .bdf, .ttf or
+	// .otf file, empty uses gg's built-in face.
+	Font string
+	// Size is the point size of TrueType fonts.
+	Size float64
+	// Scale multiplies BDF pixels, keeping them sharp.
+	Scale       float64
+	Align       string
+	MaxWidth    float64
+	Overflow    string
+	LineSpacing float64
+	Fill        Paint
+	Stroke      Paint
+	// StrokeWidth is 1 when unset.
+	StrokeWidth *float64
+	Opacity     *float64
+}
+
+func (t Text) Render(ctx *gg.Context) {
+	face, err := loadTextFace(t.Font, t.Size, t.Scale)
+	if err != nil {
+		fmt.Printf("Error loading font %s: %v\n", t.Font, err)
+		return
+	}
+
+	lines := t.layout(face)
+	lineSpacing := t.LineSpacing
+	if lineSpacing == 0 {
+		lineSpacing = 1
+	}
+
+	opacity := 1.0
+	if t.Opacity != nil {
+		opacity = *t.Opacity
+	}
+	draw := func(dx, dy float64) {
+		baseline := t.Y + face.ascent() + dy
+		for _, line := range lines {
+			x := t.X + dx
+			switch t.Align {
+			case AlignCenter:
+				x -= face.measure(line) / 2
+			case AlignRight:
+				x -= face.measure(line)
+			}
+			face.draw(ctx, line, x, baseline)
+			baseline += face.lineHeight() * lineSpacing
+		}
+	}
+
+	if !t.Stroke.IsZero() {
+		width := 1.0
+		if t.StrokeWidth != nil {
+			width = *t.StrokeWidth
+		}
+		// The outline is the text drawn at every whole pixel offset within
+		// width, strokes of glyph outlines would blur bitmap fonts.
+		setPaint(ctx, t.Stroke, opacity)
+		r := int(math.Round(width))
+		for dy := -r; dy <= r; dy++ {
+			for dx := -r; dx <= r; dx++ {
+				if (dx != 0 || dy != 0) && float64(dx*dx+dy*dy) <= width*width {
+					draw(float64(dx), float64(dy))
+				}
+			}
+		}
+	}
+
+	fill := t.Fill
+	if fill.IsZero() {
+		fill = t.Color
+	}
+	setPaint(ctx, fill, opacity)
+	draw(0, 0)
+}
+
+// layout splits the text into the lines to draw.
+func (t Text) layout(face textFace) []string {
+	var text string
+	switch v := t.Text.(type) {
+	case nil:
+		return nil
+	case string:
+		text = v
+	default:
+		text = fmt.Sprint(v)
+	}
+
+	paragraphs := strings.Split(text, "\n")
+	if t.MaxWidth <= 0 {
+		return paragraphs
+	}
+	if t.Overflow == OverflowEllipsis {
+		return []string{ellipsize(face, paragraphs[0], t.MaxWidth)}
+	}
+	var lines []string
+	for _, paragraph := range paragraphs {
+		lines = append(lines, wrap(face, paragraph, t.MaxWidth)...)
+	}
+	return lines
+}
+
+// wrap breaks a paragraph on spaces so every line fits in width, words wider
+// than width are broken between characters.
+func wrap(face textFace, paragraph string, width float64) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(paragraph) {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if face.measure(candidate) <= width {
+			line = candidate
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+		line = ""
+		for face.measure(word) > width {
+			cut := fitRunes(face, word, width)
+			lines = append(lines, word[:cut])
+			word = word[cut:]
+		}
+		line = word
+	}
+	return append(lines, line)
+}
+
+// ellipsize cuts a line to fit in width, ending it with an ellipsis.
+func ellipsize(face textFace, line string, width float64) string {
+	if face.measure(line) <= width {
+		return line
+	}
+	ellipsis := "…"
+	if !face.has('…') {
+		ellipsis = "..."
+	}
+	available := width - face.measure(ellipsis)
+	if available <= 0 {
+		return ""
+	}
+	return strings.TrimRight(line[:fitRunes(face, line, available)], " ") + ellipsis
+}
+
+// fitRunes returns the byte length of the longest prefix of s that fits in
+// width, always at least one rune so wrapping makes progress.
+func fitRunes(face textFace, s string, width float64) int {
+	cut := 0
+	for i, r := range s {
+		end := i + len(string(r))
+		if cut > 0 && face.measure(s[:end]) > width {
+			break
+		}
+		cut = end
+	}
+	return cut
+}
+
+// textFace is the part of a font Text needs, implemented for BDF and
+// TrueType fonts.
+type textFace interface {
+	measure(s string) float64
+	ascent() float64
+	lineHeight() float64
+	has(r rune) bool
+	draw(ctx *gg.Context, s string, x, baseline float64)
+}
+
+type bdfFace struct {
+	font  *BDFFont
+	scale float64
+}
+
+func (f bdfFace) measure(s string) float64 {
+	width := 0
+	for _, r := range s {
+		if g, _ := f.font.Glyph(r); g != nil {
+			width += g.Advance
+		}
+	}
+	return float64(width) * f.scale
+}
+
+func (f bdfFace) ascent() float64 {
+	return float64(f.font.Ascent) * f.scale
+}
+
+func (f bdfFace) lineHeight() float64 {
+	return float64(f.font.Ascent+f.font.Descent) * f.scale
+}
+
+func (f bdfFace) has(r rune) bool {
+	_, ok := f.font.Glyph(r)
+	return ok
+}
+
+// draw fills every lit pixel as a scale x scale square so glyphs stay
+// pixel-perfect and still follow the context transform.
+func (f bdfFace) draw(ctx *gg.Context, s string, x, baseline float64) {
+	for _, r := range s {
+		g, _ := f.font.Glyph(r)
+		if g == nil {
+			continue
+		}
+		top := baseline - float64(g.OffsetY+g.Height)*f.scale
+		left := x + float64(g.OffsetX)*f.scale
+		for row := 0; row < g.Height && row < len(g.Bitmap); row++ {
+			for col := 0; col < g.Width; col++ {
+				if g.Set(col, row) {
+					ctx.DrawRectangle(left+float64(col)*f.scale, top+float64(row)*f.scale, f.scale, f.scale)
+				}
+			}
+		}
+		x += float64(g.Advance) * f.scale
+	}
+	ctx.Fill()
+}
+
+type trueTypeFace struct {
+	face font.Face
+}
+
+func (f trueTypeFace) measure(s string) float64 {
+	return float64(font.MeasureString(f.face, s)) / 64
+}
+
+func (f trueTypeFace) ascent() float64 {
+	return float64(f.face.Metrics().Ascent) / 64
+}
+
+func (f trueTypeFace) lineHeight() float64 {
+	return float64(f.face.Metrics().Height) / 64
+}
+
+func (f trueTypeFace) has(r rune) bool {
+	_, ok := f.face.GlyphAdvance(r)
+	return ok
+}
+
+func (f trueTypeFace) draw(ctx *gg.Context, s string, x, baseline float64) {
+	ctx.SetFontFace(f.face)
+	ctx.DrawString(s, x, baseline)
+}
+
+var (
+	fontsMu sync.Mutex
+	bdfs    = make(map[string]*BDFFont)
+	faces   = make(map[string]font.Face)
+)
+
+// loadTextFace resolves and caches the font of a Text object.
+func loadTextFace(name string, size, scale float64) (textFace, error) {
+	if scale <= 0 {
+		scale = 1
+	}
+	if size <= 0 {
+		size = 12
+	}
+	if name == "" {
+		return trueTypeFace{face: basicfont.Face7x13}, nil
+	}
+
+	path, err := findFont(name)
+	if err != nil {
+		return nil, err
+	}
+
+	fontsMu.Lock()
+	defer fontsMu.Unlock()
+	if strings.EqualFold(filepath.Ext(path), ".bdf") {
+		font, ok := bdfs[path]
+		if !ok {
+			if font, err = LoadBDF(path); err != nil {
+				return nil, err
+			}
+			bdfs[path] = font
+		}
+		return bdfFace{font: font, scale: scale}, nil
+	}
+
+	key := fmt.Sprintf("%s@%g", path, size)
+	face, ok := faces[key]
+	if !ok {
+		if face, err = gg.LoadFontFace(path, size); err != nil {
+			return nil, err
+		}
+		faces[key] = face
+	}
+	return trueTypeFace{face: face}, nil
+}
+
+func findFont(name string) (string, error) {
+	if filepath.Ext(name) != "" {
+		return name, nil
+	}
+	for _, dir := range FontDirs {
+		for _, ext := range []string{".bdf", ".ttf", ".otf"} {
+			path := filepath.Join(dir, name+ext)
+			if fileExists(path) {
+				return path, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("font %s not found in %s", name, strings.Join(FontDirs, ", "))
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
@@ -0,0 +1,90 @@
+package objects
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fogleman/gg"
+)
+
+const fontDir = "../../rgbmatrix/lib/rpi-rgb-led-matrix/fonts"
+
+func TestLoadBDF(t *testing.T) {
+	font, err := LoadBDF(fontDir + "/6x10.bdf")
+	if err != nil {
+		t.Fatalf("Failed to load font: %v", err)
+	}
+	if font.Ascent != 8 || font.Descent != 2 {
+		t.Errorf("Expected ascent 8 and descent 2, got %d and %d", font.Ascent, font.Descent)
+	}
+	a, ok := font.Glyph('A')
+	if !ok {
+		t.Fatalf("Expected a glyph for A")
+	}
+	if a.Advance != 6 || a.Height != 10 || a.OffsetY != -2 {
+		t.Errorf("Unexpected glyph metrics %+v", a)
+	}
+	// Row 5 of A is the crossbar, 0xF8.
+	for col, lit := range []bool{true, true, true, true, true, false} {
+		if a.Set(col, 5) != lit {
+			t.Errorf("Expected pixel %d of the crossbar to be %v", col, lit)
+		}
+	}
+}
+
+func TestTextLayout(t *testing.T) {
+	font, err := LoadBDF(fontDir + "/6x10.bdf")
+	if err != nil {
+		t.Fatalf("Failed to load font: %v", err)
+	}
+	face := bdfFace{font: font, scale: 1}
+
+	wrapped := Text{Text: "hello there ein", MaxWidth: 36, Overflow: OverflowWrap}.layout(face)
+	if expected := []string{"hello", "there", "ein"}; !reflect.DeepEqual(wrapped, expected) {
+		t.Errorf("Expected %q, got %q", expected, wrapped)
+	}
+
+	broken := Text{Text: "abcdefgh", MaxWidth: 18}.layout(face)
+	if expected := []string{"abc", "def", "gh"}; !reflect.DeepEqual(broken, expected) {
+		t.Errorf("Expected %q, got %q", expected, broken)
+	}
+
+	cut := Text{Text: "listening", MaxWidth: 30, Overflow: OverflowEllipsis}.layout(face)
+	if expected := []string{"list…"}; !reflect.DeepEqual(cut, expected) {
+		t.Errorf("Expected %q, got %q", expected, cut)
+	}
+
+	number := Text{Text: 42.5}.layout(face)
+	if expected := []string{"42.5"}; !reflect.DeepEqual(number, expected) {
+		t.Errorf("Expected %q, got %q", expected, number)
+	}
+}
+
+func TestTextStroke(t *testing.T) {
+	ctx := gg.NewContext(20, 20)
+	Text{
+		BaseObject: BaseObject{X: 6, Y: 4},
+		Text:       "I",
+		Font:       fontDir + "/6x10.bdf",
+		Fill:       Hex("#ffffff"),
+		Stroke:     Hex("#ff0000"),
+	}.Render(ctx)
+
+	// Left of the first white pixel of every row is the red outline.
+	white := 0
+	for y := 0; y < 20; y++ {
+		for x := 1; x < 20; x++ {
+			if r, g, b, _ := ctx.Image().At(x, y).RGBA(); r>>8 != 255 || g>>8 != 255 || b>>8 != 255 {
+				continue
+			}
+			white++
+			if r, g, _, a := ctx.Image().At(x-1, y).RGBA(); r>>8 != 255 || g != 0 || a>>8 != 255 {
+				t.Errorf("Expected the red outline at %d,%d, got %d,%d,%d", x-1, y, r>>8, g>>8, a>>8)
+			}
+			break
+		}
+	}
+	if white == 0 {
+		t.Error("Expected the glyph filled white")
+	}
+}
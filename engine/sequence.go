@@ -0,0 +1,292 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DefaultStateVariable is the env variable holding the current state of a
+// state machine.
+const DefaultStateVariable = "state"
+
+// StateMachine plays the animations of the current state. The state is read
+// from an env variable, so expressions can use it and setting it, from a
+// keyframe or with SetState, switches state on the next frame.
+type StateMachine struct {
+	// Variable is the env variable of the state, DefaultStateVariable when
+	// empty.
+	Variable string `yaml:"variable"`
+	// Initial is the state the scene starts in when env doesn't set the
+	// variable.
+	Initial string           `yaml:"initial"`
+	States  map[string]State `yaml:"states"`
+	// Transitions play an animation between two states before the animations
+	// of the new state start. The first match wins, "*" matches any state.
+	Transitions []Transition `yaml:"transitions"`
+}
+
+type State struct {
+	Animations []string `yaml:"animations"`
+}
+
+type Transition struct {
+	From      string `yaml:"from"`
+	To        string `yaml:"to"`
+	Animation string `yaml:"animation"`
+}
+
+func (m *StateMachine) variable() string {
+	if m.Variable == "" {
+		return DefaultStateVariable
+	}
+	return m.Variable
+}
+
+func (m *StateMachine) transitionFor(from, to string) *Transition {
+	for i := range m.Transitions {
+		t := &m.Transitions[i]
+		if (t.From == from || t.From == "*") && (t.To == to || t.To == "*") {
+			return t
+		}
+	}
+	return nil
+}
+
+// Start plays the named animation from its beginning on the next frame,
+// restarting it when it is already playing.
+func (scene *Scene) Start(name string) error {
+	animation := scene.animation(name)
+	if animation == nil {
+		return fmt.Errorf("unknown animation %q", name)
+	}
+	scene.start(animation)
+	return nil
+}
+
+// Stop stops the named animation where it is, leaving its values in env.
+func (scene *Scene) Stop(name string) error {
+	animation := scene.animation(name)
+	if animation == nil {
+		return fmt.Errorf("unknown animation %q", name)
+	}
+	scene.stop(animation)
+	return nil
+}
+
+// SetState switches the state machine to state on the next frame.
+func (scene *Scene) SetState(state string) error {
+	if scene.StateMachine == nil {
+		return errors.New("scene has no state machine")
+	}
+	if _, ok := scene.StateMachine.States[state]; !ok {
+		return fmt.Errorf("unknown state %q", state)
+	}
+	if scene.Env == nil {
+		scene.Env = make(map[string]interface{})
+	}
+	scene.Env[scene.StateMachine.variable()] = state
+	return nil
+}
+
+// State is the current state of the state machine, empty without one.
+func (scene *Scene) State() string {
+	return scene.state
+}
+
+// Playing lists the animations that are playing, in scene order.
+func (scene *Scene) Playing() []string {
+	playing := []string{}
+	for _, animation := range scene.Animations {
+		if animation.state.playing {
+			playing = append(playing, animation.Name)
+		}
+	}
+	return playing
+}
+
+func (scene *Scene) animation(name string) *AnimationWrapper {
+	for i := range scene.Animations {
+		if scene.Animations[i].Name == name {
+			return &scene.Animations[i]
+		}
+	}
+	return nil
+}
+
+// sequence starts and stops animations before a frame: the ones that
+// autoplay on the first frame, the ones whose when condition changed and
+// the ones of the state machine when its state changed.
+func (scene *Scene) sequence() {
+	if !scene.sequenced {
+		scene.sequenced = true
+		for i := range scene.Animations {
+			animation := &scene.Animations[i]
+			if !animation.state.playing && scene.autoplays(animation) {
+				scene.start(animation)
+			}
+		}
+	}
+
+	for i := range scene.Animations {
+		animation := &scene.Animations[i]
+		if animation.When == "" {
+			continue
+		}
+		value, err := scene.evaluate(animation.When)
+		if err != nil {
+			continue
+		}
+		on := value == true
+		if on && !animation.whenWas {
+			scene.start(animation)
+		} else if !on && animation.whenWas && animation.state.repeat {
+			scene.stop(animation)
+		}
+		animation.whenWas = on
+	}
+
+	scene.switchState()
+}
+
+// autoplays tells whether animation starts with the scene. Unless set it
+// does when it isn't started by after, when, a parallel group or the state
+// machine.
+func (scene *Scene) autoplays(animation *AnimationWrapper) bool {
+	if animation.Autoplay != nil {
+		return *animation.Autoplay
+	}
+	if animation.After != "" || animation.When != "" {
+		return false
+	}
+	for _, other := range scene.Animations {
+		if contains(other.Parallel, animation.Name) {
+			return false
+		}
+	}
+	if machine := scene.StateMachine; machine != nil {
+		for _, state := range machine.States {
+			if contains(state.Animations, animation.Name) {
+				return false
+			}
+		}
+		for _, t := range machine.Transitions {
+			if t.Animation == animation.Name {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// switchState stops the animations of the previous state when the state
+// variable changed, then plays the transition or the new state.
+func (scene *Scene) switchState() {
+	machine := scene.StateMachine
+	if machine == nil {
+		return
+	}
+	next, _ := scene.Env[machine.variable()].(string)
+	if next == scene.state {
+		return
+	}
+	for _, name := range machine.States[scene.state].Animations {
+		scene.Stop(name)
+	}
+	if scene.transition != nil {
+		scene.Stop(scene.transition.Animation)
+		scene.transition = nil
+	}
+
+	previous := scene.state
+	scene.state = next
+	// The initial state is entered without a transition.
+	if previous != "" {
+		if t := machine.transitionFor(previous, next); t != nil {
+			if animation := scene.animation(t.Animation); animation != nil {
+				scene.transition = t
+				scene.start(animation)
+				return
+			}
+		}
+	}
+	scene.enter(next)
+}
+
+func (scene *Scene) enter(state string) {
+	for _, name := range scene.StateMachine.States[state].Animations {
+		scene.Start(name)
+	}
+}
+
+func (scene *Scene) start(animation *AnimationWrapper) {
+	scene.startGroup(animation, make(map[*AnimationWrapper]bool))
+}
+
+// startGroup starts animation and its parallel members, started keeps a
+// group that plays itself through others from starting forever.
+func (scene *Scene) startGroup(animation *AnimationWrapper, started map[*AnimationWrapper]bool) {
+	if started[animation] {
+		return
+	}
+	started[animation] = true
+	animation.state = animationState{playing: true}
+	for _, name := range animation.Parallel {
+		if member := scene.animation(name); member != nil {
+			scene.startGroup(member, started)
+		}
+	}
+}
+
+func (scene *Scene) stop(animation *AnimationWrapper) {
+	scene.stopGroup(animation, make(map[*AnimationWrapper]bool))
+}
+
+func (scene *Scene) stopGroup(animation *AnimationWrapper, stopped map[*AnimationWrapper]bool) {
+	if stopped[animation] {
+		return
+	}
+	stopped[animation] = true
+	animation.state.playing = false
+	for _, name := range animation.Parallel {
+		if member := scene.animation(name); member != nil {
+			scene.stopGroup(member, stopped)
+		}
+	}
+}
+
+// finish ends a run of animation and starts what waits for it: animations
+// playing after it, the state following a transition and, through their
+// own finish, the parallel groups it was the last one playing in.
+func (scene *Scene) finish(animation *AnimationWrapper) {
+	scene.finishGroup(animation, make(map[*AnimationWrapper]bool))
+}
+
+func (scene *Scene) finishGroup(animation *AnimationWrapper, finished map[*AnimationWrapper]bool) {
+	if finished[animation] {
+		return
+	}
+	finished[animation] = true
+	animation.state.playing = false
+	for i := range scene.Animations {
+		other := &scene.Animations[i]
+		if other.After == animation.Name {
+			scene.start(other)
+		}
+		if other.state.playing && contains(other.Parallel, animation.Name) && !scene.anyPlaying(other.Parallel) {
+			scene.finishGroup(other, finished)
+		}
+	}
+	if scene.transition != nil && scene.transition.Animation == animation.Name {
+		scene.transition = nil
+		scene.enter(scene.state)
+	}
+}
+
+func (scene *Scene) anyPlaying(names []string) bool {
+	for _, name := range names {
+		if animation := scene.animation(name); animation != nil && animation.state.playing {
+			return true
+		}
+	}
+	return false
+}
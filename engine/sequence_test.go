@@ -0,0 +1,251 @@
+package engine
+
+import (
+	"testing"
+	"time"
+)
+
+const sequenceScene = `version: 1
+frame:
+  width: 8
+  height: 8
+env:
+  a: 0
+  b: 0
+  c: 0
+  d: 0
+  mood: calm
+animations:
+  - name: both
+    parallel: [first, flash]
+  - name: first
+    keyframes:
+      - time: 0
+        properties:
+          a: 0
+      - time: 0.1
+        properties:
+          a: 1.0
+  - name: flash
+    keyframes:
+      - time: 0.05
+        properties:
+          d: 1
+  - name: second
+    after: both
+    keyframes:
+      - time: 0
+        properties:
+          b: 0
+      - time: 0.1
+        properties:
+          b: 1.0
+  - name: pulse
+    when: mood == 'happy'
+    repeat: "true"
+    duration: "1"
+    keyframes:
+      - time: 0
+        properties:
+          c: 0
+      - time: 1
+        properties:
+          c: 10
+`
+
+// play computes the animations every 10ms from start until at.
+func play(scene *Scene, start time.Time, at time.Duration) {
+	for t := time.Duration(0); t <= at; t += 10 * time.Millisecond {
+		scene.ComputeAnimations(start.Add(t))
+	}
+}
+
+func TestSequenceAfter(t *testing.T) {
+	scene, err := ParseScene([]byte(sequenceScene))
+	if err != nil {
+		t.Fatalf("Failed to parse scene: %v", err)
+	}
+	start := time.Unix(0, 0)
+	play(scene, start, 50*time.Millisecond)
+	if scene.Env["a"] != 0.5 || scene.Env["d"] != 1 || scene.Env["b"] != 0 {
+		t.Errorf("Expected first and flash to play together, got a %v d %v b %v", scene.Env["a"], scene.Env["d"], scene.Env["b"])
+	}
+	play(scene, start, 300*time.Millisecond)
+	if scene.Env["a"] != 1.0 || scene.Env["b"] != 1.0 {
+		t.Errorf("Expected second to play after both, got a %v b %v", scene.Env["a"], scene.Env["b"])
+	}
+	if scene.animation("both").state.playing {
+		t.Errorf("Expected the parallel group to finish with its animations")
+	}
+}
+
+func TestSequenceStartStop(t *testing.T) {
+	scene, err := ParseScene([]byte(sequenceScene))
+	if err != nil {
+		t.Fatalf("Failed to parse scene: %v", err)
+	}
+	start := time.Unix(0, 0)
+	play(scene, start, 300*time.Millisecond)
+	if err := scene.Start("second"); err != nil {
+		t.Fatalf("Failed to start second: %v", err)
+	}
+	scene.ComputeAnimations(start.Add(time.Second))
+	scene.ComputeAnimations(start.Add(time.Second + 50*time.Millisecond))
+	if scene.Env["b"] != 0.5 {
+		t.Errorf("Expected second to restart, got b %v", scene.Env["b"])
+	}
+	if err := scene.Stop("second"); err != nil {
+		t.Fatalf("Failed to stop second: %v", err)
+	}
+	scene.ComputeAnimations(start.Add(2 * time.Second))
+	if scene.Env["b"] != 0.5 {
+		t.Errorf("Expected second to stay where it stopped, got b %v", scene.Env["b"])
+	}
+	if err := scene.Start("third"); err == nil {
+		t.Errorf("Expected an error starting an unknown animation")
+	}
+}
+
+func TestSequenceWhen(t *testing.T) {
+	scene, err := ParseScene([]byte(sequenceScene))
+	if err != nil {
+		t.Fatalf("Failed to parse scene: %v", err)
+	}
+	start := time.Unix(0, 0)
+	scene.ComputeAnimations(start)
+	scene.Env["mood"] = "happy"
+	scene.ComputeAnimations(start.Add(time.Second))
+	scene.ComputeAnimations(start.Add(1500 * time.Millisecond))
+	if scene.Env["c"] != 5 {
+		t.Errorf("Expected pulse to play once mood is happy, got c %v", scene.Env["c"])
+	}
+	scene.Env["mood"] = "calm"
+	scene.ComputeAnimations(start.Add(1700 * time.Millisecond))
+	if scene.Env["c"] != 5 {
+		t.Errorf("Expected pulse to stop once mood isn't happy, got c %v", scene.Env["c"])
+	}
+}
+
+// parallelCycleScene didn't pass validation, the engine must still not
+// recurse forever through its groups.
+const parallelCycleScene = `version: 1
+frame:
+  width: 8
+  height: 8
+env:
+  a: 0
+animations:
+  - name: look
+    parallel: [turn, nod]
+  - name: turn
+    parallel: [look]
+  - name: nod
+    keyframes:
+      - time: 0
+        properties:
+          a: 0
+      - time: 0.1
+        properties:
+          a: 1.0
+`
+
+func TestSequenceParallelCycle(t *testing.T) {
+	scene, err := ParseScene([]byte(parallelCycleScene))
+	if err != nil {
+		t.Fatalf("Failed to parse scene: %v", err)
+	}
+	start := time.Unix(0, 0)
+	scene.ComputeAnimations(start)
+	if err := scene.Start("turn"); err != nil {
+		t.Fatalf("Failed to start turn: %v", err)
+	}
+	if !scene.animation("look").state.playing || !scene.animation("nod").state.playing {
+		t.Errorf("Expected turn to start look and nod")
+	}
+	play(scene, start, 50*time.Millisecond)
+	if scene.Env["a"] != 0.5 {
+		t.Errorf("Expected nod to play, got a %v", scene.Env["a"])
+	}
+	if err := scene.Stop("look"); err != nil {
+		t.Fatalf("Failed to stop look: %v", err)
+	}
+	if scene.animation("turn").state.playing || scene.animation("nod").state.playing {
+		t.Errorf("Expected look to stop turn and nod")
+	}
+}
+
+const stateMachineScene = `version: 1
+frame:
+  width: 8
+  height: 8
+env:
+  eyes: 0
+  brows: 0
+animations:
+  - name: breathe
+    repeat: "true"
+    duration: "1"
+    keyframes:
+      - time: 0
+        properties:
+          eyes: 0
+      - time: 1
+        properties:
+          eyes: 10
+  - name: perk
+    keyframes:
+      - time: 0
+        properties:
+          brows: 0
+      - time: 0.1
+        properties:
+          brows: 5.0
+  - name: think
+    keyframes:
+      - time: 0
+        properties:
+          eyes: 20
+stateMachine:
+  initial: idle
+  states:
+    idle:
+      animations: [breathe]
+    thinking:
+      animations: [think]
+  transitions:
+    - from: "*"
+      to: thinking
+      animation: perk
+`
+
+func TestStateMachine(t *testing.T) {
+	scene, err := ParseScene([]byte(stateMachineScene))
+	if err != nil {
+		t.Fatalf("Failed to parse scene: %v", err)
+	}
+	start := time.Unix(0, 0)
+	scene.ComputeAnimations(start)
+	scene.ComputeAnimations(start.Add(500 * time.Millisecond))
+	if scene.State() != "idle" || scene.Env["eyes"] != 5 {
+		t.Fatalf("Expected to breathe in the idle state, got %q with eyes %v", scene.State(), scene.Env["eyes"])
+	}
+
+	if err := scene.SetState("thinking"); err != nil {
+		t.Fatalf("Failed to set state: %v", err)
+	}
+	scene.ComputeAnimations(start.Add(600 * time.Millisecond))
+	scene.ComputeAnimations(start.Add(650 * time.Millisecond))
+	if scene.Env["brows"] != 2.5 || scene.Env["eyes"] != 5 {
+		t.Errorf("Expected the transition to play alone, got brows %v eyes %v", scene.Env["brows"], scene.Env["eyes"])
+	}
+	for at := 700 * time.Millisecond; at <= 800*time.Millisecond; at += 50 * time.Millisecond {
+		scene.ComputeAnimations(start.Add(at))
+	}
+	if scene.State() != "thinking" || scene.Env["eyes"] != 20 {
+		t.Errorf("Expected the thinking state after the transition, got %q with eyes %v", scene.State(), scene.Env["eyes"])
+	}
+
+	if err := scene.SetState("dreaming"); err == nil {
+		t.Errorf("Expected an error setting an unknown state")
+	}
+}
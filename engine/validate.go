@@ -0,0 +1,898 @@
+package engine
+
+import (
+	"einclient/engine/objects"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/expr-lang/expr"
+	"gopkg.in/yaml.v3"
+)
+
+// ValidationError is a single problem in a scene file.
+type ValidationError struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Message)
+}
+
+// ValidationErrors is every problem found in a scene file, in file order.
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+type propertyKind int
+
+const (
+	// propertyExpression is a YAML scalar, strings are expr expressions.
+	propertyExpression propertyKind = iota
+	// propertyPoint is a mapping with x and y expressions.
+	propertyPoint
+	// propertyPoints is a list of points, or one expression giving them.
+	propertyPoints
+	// propertyLiteral is a YAML scalar used as written, limited to values
+	// when they are set.
+	propertyLiteral
+	// propertyExpressions is a list of expressions, or one expression giving
+	// the whole list.
+	propertyExpressions
+	// propertyColor is an expression or a gradient mapping.
+	propertyColor
+	// propertyPathData is a literal SVG path data string.
+	propertyPathData
+	// propertySegments is a list of path segments, or one expression giving
+	// them or SVG path data.
+	propertySegments
+)
+
+type propertySpec struct {
+	kind     propertyKind
+	required bool
+	values   []string
+}
+
+// literal reports whether a property is taken as written instead of being
+// evaluated as an expression.
+func (spec propertySpec) literal() bool {
+	return spec.kind == propertyLiteral || spec.kind == propertyPathData
+}
+
+// typedSchema is what evaluating gradients and path segments needs of their
+// schemas: their type is taken as written, everything else is evaluated.
+var typedSchema = map[string]propertySpec{"type": {kind: propertyLiteral}}
+
+// nested returns the schema of the mappings a property holds.
+func (spec propertySpec) nested() map[string]propertySpec {
+	switch spec.kind {
+	case propertyColor, propertySegments:
+		return typedSchema
+	}
+	return nil
+}
+
+var (
+	requiredExpression = propertySpec{kind: propertyExpression, required: true}
+	optionalExpression = propertySpec{kind: propertyExpression}
+	optionalColor      = propertySpec{kind: propertyColor}
+)
+
+// withStyle adds the paint properties of objects.Style to a schema.
+func withStyle(schema map[string]propertySpec) map[string]propertySpec {
+	schema["fill"] = optionalColor
+	schema["stroke"] = optionalColor
+	schema["strokeWidth"] = optionalExpression
+	schema["lineCap"] = propertySpec{kind: propertyLiteral, values: []string{objects.LineCapButt, objects.LineCapRound, objects.LineCapSquare}}
+	schema["lineJoin"] = propertySpec{kind: propertyLiteral, values: []string{objects.LineJoinRound, objects.LineJoinBevel}}
+	schema["dash"] = propertySpec{kind: propertyExpressions}
+	schema["dashOffset"] = optionalExpression
+	schema["opacity"] = optionalExpression
+	return schema
+}
+
+// objectSchemas lists the properties every object type understands, keys are
+// matched case-insensitively like the JSON decoding of the objects.
+var objectSchemas = map[string]map[string]propertySpec{
+	ObjectTypeCircle: withStyle(map[string]propertySpec{
+		"x":      requiredExpression,
+		"y":      requiredExpression,
+		"radius": requiredExpression,
+		"color":  optionalColor,
+	}),
+	ObjectTypeRectangle: withStyle(map[string]propertySpec{
+		"x":      requiredExpression,
+		"y":      requiredExpression,
+		"width":  requiredExpression,
+		"height": requiredExpression,
+		"color":  optionalColor,
+	}),
+	ObjectTypeArc: withStyle(map[string]propertySpec{
+		"x":          requiredExpression,
+		"y":          requiredExpression,
+		"radius":     requiredExpression,
+		"startAngle": requiredExpression,
+		"endAngle":   requiredExpression,
+		"color":      optionalColor,
+	}),
+	ObjectTypeLine: withStyle(map[string]propertySpec{
+		"startPoint": {kind: propertyPoint, required: true},
+		"endPoint":   {kind: propertyPoint, required: true},
+		"color":      optionalColor,
+	}),
+	ObjectTypeSimplePolygon: withStyle(map[string]propertySpec{
+		"n":        requiredExpression,
+		"x":        requiredExpression,
+		"y":        requiredExpression,
+		"r":        requiredExpression,
+		"rotation": optionalExpression,
+		"color":    optionalColor,
+	}),
+	ObjectTypePolygon: withStyle(map[string]propertySpec{
+		"points": {kind: propertyPoints, required: true},
+		"color":  optionalColor,
+	}),
+	ObjectTypePath: withStyle(map[string]propertySpec{
+		"d":        {kind: propertyPathData},
+		"segments": {kind: propertySegments},
+		"color":    optionalColor,
+	}),
+	ObjectTypeText: {
+		"x":           requiredExpression,
+		"y":           requiredExpression,
+		"text":        requiredExpression,
+		"color":       optionalColor,
+		"font":        {kind: propertyLiteral},
+		"size":        optionalExpression,
+		"scale":       optionalExpression,
+		"align":       {kind: propertyLiteral, values: []string{objects.AlignLeft, objects.AlignCenter, objects.AlignRight}},
+		"maxWidth":    optionalExpression,
+		"overflow":    {kind: propertyLiteral, values: []string{objects.OverflowWrap, objects.OverflowEllipsis}},
+		"lineSpacing": optionalExpression,
+		"fill":        optionalColor,
+		"stroke":      optionalColor,
+		"strokeWidth": optionalExpression,
+		"opacity":     optionalExpression,
+	},
+	ObjectTypeImage: {
+		"x":        requiredExpression,
+		"y":        requiredExpression,
+		"src":      {kind: propertyLiteral, required: true},
+		"width":    optionalExpression,
+		"height":   optionalExpression,
+		"rotation": optionalExpression,
+		"opacity":  optionalExpression,
+		"frame":    optionalExpression,
+	},
+	ObjectTypeGroup: {
+		"x":        optionalExpression,
+		"y":        optionalExpression,
+		"rotation": optionalExpression,
+		"scale":    optionalExpression,
+		"scaleX":   optionalExpression,
+		"scaleY":   optionalExpression,
+		"originX":  optionalExpression,
+		"originY":  optionalExpression,
+		"opacity":  optionalExpression,
+	},
+}
+
+// gradientSchemas lists the coordinates of every gradient type besides its
+// type and stops.
+var gradientSchemas = map[string]map[string]propertySpec{
+	objects.GradientLinear: {
+		"x0": requiredExpression,
+		"y0": requiredExpression,
+		"x1": requiredExpression,
+		"y1": requiredExpression,
+	},
+	objects.GradientRadial: {
+		"x":  requiredExpression,
+		"y":  requiredExpression,
+		"r":  requiredExpression,
+		"fx": optionalExpression,
+		"fy": optionalExpression,
+		"fr": optionalExpression,
+	},
+	objects.GradientConic: {
+		"x":     requiredExpression,
+		"y":     requiredExpression,
+		"angle": optionalExpression,
+	},
+}
+
+// segmentSchemas lists the properties of every path segment type besides
+// its type.
+var segmentSchemas = map[string]map[string]propertySpec{
+	objects.SegmentMove: {
+		"x": requiredExpression,
+		"y": requiredExpression,
+	},
+	objects.SegmentLine: {
+		"x": requiredExpression,
+		"y": requiredExpression,
+	},
+	objects.SegmentCubic: {
+		"x1": requiredExpression,
+		"y1": requiredExpression,
+		"x2": requiredExpression,
+		"y2": requiredExpression,
+		"x":  requiredExpression,
+		"y":  requiredExpression,
+	},
+	objects.SegmentQuad: {
+		"x1": requiredExpression,
+		"y1": requiredExpression,
+		"x":  requiredExpression,
+		"y":  requiredExpression,
+	},
+	objects.SegmentArc: {
+		"rx":       requiredExpression,
+		"ry":       requiredExpression,
+		"rotation": optionalExpression,
+		"largeArc": optionalExpression,
+		"sweep":    optionalExpression,
+		"x":        requiredExpression,
+		"y":        requiredExpression,
+	},
+	objects.SegmentClose: {},
+}
+
+var (
+	sceneKeys      = []string{"version", "seed", "env", "frame", "objects", "animations", "stateMachine"}
+	frameKeys      = []string{"width", "height", "background", "clear", "decay"}
+	objectKeys     = []string{"name", "type", "properties", "objects"}
+	animationKeys  = []string{"name", "duration", "repeat", "delay", "easing", "colorSpace", "keyframes", "after", "when", "parallel", "autoplay"}
+	keyframeKeys   = []string{"time", "easing", "properties"}
+	machineKeys    = []string{"variable", "initial", "states", "transitions"}
+	stateKeys      = []string{"animations"}
+	transitionKeys = []string{"from", "to", "animation"}
+	pointKeys      = []string{"x", "y"}
+	stopKeys       = []string{"offset", "color"}
+)
+
+// ValidateScene checks a scene file before it is ever rendered and reports
+// every problem found with its position in file.
+func ValidateScene(file string, data []byte) error {
+	doc, err := expandScene(file, data)
+	if err != nil {
+		return wrapFileError(file, err)
+	}
+	return validateExpanded(file, doc)
+}
+
+// validateExpanded validates a scene once its includes and components are
+// expanded, errors point into the file each node comes from.
+func validateExpanded(file string, doc *expandedScene) error {
+	scene, err := decodeScene(doc.root)
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+
+	env := scene.Env
+	if env == nil {
+		env = map[string]interface{}{}
+	}
+	v := &validator{
+		file:    file,
+		origins: doc.origins,
+		env:     env,
+		options: append(scene.functions(), expr.Env(env)),
+	}
+	v.scene(doc.root)
+	if len(v.errors) > 0 {
+		v.errors.sort()
+		return v.errors
+	}
+	return nil
+}
+
+// wrapFileError prefixes errors that have no position with the file name.
+func wrapFileError(file string, err error) error {
+	if _, ok := err.(ValidationErrors); ok {
+		return err
+	}
+	return fmt.Errorf("%s: %w", file, err)
+}
+
+func (e ValidationErrors) sort() {
+	sort.SliceStable(e, func(i, j int) bool {
+		if e[i].File != e[j].File {
+			return e[i].File < e[j].File
+		}
+		if e[i].Line != e[j].Line {
+			return e[i].Line < e[j].Line
+		}
+		return e[i].Column < e[j].Column
+	})
+}
+
+type validator struct {
+	file    string
+	origins map[*yaml.Node]string
+	env     map[string]interface{}
+	options []expr.Option
+	errors  ValidationErrors
+	// animations are the names of the scene's animations.
+	animations map[string]bool
+}
+
+func (v *validator) errorf(node *yaml.Node, format string, args ...interface{}) {
+	file := v.origins[node]
+	if file == "" {
+		file = v.file
+	}
+	v.errors = append(v.errors, &ValidationError{
+		File:    file,
+		Line:    node.Line,
+		Column:  node.Column,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// mapping returns the key and value nodes of a mapping, reporting keys that
+// aren't in allowed when it is set.
+func (v *validator) mapping(node *yaml.Node, what string, allowed []string) map[string][2]*yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		v.errorf(node, "%s must be a mapping", what)
+		return nil
+	}
+	pairs := make(map[string][2]*yaml.Node)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if allowed != nil && !contains(allowed, key.Value) {
+			v.errorf(key, "unknown %s key %q", what, key.Value)
+			continue
+		}
+		pairs[key.Value] = [2]*yaml.Node{key, value}
+	}
+	return pairs
+}
+
+func (v *validator) sequence(node *yaml.Node, what string) []*yaml.Node {
+	if node.Kind != yaml.SequenceNode {
+		v.errorf(node, "%s must be a list", what)
+		return nil
+	}
+	return node.Content
+}
+
+func (v *validator) scene(node *yaml.Node) {
+	pairs := v.mapping(node, "scene", sceneKeys)
+	if pair, ok := pairs["frame"]; ok {
+		frame := v.mapping(pair[1], "frame", frameKeys)
+		for _, key := range []string{"width", "height"} {
+			if p, ok := frame[key]; !ok {
+				v.errorf(pair[0], "frame is missing %s", key)
+			} else if p[1].Tag != "!!int" || p[1].Value == "0" || strings.HasPrefix(p[1].Value, "-") {
+				v.errorf(p[1], "frame %s must be a positive integer", key)
+			}
+		}
+		if p, ok := frame["background"]; ok {
+			v.background(p[1])
+		}
+		if p, ok := frame["clear"]; ok {
+			v.property(p[1], propertySpec{kind: propertyLiteral, values: []string{ClearModeClear, ClearModePersist, ClearModeFade}})
+		}
+		if p, ok := frame["decay"]; ok {
+			v.expression(p[1])
+		}
+	} else {
+		v.errorf(node, "scene is missing frame")
+	}
+	if pair, ok := pairs["env"]; ok {
+		v.mapping(pair[1], "env", nil)
+	}
+	if pair, ok := pairs["objects"]; ok {
+		for _, object := range v.sequence(pair[1], "objects") {
+			v.object(object)
+		}
+	}
+	if pair, ok := pairs["animations"]; ok {
+		animations := v.sequence(pair[1], "animations")
+		v.animations = make(map[string]bool)
+		for _, animation := range animations {
+			if animation.Kind != yaml.MappingNode {
+				continue
+			}
+			for i := 0; i+1 < len(animation.Content); i += 2 {
+				if name := animation.Content[i+1]; animation.Content[i].Value == "name" {
+					if v.animations[name.Value] {
+						v.errorf(name, "duplicate animation name %q", name.Value)
+					}
+					v.animations[name.Value] = true
+				}
+			}
+		}
+		for _, animation := range animations {
+			v.animation(animation)
+		}
+		v.parallelCycles(animations)
+	}
+	if pair, ok := pairs["stateMachine"]; ok {
+		v.stateMachine(pair[1])
+	}
+}
+
+// animationName checks that node names an animation of the scene.
+func (v *validator) animationName(node *yaml.Node) {
+	if node.Kind != yaml.ScalarNode {
+		v.errorf(node, "expected an animation name")
+	} else if !v.animations[node.Value] {
+		v.errorf(node, "unknown animation %q", node.Value)
+	}
+}
+
+// parallelCycles reports parallel groups that play themselves through other
+// groups, which would start them forever.
+func (v *validator) parallelCycles(animations []*yaml.Node) {
+	members := make(map[string][]*yaml.Node)
+	var names []string
+	for _, animation := range animations {
+		name, parallel := mappingValue(animation, "name"), mappingValue(animation, "parallel")
+		if name == nil || parallel == nil || parallel.Kind != yaml.SequenceNode {
+			continue
+		}
+		if _, ok := members[name.Value]; !ok {
+			names = append(names, name.Value)
+		}
+		members[name.Value] = append(members[name.Value], parallel.Content...)
+	}
+	// Groups on the path are visiting, groups that were fully walked are done.
+	const visiting, done = 1, 2
+	marks := make(map[string]int)
+	var path []string
+	var walk func(name string)
+	walk = func(name string) {
+		marks[name] = visiting
+		path = append(path, name)
+		for _, member := range members[name] {
+			// A group listing itself is reported by animation.
+			if member.Value == name {
+				continue
+			}
+			switch marks[member.Value] {
+			case visiting:
+				from := len(path) - 1
+				for path[from] != member.Value {
+					from--
+				}
+				cycle := strings.Join(path[from:], " -> ") + " -> " + member.Value
+				v.errorf(member, "parallel animations form a cycle: %s", cycle)
+			case 0:
+				walk(member.Value)
+			}
+		}
+		path = path[:len(path)-1]
+		marks[name] = done
+	}
+	for _, name := range names {
+		if marks[name] == 0 {
+			walk(name)
+		}
+	}
+}
+
+func (v *validator) stateMachine(node *yaml.Node) {
+	pairs := v.mapping(node, "stateMachine", machineKeys)
+	if pairs == nil {
+		return
+	}
+	states := make(map[string]bool)
+	if pair, ok := pairs["states"]; ok {
+		for name, state := range v.mapping(pair[1], "states", nil) {
+			states[name] = true
+			if animations, ok := v.mapping(state[1], "state", stateKeys)["animations"]; ok {
+				for _, animation := range v.sequence(animations[1], "state animations") {
+					v.animationName(animation)
+				}
+			}
+		}
+	} else {
+		v.errorf(node, "stateMachine is missing states")
+	}
+	if pair, ok := pairs["initial"]; ok && !states[pair[1].Value] {
+		v.errorf(pair[1], "unknown initial state %q", pair[1].Value)
+	}
+	if pair, ok := pairs["transitions"]; ok {
+		for _, transition := range v.sequence(pair[1], "transitions") {
+			t := v.mapping(transition, "transition", transitionKeys)
+			if t == nil {
+				continue
+			}
+			for _, key := range []string{"from", "to"} {
+				if p, ok := t[key]; !ok {
+					v.errorf(transition, "transition is missing %s", key)
+				} else if p[1].Value != "*" && !states[p[1].Value] {
+					v.errorf(p[1], "unknown state %q", p[1].Value)
+				}
+			}
+			if p, ok := t["animation"]; ok {
+				v.animationName(p[1])
+			} else {
+				v.errorf(transition, "transition is missing animation")
+			}
+		}
+	}
+}
+
+func (v *validator) object(node *yaml.Node) {
+	pairs := v.mapping(node, "object", objectKeys)
+	if pairs == nil {
+		return
+	}
+	typePair, ok := pairs["type"]
+	if !ok {
+		v.errorf(node, "object is missing type")
+		return
+	}
+	schema, ok := objectSchemas[typePair[1].Value]
+	if !ok {
+		v.errorf(typePair[1], "unknown object type %q, expected one of %s", typePair[1].Value, strings.Join(objectTypeNames(), ", "))
+		return
+	}
+
+	objectsPair, hasObjects := pairs["objects"]
+	if typePair[1].Value == ObjectTypeGroup {
+		if !hasObjects {
+			v.errorf(node, "group object is missing objects")
+		} else {
+			for _, child := range v.sequence(objectsPair[1], "objects") {
+				v.object(child)
+			}
+		}
+		if propertiesPair, ok := pairs["properties"]; ok {
+			v.properties(propertiesPair[0], propertiesPair[1], typePair[1].Value, schema)
+		}
+		return
+	}
+	if hasObjects {
+		v.errorf(objectsPair[0], "only group objects have objects")
+	}
+
+	propertiesPair, ok := pairs["properties"]
+	if !ok {
+		v.errorf(node, "%s object is missing properties", typePair[1].Value)
+		return
+	}
+	v.properties(propertiesPair[0], propertiesPair[1], typePair[1].Value, schema)
+	if typePair[1].Value == ObjectTypePath && mappingValue(propertiesPair[1], "d") == nil && mappingValue(propertiesPair[1], "segments") == nil {
+		v.errorf(propertiesPair[0], "path object needs d or segments")
+	}
+}
+
+func (v *validator) properties(key, node *yaml.Node, objectType string, schema map[string]propertySpec) {
+	properties := v.mapping(node, "properties", nil)
+	if properties == nil {
+		return
+	}
+	seen := make(map[string]bool)
+	for name, pair := range properties {
+		spec, specName, ok := lookupProperty(schema, name)
+		if !ok {
+			v.errorf(pair[0], "unknown %s property %q", objectType, name)
+			continue
+		}
+		seen[specName] = true
+		v.property(pair[1], spec)
+	}
+	for _, name := range sortedKeys(schema) {
+		if schema[name].required && !seen[name] {
+			v.errorf(key, "%s object is missing property %q", objectType, name)
+		}
+	}
+}
+
+func (v *validator) property(node *yaml.Node, spec propertySpec) {
+	switch spec.kind {
+	case propertyExpression:
+		v.expression(node)
+	case propertyLiteral:
+		if node.Kind != yaml.ScalarNode {
+			v.errorf(node, "expected a value")
+		} else if spec.values != nil && !contains(spec.values, node.Value) {
+			v.errorf(node, "invalid value %q, expected one of %s", node.Value, strings.Join(spec.values, ", "))
+		}
+	case propertyPoint:
+		point := v.mapping(node, "point", pointKeys)
+		if point == nil {
+			return
+		}
+		for _, key := range pointKeys {
+			if pair, ok := point[key]; ok {
+				v.expression(pair[1])
+			} else {
+				v.errorf(node, "point is missing %s", key)
+			}
+		}
+	case propertyPoints:
+		if node.Kind == yaml.ScalarNode {
+			v.expression(node)
+			return
+		}
+		for _, point := range v.sequence(node, "points") {
+			v.property(point, propertySpec{kind: propertyPoint})
+		}
+	case propertyExpressions:
+		if node.Kind == yaml.ScalarNode {
+			v.expression(node)
+			return
+		}
+		for _, item := range v.sequence(node, "list") {
+			v.expression(item)
+		}
+	case propertyColor:
+		if node.Kind == yaml.MappingNode {
+			v.gradient(node)
+		} else {
+			v.expression(node)
+		}
+	case propertyPathData:
+		if node.Kind != yaml.ScalarNode {
+			v.errorf(node, "expected path data")
+		} else if _, err := objects.ParsePathData(node.Value); err != nil {
+			v.errorf(node, "invalid path data: %v", err)
+		}
+	case propertySegments:
+		if node.Kind == yaml.ScalarNode {
+			v.expression(node)
+			return
+		}
+		for _, segment := range v.sequence(node, "segments") {
+			v.segment(segment)
+		}
+	}
+}
+
+func (v *validator) segment(node *yaml.Node) {
+	pairs := v.mapping(node, "segment", nil)
+	if pairs == nil {
+		return
+	}
+	typePair, ok := pairs["type"]
+	if !ok {
+		v.errorf(node, "segment is missing type")
+		return
+	}
+	schema, ok := segmentSchemas[typePair[1].Value]
+	if !ok {
+		v.errorf(typePair[1], "unknown segment type %q, expected one of %s", typePair[1].Value, strings.Join(sortedKeys(segmentSchemas), ", "))
+		return
+	}
+	for name, pair := range pairs {
+		if name == "type" {
+			continue
+		}
+		spec, ok := schema[name]
+		if !ok {
+			v.errorf(pair[0], "unknown %s segment key %q", typePair[1].Value, name)
+			continue
+		}
+		v.property(pair[1], spec)
+	}
+	for _, name := range sortedKeys(schema) {
+		if _, ok := pairs[name]; !ok && schema[name].required {
+			v.errorf(node, "%s segment is missing %s", typePair[1].Value, name)
+		}
+	}
+}
+
+// background checks a frame background, an image mapping with src takes the
+// image properties, anything else is a color.
+func (v *validator) background(node *yaml.Node) {
+	if node.Kind != yaml.MappingNode || mappingValue(node, "src") == nil {
+		v.property(node, optionalColor)
+		return
+	}
+	// The background covers the frame unless it says otherwise.
+	schema := make(map[string]propertySpec)
+	for name, spec := range objectSchemas[ObjectTypeImage] {
+		spec.required = name == "src"
+		schema[name] = spec
+	}
+	v.properties(node, node, "background image", schema)
+}
+
+func (v *validator) gradient(node *yaml.Node) {
+	pairs := v.mapping(node, "gradient", nil)
+	typePair, ok := pairs["type"]
+	if !ok {
+		v.errorf(node, "gradient is missing type")
+		return
+	}
+	schema, ok := gradientSchemas[typePair[1].Value]
+	if !ok {
+		v.errorf(typePair[1], "unknown gradient type %q, expected one of %s", typePair[1].Value, strings.Join(sortedKeys(gradientSchemas), ", "))
+		return
+	}
+
+	for name, pair := range pairs {
+		switch name {
+		case "type":
+		case "stops":
+			for _, stop := range v.sequence(pair[1], "gradient stops") {
+				stopPairs := v.mapping(stop, "gradient stop", stopKeys)
+				if stopPairs == nil {
+					continue
+				}
+				for _, key := range stopKeys {
+					if stopPair, ok := stopPairs[key]; ok {
+						v.expression(stopPair[1])
+					} else {
+						v.errorf(stop, "gradient stop is missing %s", key)
+					}
+				}
+			}
+		default:
+			if _, ok := schema[name]; !ok {
+				v.errorf(pair[0], "unknown %s gradient key %q", typePair[1].Value, name)
+				continue
+			}
+			v.expression(pair[1])
+		}
+	}
+	if _, ok := pairs["stops"]; !ok {
+		v.errorf(node, "gradient is missing stops")
+	}
+	for _, name := range sortedKeys(schema) {
+		if _, ok := pairs[name]; !ok && schema[name].required {
+			v.errorf(node, "%s gradient is missing %s", typePair[1].Value, name)
+		}
+	}
+}
+
+// expression checks a scalar property, strings other than hex colors must
+// compile against the scene env.
+func (v *validator) expression(node *yaml.Node) {
+	if node.Kind != yaml.ScalarNode {
+		v.errorf(node, "expected an expression")
+		return
+	}
+	if node.Tag != "!!str" {
+		return
+	}
+	if _, ok := ParseHexColor(node.Value); ok {
+		return
+	}
+	if _, err := expr.Compile(node.Value, v.options...); err != nil {
+		v.errorf(node, "invalid expression %q: %s", node.Value, firstLine(err.Error()))
+	}
+}
+
+func (v *validator) animation(node *yaml.Node) {
+	pairs := v.mapping(node, "animation", animationKeys)
+	if pairs == nil {
+		return
+	}
+	for _, key := range []string{"duration", "repeat", "delay"} {
+		if pair, ok := pairs[key]; ok {
+			v.expression(pair[1])
+		}
+	}
+	if pair, ok := pairs["easing"]; ok {
+		if _, err := ParseEasing(pair[1].Value); err != nil {
+			v.errorf(pair[1], "%s", err)
+		}
+	}
+	if pair, ok := pairs["colorSpace"]; ok {
+		if _, err := ParseColorSpace(pair[1].Value); err != nil {
+			v.errorf(pair[1], "%s", err)
+		}
+	}
+	if pair, ok := pairs["when"]; ok {
+		v.expression(pair[1])
+	}
+	if pair, ok := pairs["after"]; ok {
+		v.animationName(pair[1])
+	}
+
+	keyframesPair, ok := pairs["keyframes"]
+	if parallel, isGroup := pairs["parallel"]; isGroup {
+		for _, member := range v.sequence(parallel[1], "parallel") {
+			v.animationName(member)
+			if name, ok := pairs["name"]; ok && member.Value == name[1].Value {
+				v.errorf(member, "animation %q can't play in parallel with itself", member.Value)
+			}
+		}
+		if ok {
+			v.errorf(keyframesPair[0], "parallel animations have no keyframes")
+		}
+		return
+	}
+	if !ok {
+		v.errorf(node, "animation is missing keyframes")
+		return
+	}
+	var prevTime *yaml.Node
+	for _, keyframe := range v.sequence(keyframesPair[1], "keyframes") {
+		kf := v.mapping(keyframe, "keyframe", keyframeKeys)
+		if kf == nil {
+			continue
+		}
+		if pair, ok := kf["time"]; !ok {
+			v.errorf(keyframe, "keyframe is missing time")
+		} else if pair[1].Tag != "!!int" && pair[1].Tag != "!!float" {
+			v.errorf(pair[1], "keyframe time must be a number of seconds")
+		} else {
+			if prevTime != nil && parseFloat(pair[1].Value) < parseFloat(prevTime.Value) {
+				v.errorf(pair[1], "keyframe time %s is before the previous keyframe at %s", pair[1].Value, prevTime.Value)
+			}
+			prevTime = pair[1]
+		}
+		if pair, ok := kf["easing"]; ok {
+			if _, err := ParseEasing(pair[1].Value); err != nil {
+				v.errorf(pair[1], "%s", err)
+			}
+		}
+		if pair, ok := kf["properties"]; ok {
+			for name, property := range v.mapping(pair[1], "keyframe properties", nil) {
+				if _, ok := v.env[name]; !ok {
+					v.errorf(property[0], "animated property %q is not defined in env", name)
+				}
+			}
+		}
+	}
+}
+
+// lookupProperty finds a property of schema ignoring case.
+func lookupProperty(schema map[string]propertySpec, name string) (propertySpec, string, bool) {
+	if spec, ok := schema[name]; ok {
+		return spec, name, true
+	}
+	for specName, spec := range schema {
+		if strings.EqualFold(specName, name) {
+			return spec, specName, true
+		}
+	}
+	return propertySpec{}, "", false
+}
+
+func objectTypeNames() []string {
+	names := make([]string, 0, len(objectSchemas))
+	for name := range objectSchemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func parseFloat(s string) float64 {
+	var f float64
+	fmt.Sscanf(s, "%g", &f)
+	return f
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
@@ -0,0 +1,235 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+const invalidScene = `version: 1
+frame:
+  width: 400
+  height: 0
+env:
+  size: 10
+objects:
+  - name: eye
+    type: circle
+    properties:
+      x: 10
+      y: size +
+      radius: size
+      colour: "#ffffff"
+  - name: nose
+    type: hexagon
+    properties:
+      x: 1
+  - name: mouth
+    type: line
+    properties:
+      startPoint:
+        x: 1
+        y: 1
+animations:
+  - name: blink
+    easing: wobble
+    keyframes:
+      - time: 0
+        properties:
+          eyelid: 0
+`
+
+func TestValidateScene(t *testing.T) {
+	err := ValidateScene("invalid.yml", []byte(invalidScene))
+	var validationErrors ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		t.Fatalf("Expected validation errors, got %v", err)
+	}
+
+	expected := []string{
+		"invalid.yml:4:11: frame height must be a positive integer",
+		`invalid.yml:12:10: invalid expression "size +": unexpected token EOF (1:6)`,
+		`invalid.yml:14:7: unknown circle property "colour"`,
+		`invalid.yml:16:11: unknown object type "hexagon", expected one of arc, circle, group, image, line, path, polygon, rectangle, simple, text`,
+		`invalid.yml:21:5: line object is missing property "endPoint"`,
+		"invalid.yml:27:13: unknown easing: wobble",
+		`invalid.yml:31:11: animated property "eyelid" is not defined in env`,
+	}
+	if len(validationErrors) != len(expected) {
+		t.Fatalf("Expected %d errors, got %d:\n%v", len(expected), len(validationErrors), err)
+	}
+	for i, message := range expected {
+		if validationErrors[i].Error() != message {
+			t.Errorf("Expected %q, got %q", message, validationErrors[i].Error())
+		}
+	}
+}
+
+func TestValidateScenes(t *testing.T) {
+	for _, path := range []string{"../scenes/basic.yml", "../scenes/ein.yml", "../scenes/sprite.yml", "../scenes/styles.yml", "../scenes/gradients.yml", "../scenes/trails.yml", "../scenes/paths.yml", "../scenes/morph.yml", "../scenes/states.yml"} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", path, err)
+		}
+		if err := ValidateScene(path, data); err != nil {
+			t.Errorf("Expected %s to be valid, got:\n%v", path, err)
+		}
+	}
+}
+
+const invalidGradients = `version: 1
+frame:
+  width: 64
+  height: 64
+objects:
+  - name: sky
+    type: rectangle
+    properties:
+      x: 0
+      y: 0
+      width: 64
+      height: 64
+      fill:
+        type: diagonal
+  - name: eye
+    type: circle
+    properties:
+      x: 0
+      y: 0
+      radius: 4
+      fill:
+        type: radial
+        x: 0
+        y: 0
+        x0: 1
+        stops:
+          - offset: 0
+`
+
+func TestValidateGradients(t *testing.T) {
+	err := ValidateScene("gradients.yml", []byte(invalidGradients))
+	var validationErrors ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		t.Fatalf("Expected validation errors, got %v", err)
+	}
+
+	expected := []string{
+		`gradients.yml:14:15: unknown gradient type "diagonal", expected one of conic, linear, radial`,
+		"gradients.yml:22:9: radial gradient is missing r",
+		`gradients.yml:25:9: unknown radial gradient key "x0"`,
+		"gradients.yml:27:13: gradient stop is missing color",
+	}
+	if len(validationErrors) != len(expected) {
+		t.Fatalf("Expected %d errors, got %d:\n%v", len(expected), len(validationErrors), err)
+	}
+	for i, message := range expected {
+		if validationErrors[i].Error() != message {
+			t.Errorf("Expected %q, got %q", message, validationErrors[i].Error())
+		}
+	}
+}
+
+const invalidPaths = `version: 1
+frame:
+  width: 64
+  height: 64
+objects:
+  - name: brow
+    type: path
+    properties:
+      d: "M 10 20 Q 18"
+  - name: smile
+    type: path
+    properties:
+      segments:
+        - type: move
+          x: 0
+        - type: spline
+  - name: empty
+    type: path
+    properties:
+      color: "#ffffff"
+`
+
+func TestValidatePaths(t *testing.T) {
+	err := ValidateScene("paths.yml", []byte(invalidPaths))
+	var validationErrors ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		t.Fatalf("Expected validation errors, got %v", err)
+	}
+
+	expected := []string{
+		"paths.yml:9:10: invalid path data: column 13: expected a number, got the end",
+		"paths.yml:14:11: move segment is missing y",
+		`paths.yml:16:17: unknown segment type "spline", expected one of arc, close, cubic, line, move, quad`,
+		"paths.yml:19:5: path object needs d or segments",
+	}
+	if len(validationErrors) != len(expected) {
+		t.Fatalf("Expected %d errors, got %d:\n%v", len(expected), len(validationErrors), err)
+	}
+	for i, message := range expected {
+		if validationErrors[i].Error() != message {
+			t.Errorf("Expected %q, got %q", message, validationErrors[i].Error())
+		}
+	}
+}
+
+const invalidSequencing = `version: 1
+frame:
+  width: 64
+  height: 64
+env:
+  x: 0
+animations:
+  - name: move
+    after: jump
+    when: x >
+    keyframes:
+      - time: 0
+        properties:
+          x: 1
+  - name: move
+    parallel: [move, spin]
+  - name: look
+    parallel: [turn]
+  - name: turn
+    parallel: [tilt]
+  - name: tilt
+    parallel: [look]
+stateMachine:
+  initial: asleep
+  states:
+    idle:
+      animations: [blink]
+  transitions:
+    - from: idle
+      to: "*"
+`
+
+func TestValidateSequencing(t *testing.T) {
+	err := ValidateScene("sequencing.yml", []byte(invalidSequencing))
+	var validationErrors ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		t.Fatalf("Expected validation errors, got %v", err)
+	}
+
+	expected := []string{
+		`sequencing.yml:9:12: unknown animation "jump"`,
+		`sequencing.yml:10:11: invalid expression "x >": unexpected token EOF (1:3)`,
+		`sequencing.yml:15:11: duplicate animation name "move"`,
+		`sequencing.yml:16:16: animation "move" can't play in parallel with itself`,
+		`sequencing.yml:16:22: unknown animation "spin"`,
+		"sequencing.yml:22:16: parallel animations form a cycle: look -> turn -> tilt -> look",
+		`sequencing.yml:24:12: unknown initial state "asleep"`,
+		`sequencing.yml:27:20: unknown animation "blink"`,
+		"sequencing.yml:29:7: transition is missing animation",
+	}
+	if len(validationErrors) != len(expected) {
+		t.Fatalf("Expected %d errors, got %d:\n%v", len(expected), len(validationErrors), err)
+	}
+	for i, message := range expected {
+		if validationErrors[i].Error() != message {
+			t.Errorf("Expected %q, got %q", message, validationErrors[i].Error())
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"context"
+	"einclient/watch"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Files lists the files the scene was loaded from.
+func (scene *Scene) Files() []string {
+	return scene.files
+}
+
+// WatchScene loads the scene, sends it to reloadChan and reloads it whenever
+// one of its files changes until ctx is done. Scenes that fail to load are
+// reported and skipped, the previous one keeps playing.
+func WatchScene(ctx context.Context, filePath string, reloadChan chan *Scene, opts watch.Options) error {
+	scene, err := readSceneFile(filePath)
+	if err != nil {
+		return err
+	}
+	watcher, err := watch.New(ctx, opts, scene.Files()...)
+	if err != nil {
+		return err
+	}
+	reloadChan <- scene
+
+	go func() {
+		for range watcher.Changes() {
+			scene, err := readSceneFile(filePath)
+			if err != nil {
+				fmt.Println("Error loading scene:", err)
+				continue
+			}
+			if err := watcher.Watch(scene.Files()...); err != nil {
+				fmt.Println("Error watching scene files:", err)
+			}
+			fmt.Printf("Reloading scene %s\n", filePath)
+			fmt.Printf("Loaded %d objects\n", len(scene.Objects))
+			select {
+			case reloadChan <- scene:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// readSceneFile is ReadScene for files that may be caught halfway through a
+// save, the watcher reports the write that completes them.
+func readSceneFile(filePath string) (*Scene, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("scene file is empty")
+	}
+	return loadScene(filePath, data)
+}
@@ -0,0 +1,50 @@
+package engine
+
+import (
+	"context"
+	"einclient/watch"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchScene(t *testing.T) {
+	data, err := os.ReadFile("../scenes/basic.yml")
+	if err != nil {
+		t.Fatalf("Failed to read scene: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "scene.yml")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Failed to write scene: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := make(chan *Scene, 1)
+	opts := watch.Options{Debounce: 20 * time.Millisecond}
+	if err := WatchScene(ctx, path, ch, opts); err != nil {
+		t.Fatalf("Failed to watch scene: %v", err)
+	}
+	first := <-ch
+
+	// A broken save is skipped, the fixed one is sent.
+	if err := os.WriteFile(path, []byte("version: 1\nframe: [\n"), 0644); err != nil {
+		t.Fatalf("Failed to write scene: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	edited := strings.Replace(string(data), "objects:", "objects:\n  - name: extra\n    type: circle\n    properties:\n      x: 1\n      y: 1\n      radius: 1", 1)
+	if err := os.WriteFile(path, []byte(edited), 0644); err != nil {
+		t.Fatalf("Failed to write scene: %v", err)
+	}
+
+	select {
+	case scene := <-ch:
+		if len(scene.Objects) != len(first.Objects)+1 {
+			t.Errorf("Expected %d objects after the reload, got %d", len(first.Objects)+1, len(scene.Objects))
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Expected the scene to be reloaded")
+	}
+}
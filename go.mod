@@ -24,7 +24,7 @@ require (
 	github.com/kr/text v0.1.0 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
-	golang.org/x/image v0.19.0 // indirect
-	golang.org/x/sys v0.23.0 // indirect
+	golang.org/x/image v0.19.0
+	golang.org/x/sys v0.23.0
 	golang.org/x/text v0.17.0 // indirect
 )
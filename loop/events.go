@@ -0,0 +1,188 @@
+package loop
+
+import (
+	"context"
+	"einclient/control"
+	"einclient/engine"
+	"einclient/websocket"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	DefaultMinBackoff = time.Second
+	DefaultMaxBackoff = 30 * time.Second
+)
+
+// Event is a message of the assistant backend, every field is optional:
+//
+//	{"state": "thinking", "env": {"mood": "happy", "amplitude": 0.4}, "start": ["nod"], "stop": ["blink"]}
+//
+// State switches the scene's state machine, or sets the state variable of a
+// scene without one. Env variables the scene doesn't define are ignored, so
+// the same events drive any scene.
+type Event struct {
+	State string                 `json:"state"`
+	Env   map[string]interface{} `json:"env"`
+	Start []string               `json:"start"`
+	Stop  []string               `json:"stop"`
+}
+
+// Events takes the assistant state from a WebSocket backend and plays Idle
+// while it can't reach it, Scene once it can again.
+type Events struct {
+	URL   string
+	Scene string
+	// Idle is played while disconnected, Scene keeps playing when empty.
+	Idle string
+	// MinBackoff is the first wait before reconnecting, doubled after every
+	// failed attempt up to MaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	idle bool
+}
+
+// Run connects to the backend and applies its events to player until ctx is
+// done, reconnecting whenever the connection drops.
+func (e *Events) Run(ctx context.Context, player control.Player) error {
+	return reconnect(ctx, "Backend "+e.URL, e.MinBackoff, e.MaxBackoff, func() (bool, error) {
+		return e.session(ctx, player)
+	}, func() {
+		e.setIdle(player, true)
+	})
+}
+
+// reconnect runs session until ctx is done. The wait before running it again
+// starts at min and doubles up to max while sessions fail to connect.
+func reconnect(ctx context.Context, name string, min, max time.Duration, session func() (bool, error), disconnected func()) error {
+	min, max = backoffs(min, max)
+	backoff := min
+	for {
+		connected, err := session()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if connected {
+			backoff = min
+		}
+		fmt.Printf("%s disconnected: %v, reconnecting in %s\n", name, err, backoff)
+		disconnected()
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+		if backoff > max {
+			backoff = max
+		}
+	}
+}
+
+// backoffs fills in the defaults of the reconnection waits.
+func backoffs(min, max time.Duration) (time.Duration, time.Duration) {
+	if min <= 0 {
+		min = DefaultMinBackoff
+	}
+	if max < min {
+		max = DefaultMaxBackoff
+	}
+	return min, max
+}
+
+// session reads events until the connection drops, the first result tells
+// whether it was ever up.
+func (e *Events) session(ctx context.Context, player control.Player) (bool, error) {
+	dialCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	conn, err := websocket.Dial(dialCtx, e.URL, nil)
+	cancel()
+	if err != nil {
+		return false, err
+	}
+	fmt.Printf("Connected to backend %s\n", e.URL)
+	e.setIdle(player, false)
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+	defer conn.Close()
+
+	for {
+		message, err := conn.ReadMessage()
+		if err != nil {
+			return true, err
+		}
+		var event Event
+		if err := json.Unmarshal(message, &event); err != nil {
+			fmt.Printf("Error decoding backend event: %v\n", err)
+			continue
+		}
+		if err := player.Do(event.apply); err != nil {
+			fmt.Printf("Error applying backend event: %v\n", err)
+		}
+	}
+}
+
+// setIdle swaps between the idle scene and the scene when idle changes.
+func (e *Events) setIdle(player control.Player, idle bool) {
+	if e.Idle == "" || e.idle == idle {
+		return
+	}
+	path := e.Scene
+	if idle {
+		path = e.Idle
+	}
+	if err := player.Play(path); err != nil {
+		fmt.Printf("Error playing %s: %v\n", path, err)
+		return
+	}
+	e.idle = idle
+}
+
+func (event Event) apply(scene *engine.Scene) error {
+	if event.State != "" {
+		if scene.StateMachine != nil {
+			if err := scene.SetState(event.State); err != nil {
+				return err
+			}
+		} else {
+			event.Env = withState(event.Env, event.State)
+		}
+	}
+	for key, value := range event.Env {
+		// Variables of other scenes are skipped, not errors.
+		if _, ok := scene.Env[key]; ok {
+			if err := scene.SetEnv(map[string]interface{}{key: value}); err != nil {
+				return err
+			}
+		}
+	}
+	for _, name := range event.Stop {
+		if err := scene.Stop(name); err != nil {
+			return err
+		}
+	}
+	for _, name := range event.Start {
+		if err := scene.Start(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func withState(env map[string]interface{}, state string) map[string]interface{} {
+	out := map[string]interface{}{engine.DefaultStateVariable: state}
+	for key, value := range env {
+		out[key] = value
+	}
+	return out
+}
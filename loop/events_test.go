@@ -0,0 +1,162 @@
+package loop
+
+import (
+	"context"
+	"crypto/sha1"
+	"einclient/engine"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const eventsScene = `version: 1
+frame:
+  width: 8
+  height: 8
+env:
+  mood: calm
+  amplitude: 0.0
+animations:
+  - name: nod
+    autoplay: false
+    keyframes:
+      - time: 1
+        properties:
+          amplitude: 1.0
+stateMachine:
+  initial: idle
+  states:
+    idle: {}
+    speaking: {}
+`
+
+// fakePlayer records the scenes played and applies commands right away.
+type fakePlayer struct {
+	mu     sync.Mutex
+	scene  *engine.Scene
+	played []string
+}
+
+func (p *fakePlayer) Do(fn func(scene *engine.Scene) error) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return fn(p.scene)
+}
+
+func (p *fakePlayer) Play(path string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.played = append(p.played, path)
+	return nil
+}
+
+func (p *fakePlayer) snapshot() (string, interface{}, []string, []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.scene.Env["state"].(string), p.scene.Env["mood"], p.scene.Playing(), append([]string(nil), p.played...)
+}
+
+// acceptWebSocket answers the handshake of a WebSocket client, the stand-in
+// backend then writes frames itself.
+func acceptWebSocket(w http.ResponseWriter, r *http.Request) (net.Conn, error) {
+	conn, rw, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return nil, err
+	}
+	sum := sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", base64.StdEncoding.EncodeToString(sum[:]))
+	return conn, rw.Flush()
+}
+
+// writeFrame writes an unmasked frame shorter than 126 bytes, as servers do.
+func writeFrame(conn net.Conn, opcode byte, payload string) {
+	conn.Write(append([]byte{0x80 | opcode, byte(len(payload))}, payload...))
+}
+
+// eventually polls check until it passes or a second went by.
+func eventually(t *testing.T, what string, check func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if check() {
+			return
+		}
+	}
+	t.Fatalf("Timed out waiting for %s", what)
+}
+
+func TestEvents(t *testing.T) {
+	scene, err := engine.ParseScene([]byte(eventsScene))
+	if err != nil {
+		t.Fatalf("Failed to parse scene: %v", err)
+	}
+	player := &fakePlayer{scene: scene}
+
+	// The stand-in backend sends one event per connection then hangs up.
+	connections := make(chan struct{}, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := acceptWebSocket(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		select {
+		case connections <- struct{}{}:
+		default:
+		}
+		writeFrame(conn, 0x1, `{"state": "speaking", "env": {"mood": "happy", "other": 1}, "start": ["nod"]}`)
+		writeFrame(conn, 0x1, `not json`)
+		time.Sleep(20 * time.Millisecond)
+		writeFrame(conn, 0x8, "")
+	}))
+	defer server.Close()
+
+	events := &Events{
+		URL:        "ws" + strings.TrimPrefix(server.URL, "http"),
+		Scene:      "face.yml",
+		Idle:       "idle.yml",
+		MinBackoff: 10 * time.Millisecond,
+		MaxBackoff: 20 * time.Millisecond,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go events.Run(ctx, player)
+
+	eventually(t, "the event to apply", func() bool {
+		state, mood, playing, _ := player.snapshot()
+		return state == "speaking" && mood == "happy" && len(playing) == 1 && playing[0] == "nod"
+	})
+	<-connections
+	<-connections
+	eventually(t, "the idle scene between connections", func() bool {
+		_, _, _, played := player.snapshot()
+		return len(played) >= 2 && played[0] == "idle.yml" && played[1] == "face.yml"
+	})
+}
+
+func TestBackoffs(t *testing.T) {
+	if min, max := backoffs(0, 0); min != DefaultMinBackoff || max != DefaultMaxBackoff {
+		t.Errorf("Expected the default backoffs, got %s and %s", min, max)
+	}
+	if min, max := backoffs(time.Second, time.Millisecond); min != time.Second || max != DefaultMaxBackoff {
+		t.Errorf("Expected a max below the min to fall back to %s, got %s and %s", DefaultMaxBackoff, min, max)
+	}
+}
+
+func TestEventRejectsBadEnv(t *testing.T) {
+	scene, err := engine.ParseScene([]byte(eventsScene))
+	if err != nil {
+		t.Fatalf("Failed to parse scene: %v", err)
+	}
+	if err := (Event{Env: map[string]interface{}{"mood": 3.0}}).apply(scene); err == nil {
+		t.Error("Expected a number for the string mood to fail")
+	}
+	if scene.Env["mood"] != "calm" {
+		t.Errorf("Expected mood to stay calm, got %v", scene.Env["mood"])
+	}
+}
@@ -1,17 +1,21 @@
 package loop
 
 import (
+	"context"
 	"einclient/engine"
+	"einclient/playlist"
+	"einclient/render"
 	"einclient/rgbmatrix"
+	"einclient/watch"
+	"errors"
 	"flag"
 	"fmt"
 	"image"
-	"image/color"
 	"io"
+	"sync"
 	"time"
 
 	"github.com/fogleman/gg"
-	"github.com/nfnt/resize"
 )
 
 const (
@@ -23,33 +27,194 @@ var (
 	gifs_repo = flag.String("gifs", "./gifs", "directory containing GIFs to play")
 	gif_delay = flag.Int("gif-delay", 10, "delay between GIFs in milliseconds")
 	no_resize = flag.Bool("no-resize", false, "play GIFs without resizing")
+	shuffle   = flag.Bool("shuffle", false, "play GIFs in a random order")
+	gifRepeat = flag.Int("gif-repeat", 1, "how many times each GIF plays, unless set in the order file")
+	gifFilter = flag.String("gif-filter", "lanczos", "filter used to scale GIFs: nearest, box or lanczos")
+	gifDither = flag.String("gif-dither", "floyd-steinberg", "dithering of scaled GIFs: none, ordered or floyd-steinberg")
+	gifColors = flag.Int("gif-colors", 256, "palette size of scaled GIFs, at most 256")
 )
 
-func init() {
-	flag.Parse()
-}
-
 type Loop struct {
 	Matrix    rgbmatrix.Matrix
-	Animation *Animation
+	Animation rgbmatrix.Animation
 	Toolkit   *rgbmatrix.ToolKit
-	Chan      chan *engine.Scene
+	// Chan delivers the scenes to play, Play replaces it with the channel of
+	// the watcher it starts.
+	Chan chan *engine.Scene
+	// Watch are the options scenes passed to Play are watched with.
+	Watch watch.Options
+
+	commands chan command
+	mu       sync.Mutex
+	unwatch  context.CancelFunc
+}
+
+// command is a function run against the scene between two frames.
+type command struct {
+	fn   func(scene *engine.Scene) error
+	done chan error
 }
 
 func NewLoop(ch chan *engine.Scene) (*Loop, error) {
-	config := &rgbmatrix.DefaultConfig
-	m, err := rgbmatrix.NewRGBLedMatrix(config)
+	l, err := newLoop()
+	if err != nil {
+		return nil, err
+	}
+	l.Animation = NewAnimation(*<-ch)
+	l.Chan = ch
+	return l, nil
+}
+
+// NewSceneLoop plays the scene file at path, reloading it whenever its files
+// change.
+func NewSceneLoop(path string, opts watch.Options) (*Loop, error) {
+	l, err := newLoop()
+	if err != nil {
+		return nil, err
+	}
+	l.Watch = opts
+	if err := l.Play(path); err != nil {
+		l.Stop()
+		return nil, err
+	}
+	l.Animation = NewAnimation(*<-l.scenes())
+	return l, nil
+}
+
+// NewPlaylistLoop plays the GIFs of the -gifs directory instead of a scene.
+func NewPlaylistLoop() (*Loop, error) {
+	scale, err := ScaleOptions()
+	if err != nil {
+		return nil, err
+	}
+	l, err := newLoop()
+	if err != nil {
+		return nil, err
+	}
+	scale.Width, scale.Height = l.Matrix.Geometry()
+	p, err := playlist.New(*gifs_repo, playlist.Options{
+		Scale:    scale,
+		NoResize: *no_resize,
+		Shuffle:  *shuffle,
+		Repeat:   *gifRepeat,
+		Gap:      time.Duration(*gif_delay) * time.Millisecond,
+	})
+	if err != nil {
+		l.Stop()
+		return nil, err
+	}
+	l.Animation = p
+	return l, nil
+}
+
+// NewCalibrationLoop shows rgbmatrix.CalibrationPattern to tune the -led-gamma,
+// -led-white-point and -led-temperature flags with.
+func NewCalibrationLoop() (*Loop, error) {
+	l, err := newLoop()
+	if err != nil {
+		return nil, err
+	}
+	l.Animation = &Still{Image: rgbmatrix.CalibrationPattern(l.Matrix.Geometry())}
+	return l, nil
+}
+
+// ScaleOptions returns the GIF scaling options of the -gif-* flags, the
+// geometry is left to the caller.
+func ScaleOptions() (playlist.ScaleOptions, error) {
+	filter, err := playlist.ParseFilter(*gifFilter)
+	if err != nil {
+		return playlist.ScaleOptions{}, err
+	}
+	dither, err := playlist.ParseDither(*gifDither)
+	if err != nil {
+		return playlist.ScaleOptions{}, err
+	}
+	return playlist.ScaleOptions{Filter: filter, Dither: dither, Colors: *gifColors}, nil
+}
+
+func newLoop() (*Loop, error) {
+	config := rgbmatrix.FlagConfig()
+	m, err := rgbmatrix.NewRGBLedMatrix(&config)
 	if err != nil {
 		return nil, err
 	}
 	return &Loop{
-		Matrix:    m,
-		Animation: NewAnimation(*<-ch),
-		Toolkit:   rgbmatrix.NewToolKit(m),
-		Chan:      ch,
+		Matrix:   m,
+		Toolkit:  rgbmatrix.NewToolKit(m),
+		Chan:     make(chan *engine.Scene, 1),
+		commands: make(chan command),
 	}, nil
 }
 
+// SetBrightness sets the brightness of the panel in percent.
+func (l *Loop) SetBrightness(percent int) {
+	l.Matrix.SetBrightness(percent)
+}
+
+func (l *Loop) Brightness() int {
+	return l.Matrix.Brightness()
+}
+
+// SetPower turns the panel on or off, frames keep being rendered while off.
+func (l *Loop) SetPower(on bool) {
+	l.Matrix.SetPower(on)
+}
+
+func (l *Loop) Power() bool {
+	return l.Matrix.Power()
+}
+
+// Play watches the scene file at path and plays it from the next frame, the
+// scene played before stops being watched. Every watcher sends on a channel
+// of its own, so reloads from the previous one never replace the new scene.
+func (l *Loop) Play(path string) error {
+	ch := make(chan *engine.Scene, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := engine.WatchScene(ctx, path, ch, l.Watch); err != nil {
+		cancel()
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.unwatch != nil {
+		l.unwatch()
+	}
+	l.unwatch = cancel
+	l.Chan = ch
+	return nil
+}
+
+// scenes returns the channel the playing scene is reloaded on.
+func (l *Loop) scenes() chan *engine.Scene {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.Chan
+}
+
+// Do runs fn against the playing scene between two frames, so it never sees
+// a scene halfway through rendering, and returns its error.
+func (l *Loop) Do(fn func(scene *engine.Scene) error) error {
+	c := command{fn: fn, done: make(chan error, 1)}
+	l.commands <- c
+	return <-c.done
+}
+
+func (l *Loop) runCommands() {
+	for {
+		select {
+		case c := <-l.commands:
+			animation, ok := l.Animation.(*Animation)
+			if !ok {
+				c.done <- errors.New("not playing a scene")
+				continue
+			}
+			c.done <- c.fn(animation.scene)
+		default:
+			return
+		}
+	}
+}
+
 func (l *Loop) Start() error {
 	var err error
 	var i image.Image
@@ -57,10 +222,11 @@ func (l *Loop) Start() error {
 	fmt.Println("Starting loop")
 	for {
 		select {
-		case scene := <-l.Chan:
+		case scene := <-l.scenes():
 			l.Animation = NewAnimation(*scene)
 		default:
 		}
+		l.runCommands()
 
 		i, n, err = l.Animation.Next()
 		if err != nil {
@@ -86,19 +252,27 @@ func (l *Loop) Stop() error {
 type Animation struct {
 	ctx   *gg.Context
 	scene *engine.Scene
+	clock engine.Clock
 }
 
 func NewAnimation(scene engine.Scene) *Animation {
 	return &Animation{
 		ctx:   gg.NewContext(scene.Frame.Width, scene.Frame.Height),
 		scene: &scene,
+		clock: engine.RealClock{},
 	}
 }
 
 func (a *Animation) Next() (image.Image, <-chan time.Time, error) {
-	a.ctx.SetColor(color.Black)
-	a.ctx.Clear()
-	a.scene.Render(a.ctx)
-	img := resize.Resize(64, 64, a.ctx.Image(), resize.Lanczos2)
+	img := render.Frame(a.ctx, a.scene, a.clock, Width, Height)
 	return img, time.After(time.Millisecond * 50), nil
 }
+
+// Still shows an image until the loop stops.
+type Still struct {
+	Image image.Image
+}
+
+func (s *Still) Next() (image.Image, <-chan time.Time, error) {
+	return s.Image, time.After(time.Second), nil
+}
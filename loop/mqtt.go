@@ -0,0 +1,361 @@
+package loop
+
+import (
+	"context"
+	"einclient/control"
+	"einclient/engine"
+	"einclient/mqtt"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultMQTTPrefix      = "ein"
+	DefaultDiscoveryPrefix = "homeassistant"
+	DefaultStateInterval   = time.Second
+)
+
+// Panel is what MQTT controls, Loop is the one driving the matrix.
+type Panel interface {
+	control.Player
+	SetBrightness(percent int)
+	Brightness() int
+	SetPower(on bool)
+	Power() bool
+}
+
+// MQTT controls the panel from MQTT topics under Prefix:
+//
+//	<prefix>/power/set         ON or OFF
+//	<prefix>/brightness/set    1 to 100
+//	<prefix>/scene/set         the name of a scene of Scenes
+//	<prefix>/state/set         a state of the scene's state machine
+//	<prefix>/env/set           a JSON object of env variables
+//	<prefix>/env/<name>/set    a JSON value, or a string
+//
+// Every change is published back, retained, on the same topic ending in
+// state instead of set, changes made elsewhere like the dim schedule or the
+// HTTP API once the panel is polled, and <prefix>/availability is online or
+// offline. The
+// Home Assistant discovery configs of a light and a scene select are
+// published under DiscoveryPrefix.
+type MQTT struct {
+	Options mqtt.Options
+	// Prefix is DefaultMQTTPrefix when empty.
+	Prefix string
+	// DiscoveryPrefix is DefaultDiscoveryPrefix when empty, "-" publishes no
+	// discovery configs.
+	DiscoveryPrefix string
+	// Scenes is the directory of the scenes that can be selected by name.
+	Scenes string
+	// StateInterval is how often the panel is polled for changes, it is
+	// DefaultStateInterval when zero.
+	StateInterval time.Duration
+
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// Run connects to the broker and handles its messages until ctx is done,
+// reconnecting whenever the connection drops.
+func (m *MQTT) Run(ctx context.Context, panel Panel) error {
+	return reconnect(ctx, "MQTT broker "+m.Options.Broker, m.MinBackoff, m.MaxBackoff, func() (bool, error) {
+		return m.session(ctx, panel)
+	}, func() {})
+}
+
+func (m *MQTT) prefix() string {
+	if m.Prefix == "" {
+		return DefaultMQTTPrefix
+	}
+	return m.Prefix
+}
+
+func (m *MQTT) topic(parts ...string) string {
+	return m.prefix() + "/" + strings.Join(parts, "/")
+}
+
+func (m *MQTT) id() string {
+	if m.Options.ClientID == "" {
+		return DefaultMQTTPrefix
+	}
+	return m.Options.ClientID
+}
+
+func (m *MQTT) session(ctx context.Context, panel Panel) (bool, error) {
+	opts := m.Options
+	opts.ClientID = m.id()
+	availability := m.topic("availability")
+	opts.Will = &mqtt.Message{Topic: availability, Payload: []byte("offline"), Retain: true}
+	dialCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	client, err := mqtt.Dial(dialCtx, opts)
+	cancel()
+	if err != nil {
+		return false, err
+	}
+	fmt.Printf("Connected to MQTT broker %s\n", m.Options.Broker)
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			client.Close()
+		case <-done:
+		}
+	}()
+	defer func() {
+		// A clean disconnect doesn't publish the will.
+		client.Publish(mqtt.Message{Topic: availability, Payload: []byte("offline"), Retain: true})
+		client.Close()
+	}()
+
+	if err := client.Subscribe(m.topic("+", "set"), m.topic("env", "+", "set")); err != nil {
+		return true, err
+	}
+	states := m.states(panel)
+	messages := append(m.discovery(), states...)
+	messages = append(messages, mqtt.Message{Topic: availability, Payload: []byte("online"), Retain: true})
+	if err := publishAll(client, messages); err != nil {
+		return true, err
+	}
+	go m.pollStates(client, panel, states, done)
+
+	for {
+		message, err := client.ReadMessage()
+		if err != nil {
+			return true, err
+		}
+		replies, err := m.handle(panel, message)
+		if err != nil {
+			fmt.Printf("Error handling MQTT message on %s: %v\n", message.Topic, err)
+			continue
+		}
+		if err := publishAll(client, replies); err != nil {
+			return true, err
+		}
+	}
+}
+
+func publishAll(client *mqtt.Client, messages []mqtt.Message) error {
+	for _, message := range messages {
+		if err := client.Publish(message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MQTT) state(name, payload string) mqtt.Message {
+	return mqtt.Message{Topic: m.topic(name, "state"), Payload: []byte(payload), Retain: true}
+}
+
+// states are the state messages of the panel as it is, the scene is named
+// after the file of the scene playing.
+func (m *MQTT) states(panel Panel) []mqtt.Message {
+	messages := []mqtt.Message{
+		m.state("power", onOff(panel.Power())),
+		m.state("brightness", strconv.Itoa(panel.Brightness())),
+	}
+	var scene string
+	panel.Do(func(s *engine.Scene) error {
+		if files := s.Files(); len(files) > 0 {
+			scene = strings.TrimSuffix(filepath.Base(files[0]), filepath.Ext(files[0]))
+		}
+		return nil
+	})
+	if scene != "" {
+		messages = append(messages, m.state("scene", scene))
+	}
+	return messages
+}
+
+// pollStates publishes the states that changed since published every
+// StateInterval until done, the dim schedule, the HTTP API and the events
+// change the panel without going through MQTT.
+func (m *MQTT) pollStates(client *mqtt.Client, panel Panel, published []mqtt.Message, done <-chan struct{}) {
+	interval := m.StateInterval
+	if interval <= 0 {
+		interval = DefaultStateInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	last := make(map[string]string)
+	for _, message := range published {
+		last[message.Topic] = string(message.Payload)
+	}
+	for {
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+		for _, message := range m.states(panel) {
+			if last[message.Topic] == string(message.Payload) {
+				continue
+			}
+			if err := client.Publish(message); err != nil {
+				return
+			}
+			last[message.Topic] = string(message.Payload)
+		}
+	}
+}
+
+// handle applies a message to the panel and returns the states it changed.
+func (m *MQTT) handle(panel Panel, message mqtt.Message) ([]mqtt.Message, error) {
+	payload := strings.TrimSpace(string(message.Payload))
+	parts := strings.Split(strings.TrimPrefix(message.Topic, m.prefix()+"/"), "/")
+	switch {
+	case len(parts) == 2 && parts[0] == "power":
+		switch strings.ToUpper(payload) {
+		case "ON":
+			panel.SetPower(true)
+		case "OFF":
+			panel.SetPower(false)
+		default:
+			return nil, fmt.Errorf("expected ON or OFF, got %q", payload)
+		}
+		return []mqtt.Message{m.state("power", onOff(panel.Power()))}, nil
+
+	case len(parts) == 2 && parts[0] == "brightness":
+		percent, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, fmt.Errorf("expected a brightness from 1 to 100, got %q", payload)
+		}
+		panel.SetBrightness(percent)
+		return []mqtt.Message{m.state("brightness", strconv.Itoa(panel.Brightness()))}, nil
+
+	case len(parts) == 2 && parts[0] == "scene":
+		path, err := control.ScenePath(m.Scenes, payload)
+		if err != nil {
+			return nil, err
+		}
+		if err := panel.Play(path); err != nil {
+			return nil, err
+		}
+		return []mqtt.Message{m.state("scene", payload)}, nil
+
+	case len(parts) == 2 && parts[0] == "state":
+		if err := panel.Do(func(scene *engine.Scene) error {
+			return scene.SetState(payload)
+		}); err != nil {
+			return nil, err
+		}
+		return []mqtt.Message{m.state("state", payload)}, nil
+
+	case len(parts) == 2 && parts[0] == "env":
+		var values map[string]interface{}
+		if err := json.Unmarshal(message.Payload, &values); err != nil {
+			return nil, fmt.Errorf("expected a JSON object: %w", err)
+		}
+		return m.setEnv(panel, func(*engine.Scene) map[string]interface{} { return values })
+
+	case len(parts) == 3 && parts[0] == "env":
+		return m.setEnv(panel, func(scene *engine.Scene) map[string]interface{} {
+			var value interface{}
+			// String variables take the payload as it is, a mood can be "true".
+			if _, isString := scene.Env[parts[1]].(string); isString || json.Unmarshal(message.Payload, &value) != nil {
+				value = payload
+			}
+			return map[string]interface{}{parts[1]: value}
+		})
+	}
+	return nil, fmt.Errorf("unknown topic")
+}
+
+// setEnv sets the values read from the playing scene, they can depend on the
+// types of its env.
+func (m *MQTT) setEnv(panel Panel, read func(scene *engine.Scene) map[string]interface{}) ([]mqtt.Message, error) {
+	var env []byte
+	err := panel.Do(func(scene *engine.Scene) error {
+		if err := scene.SetEnv(read(scene)); err != nil {
+			return err
+		}
+		var err error
+		env, err = json.Marshal(scene.Env)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return []mqtt.Message{m.state("env", string(env))}, nil
+}
+
+// discovery are the Home Assistant discovery configs of the panel.
+func (m *MQTT) discovery() []mqtt.Message {
+	prefix := m.DiscoveryPrefix
+	if prefix == "-" {
+		return nil
+	}
+	if prefix == "" {
+		prefix = DefaultDiscoveryPrefix
+	}
+	id := m.id()
+	device := map[string]interface{}{"identifiers": []string{id}, "name": "Ein", "model": "LED matrix"}
+	configs := map[string]map[string]interface{}{
+		"light": {
+			"name":                     "Panel",
+			"unique_id":                id + "_panel",
+			"command_topic":            m.topic("power", "set"),
+			"state_topic":              m.topic("power", "state"),
+			"brightness_command_topic": m.topic("brightness", "set"),
+			"brightness_state_topic":   m.topic("brightness", "state"),
+			"brightness_scale":         100,
+		},
+		"select": {
+			"name":          "Scene",
+			"unique_id":     id + "_scene",
+			"command_topic": m.topic("scene", "set"),
+			"state_topic":   m.topic("scene", "state"),
+			"options":       sceneNames(m.Scenes),
+		},
+	}
+	var messages []mqtt.Message
+	for _, component := range sortedComponents(configs) {
+		config := configs[component]
+		config["availability_topic"] = m.topic("availability")
+		config["device"] = device
+		data, _ := json.Marshal(config)
+		topic := fmt.Sprintf("%s/%s/%s/%s/config", prefix, component, id, strings.TrimPrefix(config["unique_id"].(string), id+"_"))
+		messages = append(messages, mqtt.Message{Topic: topic, Payload: data, Retain: true})
+	}
+	return messages
+}
+
+func sortedComponents(configs map[string]map[string]interface{}) []string {
+	components := make([]string, 0, len(configs))
+	for component := range configs {
+		components = append(components, component)
+	}
+	sort.Strings(components)
+	return components
+}
+
+// sceneNames lists the scenes of dir by name, without their extension.
+func sceneNames(dir string) []string {
+	names := []string{}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return names
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".yml" {
+			names = append(names, strings.TrimSuffix(entry.Name(), ".yml"))
+		}
+	}
+	return names
+}
+
+func onOff(on bool) string {
+	if on {
+		return "ON"
+	}
+	return "OFF"
+}
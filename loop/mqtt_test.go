@@ -0,0 +1,223 @@
+package loop
+
+import (
+	"bufio"
+	"context"
+	"einclient/engine"
+	"einclient/mqtt"
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakePanel is a fakePlayer with brightness and power.
+type fakePanel struct {
+	fakePlayer
+	brightness int
+	on         bool
+}
+
+func (p *fakePanel) SetBrightness(percent int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.brightness = percent
+}
+
+func (p *fakePanel) Brightness() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.brightness
+}
+
+func (p *fakePanel) SetPower(on bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.on = on
+}
+
+func (p *fakePanel) Power() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.on
+}
+
+func TestMQTTHandle(t *testing.T) {
+	scene, err := engine.ParseScene([]byte(eventsScene))
+	if err != nil {
+		t.Fatalf("Failed to parse scene: %v", err)
+	}
+	panel := &fakePanel{fakePlayer: fakePlayer{scene: scene}, brightness: 80, on: true}
+	m := &MQTT{Prefix: "face", Scenes: "scenes"}
+
+	tests := []struct {
+		topic, payload string
+		state, want    string
+	}{
+		{"face/power/set", "OFF", "face/power/state", "OFF"},
+		{"face/brightness/set", "40", "face/brightness/state", "40"},
+		{"face/scene/set", "states", "face/scene/state", "states"},
+		{"face/state/set", "speaking", "face/state/state", "speaking"},
+		{"face/env/mood/set", "true", "face/env/state", `"mood":"true"`},
+		{"face/env/mood/set", "happy", "face/env/state", `"mood":"happy"`},
+		{"face/env/set", `{"amplitude": 0.5}`, "face/env/state", `"amplitude":0.5`},
+	}
+	for _, test := range tests {
+		replies, err := m.handle(panel, mqtt.Message{Topic: test.topic, Payload: []byte(test.payload)})
+		if err != nil {
+			t.Fatalf("Failed to handle %s: %v", test.topic, err)
+		}
+		if len(replies) != 1 || replies[0].Topic != test.state || !replies[0].Retain || !strings.Contains(string(replies[0].Payload), test.want) {
+			t.Errorf("Expected %s retained with %s, got %+v", test.state, test.want, replies)
+		}
+	}
+	if panel.on || panel.brightness != 40 {
+		t.Errorf("Expected the panel off at 40%%, got %v at %d%%", panel.on, panel.brightness)
+	}
+	if state, mood, _, played := panel.snapshot(); state != "speaking" || mood != "happy" || len(played) != 1 || played[0] != filepath.Join("scenes", "states.yml") {
+		t.Errorf("Expected speaking, happy and scenes/states.yml played, got %s, %v and %v", state, mood, played)
+	}
+
+	for _, message := range []mqtt.Message{
+		{Topic: "face/power/set", Payload: []byte("maybe")},
+		{Topic: "face/brightness/set", Payload: []byte("bright")},
+		{Topic: "face/scene/set", Payload: []byte("../secrets")},
+		{Topic: "face/env/unknown/set", Payload: []byte("1")},
+		{Topic: "face/env/amplitude/set", Payload: []byte("loud")},
+		{Topic: "face/state/set", Payload: []byte("dancing")},
+		{Topic: "face/volume/set", Payload: []byte("11")},
+	} {
+		if _, err := m.handle(panel, message); err == nil {
+			t.Errorf("Expected an error for %s %s", message.Topic, message.Payload)
+		}
+	}
+}
+
+func TestMQTTDiscovery(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"ein.yml", "states.yml", "notes.txt"} {
+		os.WriteFile(filepath.Join(dir, name), nil, 0o644)
+	}
+	m := &MQTT{Options: mqtt.Options{ClientID: "face1"}, Scenes: dir}
+	messages := m.discovery()
+	if len(messages) != 2 {
+		t.Fatalf("Expected a light and a select config, got %d messages", len(messages))
+	}
+	if topic := messages[1].Topic; topic != "homeassistant/select/face1/scene/config" {
+		t.Errorf("Expected the select config topic, got %s", topic)
+	}
+	var config struct {
+		CommandTopic      string   `json:"command_topic"`
+		AvailabilityTopic string   `json:"availability_topic"`
+		Options           []string `json:"options"`
+	}
+	if err := json.Unmarshal(messages[1].Payload, &config); err != nil {
+		t.Fatalf("Failed to decode config: %v", err)
+	}
+	if config.CommandTopic != "ein/scene/set" || config.AvailabilityTopic != "ein/availability" || strings.Join(config.Options, ",") != "ein,states" {
+		t.Errorf("Expected ein/scene/set with options ein,states, got %+v", config)
+	}
+
+	m.DiscoveryPrefix = "-"
+	if messages := m.discovery(); len(messages) != 0 {
+		t.Errorf("Expected no discovery with -, got %d messages", len(messages))
+	}
+}
+
+// readPacket reads an MQTT packet for the stand-in broker.
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, shift := 0, 0
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length |= int(b&0x7f) << shift
+		if b&0x80 == 0 {
+			break
+		}
+		shift += 7
+	}
+	body := make([]byte, length)
+	_, err = io.ReadFull(r, body)
+	return header, body, err
+}
+
+func TestMQTTPollStates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "face.yml")
+	if err := os.WriteFile(path, []byte(eventsScene), 0o644); err != nil {
+		t.Fatalf("Failed to write scene: %v", err)
+	}
+	scene, err := engine.ReadScene(path)
+	if err != nil {
+		t.Fatalf("Failed to read scene: %v", err)
+	}
+	panel := &fakePanel{fakePlayer: fakePlayer{scene: scene}, brightness: 80, on: true}
+	m := &MQTT{Prefix: "face", StateInterval: 5 * time.Millisecond}
+
+	states := m.states(panel)
+	if len(states) != 3 || states[2].Topic != "face/scene/state" || string(states[2].Payload) != "face" {
+		t.Fatalf("Expected the scene named after its file, got %+v", states)
+	}
+
+	// The stand-in broker accepts one client and hands over its publishes.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	published := make(chan mqtt.Message, 10)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		if _, _, err := readPacket(reader); err != nil {
+			return
+		}
+		conn.Write([]byte{0x20, 2, 0, 0})
+		for {
+			header, body, err := readPacket(reader)
+			if err != nil {
+				return
+			}
+			if header>>4 == 3 && len(body) >= 2 {
+				n := int(body[0])<<8 | int(body[1])
+				published <- mqtt.Message{Topic: string(body[2 : 2+n]), Payload: body[2+n:]}
+			}
+		}
+	}()
+	client, err := mqtt.Dial(context.Background(), mqtt.Options{Broker: "tcp://" + listener.Addr().String(), ClientID: "face"})
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer client.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go m.pollStates(client, panel, states, done)
+	panel.SetBrightness(20)
+	select {
+	case message := <-published:
+		if message.Topic != "face/brightness/state" || string(message.Payload) != "20" {
+			t.Errorf("Expected face/brightness/state 20, got %s %s", message.Topic, message.Payload)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for the brightness to be published")
+	}
+	select {
+	case message := <-published:
+		t.Errorf("Expected unchanged states not to be published, got %s %s", message.Topic, message.Payload)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
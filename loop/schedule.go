@@ -0,0 +1,96 @@
+package loop
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DimStep sets the brightness from a time of day.
+type DimStep struct {
+	// At is the time since midnight.
+	At         time.Duration
+	Brightness int
+}
+
+// DimSchedule is a day of brightness steps sorted by time, the last one
+// lasting until the first of the next day.
+type DimSchedule []DimStep
+
+// ParseDimSchedule parses steps such as "22:00=20,07:30=100".
+func ParseDimSchedule(s string) (DimSchedule, error) {
+	var schedule DimSchedule
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		at, percent, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, fmt.Errorf("expected time=brightness, got %q", field)
+		}
+		t, err := time.Parse("15:04", strings.TrimSpace(at))
+		if err != nil {
+			return nil, fmt.Errorf("invalid time %q, expected HH:MM", at)
+		}
+		brightness, err := strconv.Atoi(strings.TrimSpace(percent))
+		if err != nil || brightness < 1 || brightness > 100 {
+			return nil, fmt.Errorf("invalid brightness %q, expected 1 to 100", percent)
+		}
+		schedule = append(schedule, DimStep{
+			At:         time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute,
+			Brightness: brightness,
+		})
+	}
+	if len(schedule) == 0 {
+		return nil, fmt.Errorf("empty schedule")
+	}
+	sort.SliceStable(schedule, func(i, j int) bool { return schedule[i].At < schedule[j].At })
+	return schedule, nil
+}
+
+// step returns the index of the step in effect at t.
+func (s DimSchedule) step(t time.Time) int {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	sinceMidnight := t.Sub(midnight)
+	current := len(s) - 1
+	for i, step := range s {
+		if step.At <= sinceMidnight {
+			current = i
+		}
+	}
+	return current
+}
+
+// Brightness returns the brightness scheduled at t.
+func (s DimSchedule) Brightness(t time.Time) int {
+	return s[s.step(t)].Brightness
+}
+
+// Dimmer is what a DimSchedule dims, Loop is the one driving the matrix.
+type Dimmer interface {
+	SetBrightness(percent int)
+}
+
+// Run sets the brightness of panel every time a step starts until ctx is
+// done. Changes made in between, from MQTT for instance, last until the next
+// step.
+func (s DimSchedule) Run(ctx context.Context, panel Dimmer) error {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	last := -1
+	for {
+		if step := s.step(time.Now()); step != last {
+			panel.SetBrightness(s[step].Brightness)
+			last = step
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
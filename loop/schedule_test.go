@@ -0,0 +1,40 @@
+package loop
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDimSchedule(t *testing.T) {
+	schedule, err := ParseDimSchedule("22:00=20, 07:30=100,23:30=5")
+	if err != nil {
+		t.Fatalf("Failed to parse schedule: %v", err)
+	}
+	if len(schedule) != 3 || schedule[0].At != 7*time.Hour+30*time.Minute {
+		t.Fatalf("Expected 3 steps from 07:30, got %+v", schedule)
+	}
+
+	tests := []struct {
+		clock string
+		want  int
+	}{
+		{"00:00", 5},
+		{"07:29", 5},
+		{"07:30", 100},
+		{"21:59", 100},
+		{"22:00", 20},
+		{"23:45", 5},
+	}
+	for _, test := range tests {
+		at, _ := time.ParseInLocation("2006-01-02 15:04", "2024-03-10 "+test.clock, time.Local)
+		if got := schedule.Brightness(at); got != test.want {
+			t.Errorf("Expected %d%% at %s, got %d%%", test.want, test.clock, got)
+		}
+	}
+
+	for _, s := range []string{"", "22:00", "25:00=10", "22:00=0", "22:00=101", "22:00=dim"} {
+		if _, err := ParseDimSchedule(s); err == nil {
+			t.Errorf("Expected an error for %q", s)
+		}
+	}
+}
@@ -1,14 +1,18 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"time"
 
-	"einclient/engine"
+	"einclient/control"
 	"einclient/loop"
+	"einclient/mqtt"
+	"einclient/watch"
 
 	"github.com/getsentry/sentry-go"
 	"github.com/joho/godotenv"
@@ -17,6 +21,14 @@ import (
 
 var (
 	scenePath = flag.String("scene", "./scenes/ein.yml", "path to the scene file")
+	mode      = flag.String("mode", "scene", "what to play on the matrix: scene, gifs or calibrate")
+	watchPoll = flag.Duration("watch-poll", 0, "poll the scene files at this interval instead of using inotify")
+	httpAddr  = flag.String("http", "", "serve the control API on this address, e.g. :8080")
+	eventsURL = flag.String("events", "", "WebSocket URL of the assistant backend to take state from")
+	idleScene = flag.String("idle-scene", "", "scene played while the backend is disconnected")
+	mqttURL   = flag.String("mqtt", "", "MQTT broker to take commands from, e.g. tcp://localhost:1883")
+	mqttTopic = flag.String("mqtt-prefix", loop.DefaultMQTTPrefix, "prefix of the MQTT topics")
+	dimAt     = flag.String("dim-schedule", "", "brightness by time of day, e.g. 22:00=20,07:30=100")
 )
 
 func LogErrorAndCapture(logger zerolog.Logger, err error, msg string) {
@@ -37,6 +49,19 @@ func LogMessageAndCapture(logger zerolog.Logger, level zerolog.Level, msg string
 
 func main() {
 	flag.Parse()
+	if flag.Arg(0) == "render" {
+		if err := runRender(flag.Args()[1:]); err != nil {
+			log.Fatalf("render: %s", err)
+		}
+		return
+	}
+	if flag.Arg(0) == "scale" {
+		if err := runScale(flag.Args()[1:]); err != nil {
+			log.Fatalf("scale: %s", err)
+		}
+		return
+	}
+
 	err := godotenv.Load()
 	if err != nil {
 		log.Fatalf("failed to load .env file: %s", err)
@@ -56,17 +81,53 @@ func main() {
 	// Sample log message
 	LogMessageAndCapture(logger, zerolog.InfoLevel, "Hello, World!")
 
-	// Call a function that might produce an error and capture it with Sentry
-	ch := make(chan *engine.Scene, 1)
-	err = engine.LoadScene(*scenePath, ch)
-	if err != nil {
-		LogErrorAndCapture(logger, err, "An error occurred")
+	var l *loop.Loop
+	switch *mode {
+	case "scene":
+		opts := watch.Options{Poll: *watchPoll > 0, Interval: *watchPoll}
+		l, err = loop.NewSceneLoop(*scenePath, opts)
+	case "gifs":
+		l, err = loop.NewPlaylistLoop()
+	case "calibrate":
+		l, err = loop.NewCalibrationLoop()
+	default:
+		log.Fatalf("unknown mode %q, expected scene, gifs or calibrate", *mode)
 	}
-	l, err := loop.NewLoop(ch)
 	fmt.Printf("loop: %v\n", l)
 	if err != nil {
 		LogErrorAndCapture(logger, err, "An error occurred")
 	}
+	if *dimAt != "" && l != nil {
+		schedule, err := loop.ParseDimSchedule(*dimAt)
+		if err != nil {
+			log.Fatalf("invalid -dim-schedule: %s", err)
+		}
+		go schedule.Run(context.Background(), l)
+	}
+	if *httpAddr != "" && l != nil {
+		go func() {
+			if err := control.ListenAndServe(*httpAddr, l, filepath.Dir(*scenePath)); err != nil {
+				LogErrorAndCapture(logger, err, "Control API stopped")
+			}
+		}()
+	}
+	if *eventsURL != "" && *mode == "scene" && l != nil {
+		events := &loop.Events{URL: *eventsURL, Scene: *scenePath, Idle: *idleScene}
+		go events.Run(context.Background(), l)
+	}
+	if *mqttURL != "" && l != nil {
+		m := &loop.MQTT{
+			Options: mqtt.Options{
+				Broker:   *mqttURL,
+				ClientID: os.Getenv("MQTT_CLIENT_ID"),
+				Username: os.Getenv("MQTT_USERNAME"),
+				Password: os.Getenv("MQTT_PASSWORD"),
+			},
+			Prefix: *mqttTopic,
+			Scenes: filepath.Dir(*scenePath),
+		}
+		go m.Run(context.Background(), l)
+	}
 	err = l.Start()
 	if err != nil {
 		LogErrorAndCapture(logger, err, "An error occurred")
@@ -0,0 +1,399 @@
+// Package mqtt implements as much of MQTT 3.1.1 as the client needs:
+// connecting with a will, subscribing and publishing at QoS 0, and keeping
+// the connection alive.
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const DefaultKeepAlive = 30 * time.Second
+
+// MaxPacketSize is the largest packet ReadMessage accepts.
+const MaxPacketSize = 1 << 20
+
+// ErrPingTimeout is returned by ReadMessage once the broker left a ping
+// unanswered for the keep-alive period.
+var ErrPingTimeout = errors.New("no PINGRESP within the keep-alive period")
+
+const (
+	packetConnect     = 1
+	packetConnack     = 2
+	packetPublish     = 3
+	packetPuback      = 4
+	packetSubscribe   = 8
+	packetSuback      = 9
+	packetPingreq     = 12
+	packetPingresp    = 13
+	packetDisconnect  = 14
+	maxRemainingBytes = 268435455
+)
+
+// Message is a message published on a topic.
+type Message struct {
+	Topic   string
+	Payload []byte
+	Retain  bool
+}
+
+type Options struct {
+	// Broker is a tcp:// (or mqtt://) or ssl:// (or mqtts://) URL.
+	Broker   string
+	ClientID string
+	Username string
+	// Password is only sent with a Username.
+	Password string
+	// KeepAlive is how often the client pings, DefaultKeepAlive when zero.
+	// A ping the broker doesn't answer within it closes the connection.
+	KeepAlive time.Duration
+	// Will is published by the broker when the client drops.
+	Will *Message
+}
+
+// Client is a connection to a broker. ReadMessage must be called from a
+// single goroutine, and keep being called for pings to be answered, Publish
+// and Subscribe are safe from any.
+type Client struct {
+	conn      net.Conn
+	reader    *bufio.Reader
+	keepAlive time.Duration
+	// pingresp gets the PINGRESPs ReadMessage reads for ping.
+	pingresp chan struct{}
+	timedOut atomic.Bool
+
+	mu       sync.Mutex
+	packetID uint16
+	done     chan struct{}
+}
+
+// Dial connects to the broker of opts.
+func Dial(ctx context.Context, opts Options) (*Client, error) {
+	if opts.Password != "" && opts.Username == "" {
+		// MQTT 3.1.1 only sends a password after a user name.
+		return nil, errors.New("a password needs a user name")
+	}
+	u, err := url.Parse(opts.Broker)
+	if err != nil {
+		return nil, err
+	}
+	var dialer net.Dialer
+	var conn net.Conn
+	switch u.Scheme {
+	case "tcp", "mqtt":
+		conn, err = dialer.DialContext(ctx, "tcp", hostPort(u, "1883"))
+	case "ssl", "tls", "mqtts":
+		tlsDialer := tls.Dialer{NetDialer: &dialer, Config: &tls.Config{ServerName: u.Hostname()}}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", hostPort(u, "8883"))
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q, expected tcp or ssl", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	keepAlive := opts.KeepAlive
+	if keepAlive <= 0 {
+		keepAlive = DefaultKeepAlive
+	}
+	c := &Client{
+		conn:      conn,
+		reader:    bufio.NewReader(conn),
+		keepAlive: keepAlive,
+		pingresp:  make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+	if err := c.write(packetConnect<<4, connectBody(opts, keepAlive)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	header, body, err := c.readPacket()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if header>>4 != packetConnack || len(body) != 2 {
+		conn.Close()
+		return nil, fmt.Errorf("expected CONNACK, got packet type %d", header>>4)
+	}
+	if body[1] != 0 {
+		conn.Close()
+		return nil, fmt.Errorf("connection refused: %s", connackError(body[1]))
+	}
+	conn.SetDeadline(time.Time{})
+	go c.ping()
+	return c, nil
+}
+
+func hostPort(u *url.URL, port string) string {
+	if u.Port() == "" {
+		return net.JoinHostPort(u.Hostname(), port)
+	}
+	return u.Host
+}
+
+func connectBody(opts Options, keepAlive time.Duration) []byte {
+	body := appendString(nil, "MQTT")
+	body = append(body, 4) // protocol level 3.1.1
+	flags := byte(0x02)    // clean session
+	if opts.Will != nil {
+		flags |= 0x04
+		if opts.Will.Retain {
+			flags |= 0x20
+		}
+	}
+	if opts.Username != "" {
+		flags |= 0x80
+	}
+	if opts.Password != "" {
+		flags |= 0x40
+	}
+	body = append(body, flags)
+	body = binary.BigEndian.AppendUint16(body, uint16(keepAlive/time.Second))
+	body = appendString(body, opts.ClientID)
+	if opts.Will != nil {
+		body = appendString(body, opts.Will.Topic)
+		body = appendString(body, string(opts.Will.Payload))
+	}
+	if opts.Username != "" {
+		body = appendString(body, opts.Username)
+	}
+	if opts.Password != "" {
+		body = appendString(body, opts.Password)
+	}
+	return body
+}
+
+func connackError(code byte) string {
+	switch code {
+	case 1:
+		return "unacceptable protocol version"
+	case 2:
+		return "client identifier rejected"
+	case 3:
+		return "server unavailable"
+	case 4:
+		return "bad user name or password"
+	case 5:
+		return "not authorized"
+	}
+	return fmt.Sprintf("code %d", code)
+}
+
+// ping sends a PINGREQ every half keep-alive period and closes the
+// connection when one can't be sent or isn't answered within the period.
+func (c *Client) ping() {
+	ticker := time.NewTicker(c.keepAlive / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-c.done:
+			return
+		}
+		if err := c.write(packetPingreq<<4, nil); err != nil {
+			c.conn.Close()
+			return
+		}
+		timeout := time.NewTimer(c.keepAlive)
+		select {
+		case <-c.pingresp:
+			timeout.Stop()
+		case <-timeout.C:
+			c.timedOut.Store(true)
+			c.conn.Close()
+			return
+		case <-c.done:
+			timeout.Stop()
+			return
+		}
+	}
+}
+
+// Subscribe subscribes to topic filters at QoS 0. A refusal comes back as an
+// error from ReadMessage.
+func (c *Client) Subscribe(filters ...string) error {
+	c.mu.Lock()
+	c.packetID++
+	if c.packetID == 0 {
+		c.packetID = 1
+	}
+	body := binary.BigEndian.AppendUint16(nil, c.packetID)
+	c.mu.Unlock()
+	for _, filter := range filters {
+		body = appendString(body, filter)
+		body = append(body, 0)
+	}
+	return c.write(packetSubscribe<<4|0x02, body)
+}
+
+// Publish publishes m at QoS 0.
+func (c *Client) Publish(m Message) error {
+	header := byte(packetPublish << 4)
+	if m.Retain {
+		header |= 0x01
+	}
+	return c.write(header, append(appendString(nil, m.Topic), m.Payload...))
+}
+
+// ReadMessage returns the next message published on a subscribed topic. It
+// fails with ErrPingTimeout once the broker stopped answering pings.
+func (c *Client) ReadMessage() (Message, error) {
+	for {
+		// ping notices a silent broker first, the deadline is there for when
+		// it can't even send its PINGREQ.
+		c.conn.SetReadDeadline(time.Now().Add(2 * c.keepAlive))
+		header, body, err := c.readPacket()
+		if err != nil && c.timedOut.Load() {
+			return Message{}, ErrPingTimeout
+		}
+		if err != nil {
+			return Message{}, err
+		}
+		switch header >> 4 {
+		case packetPublish:
+			return c.publish(header, body)
+		case packetSuback:
+			if len(body) < 2 {
+				return Message{}, errors.New("malformed SUBACK")
+			}
+			for _, code := range body[2:] {
+				if code == 0x80 {
+					return Message{}, errors.New("subscription refused")
+				}
+			}
+		case packetPingresp:
+			select {
+			case c.pingresp <- struct{}{}:
+			default:
+			}
+		case packetPuback:
+		default:
+			return Message{}, fmt.Errorf("unexpected packet type %d", header>>4)
+		}
+	}
+}
+
+func (c *Client) publish(header byte, body []byte) (Message, error) {
+	topic, rest, err := readString(body)
+	if err != nil {
+		return Message{}, err
+	}
+	qos := header >> 1 & 0x03
+	if qos > 0 {
+		if len(rest) < 2 {
+			return Message{}, errors.New("publish is missing its packet identifier")
+		}
+		if qos == 1 {
+			if err := c.write(packetPuback<<4, rest[:2]); err != nil {
+				return Message{}, err
+			}
+		}
+		rest = rest[2:]
+	}
+	return Message{Topic: topic, Payload: rest, Retain: header&0x01 != 0}, nil
+}
+
+// Close disconnects cleanly, so the broker doesn't publish the will.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	select {
+	case <-c.done:
+		c.mu.Unlock()
+		return nil
+	default:
+		close(c.done)
+	}
+	c.mu.Unlock()
+	c.write(packetDisconnect<<4, nil)
+	return c.conn.Close()
+}
+
+func (c *Client) write(header byte, body []byte) error {
+	if len(body) > maxRemainingBytes {
+		return fmt.Errorf("packet is larger than %d bytes", maxRemainingBytes)
+	}
+	packet := appendLength([]byte{header}, len(body))
+	packet = append(packet, body...)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := c.conn.Write(packet)
+	return err
+}
+
+func (c *Client) readPacket() (byte, []byte, error) {
+	return readPacket(c.reader)
+}
+
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, multiplier := 0, 1
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(b&0x7f) * multiplier
+		if b&0x80 == 0 {
+			break
+		}
+		multiplier *= 128
+		if multiplier > 128*128*128 {
+			return 0, nil, errors.New("malformed remaining length")
+		}
+	}
+	if length > MaxPacketSize {
+		return 0, nil, fmt.Errorf("packet is larger than %d bytes", MaxPacketSize)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header, body, nil
+}
+
+func appendLength(b []byte, length int) []byte {
+	for {
+		digit := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			digit |= 0x80
+		}
+		b = append(b, digit)
+		if length == 0 {
+			return b
+		}
+	}
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+func readString(b []byte) (string, []byte, error) {
+	if len(b) < 2 {
+		return "", nil, errors.New("truncated string")
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+n {
+		return "", nil, errors.New("truncated string")
+	}
+	return string(b[2 : 2+n]), b[2+n:], nil
+}
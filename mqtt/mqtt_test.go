@@ -0,0 +1,302 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// broker is a local stand-in broker: QoS 0, retained messages and wills.
+type broker struct {
+	listener net.Listener
+	// deaf brokers don't answer pings.
+	deaf atomic.Bool
+
+	mu       sync.Mutex
+	retained map[string]Message
+	clients  map[*brokerClient]bool
+}
+
+type brokerClient struct {
+	conn    net.Conn
+	mu      sync.Mutex
+	filters []string
+	will    *Message
+}
+
+func newBroker(t *testing.T) *broker {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	b := &broker{listener: listener, retained: map[string]Message{}, clients: map[*brokerClient]bool{}}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+	return b
+}
+
+func (b *broker) url() string {
+	return "tcp://" + b.listener.Addr().String()
+}
+
+func (b *broker) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	c := &brokerClient{conn: conn}
+	header, body, err := readPacket(reader)
+	if err != nil || header>>4 != packetConnect {
+		return
+	}
+	c.will = parseWill(body)
+	c.send(packetConnack<<4, []byte{0, 0})
+	b.mu.Lock()
+	b.clients[c] = true
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		delete(b.clients, c)
+		b.mu.Unlock()
+		if c.will != nil {
+			b.publish(*c.will)
+		}
+	}()
+
+	for {
+		header, body, err := readPacket(reader)
+		if err != nil {
+			return
+		}
+		switch header >> 4 {
+		case packetSubscribe:
+			var filters []string
+			rest := body[2:]
+			for len(rest) > 0 {
+				var filter string
+				filter, rest, _ = readString(rest)
+				filters = append(filters, filter)
+				rest = rest[1:]
+			}
+			c.mu.Lock()
+			c.filters = append(c.filters, filters...)
+			c.mu.Unlock()
+			c.send(packetSuback<<4, append(body[:2:2], make([]byte, len(filters))...))
+			b.mu.Lock()
+			for _, m := range b.retained {
+				if c.matches(m.Topic) {
+					c.deliver(m)
+				}
+			}
+			b.mu.Unlock()
+		case packetPublish:
+			topic, payload, _ := readString(body)
+			b.publish(Message{Topic: topic, Payload: payload, Retain: header&0x01 != 0})
+		case packetPingreq:
+			if !b.deaf.Load() {
+				c.send(packetPingresp<<4, nil)
+			}
+		case packetDisconnect:
+			c.will = nil
+			return
+		}
+	}
+}
+
+func (b *broker) publish(m Message) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if m.Retain {
+		b.retained[m.Topic] = m
+	}
+	for c := range b.clients {
+		if c.matches(m.Topic) {
+			c.deliver(Message{Topic: m.Topic, Payload: m.Payload})
+		}
+	}
+}
+
+func parseWill(body []byte) *Message {
+	_, rest, _ := readString(body) // protocol name
+	flags := rest[1]
+	_, rest, _ = readString(rest[4:]) // client id after level, flags and keep alive
+	if flags&0x04 == 0 {
+		return nil
+	}
+	topic, rest, _ := readString(rest)
+	payload, _, _ := readString(rest)
+	return &Message{Topic: topic, Payload: []byte(payload), Retain: flags&0x20 != 0}
+}
+
+func (c *brokerClient) matches(topic string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, filter := range c.filters {
+		if matchTopic(filter, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchTopic(filter, topic string) bool {
+	f, t := strings.Split(filter, "/"), strings.Split(topic, "/")
+	for i, part := range f {
+		if part == "#" {
+			return true
+		}
+		if i >= len(t) || (part != "+" && part != t[i]) {
+			return false
+		}
+	}
+	return len(f) == len(t)
+}
+
+func (c *brokerClient) deliver(m Message) {
+	header := byte(packetPublish << 4)
+	if m.Retain {
+		header |= 0x01
+	}
+	c.send(header, append(appendString(nil, m.Topic), m.Payload...))
+}
+
+func (c *brokerClient) send(header byte, body []byte) {
+	packet := appendLength([]byte{header}, len(body))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn.Write(append(packet, body...))
+}
+
+// dial connects with a one second keep-alive unless opts has one, so reads
+// fail quickly.
+func dial(t *testing.T, opts Options) *Client {
+	if opts.KeepAlive == 0 {
+		opts.KeepAlive = time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := Dial(ctx, opts)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// read returns the next message or fails once the keep-alive runs out.
+func read(t *testing.T, c *Client) Message {
+	t.Helper()
+	m, err := c.ReadMessage()
+	if err != nil {
+		t.Fatalf("Failed to read a message: %v", err)
+	}
+	return m
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	b := newBroker(t)
+	subscriber := dial(t, Options{Broker: b.url(), ClientID: "face"})
+	publisher := dial(t, Options{Broker: b.url(), ClientID: "home"})
+
+	// Reading it back makes sure the broker has it before the subscription.
+	publisher.Subscribe("ein/scene/state")
+	publisher.Publish(Message{Topic: "ein/scene/state", Payload: []byte("ein"), Retain: true})
+	read(t, publisher)
+	if err := subscriber.Subscribe("ein/+/set", "ein/scene/state"); err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	if m := read(t, subscriber); m.Topic != "ein/scene/state" || string(m.Payload) != "ein" || !m.Retain {
+		t.Errorf("Expected the retained scene, got %+v", m)
+	}
+	publisher.Publish(Message{Topic: "ein/power/set", Payload: []byte("OFF")})
+	if m := read(t, subscriber); m.Topic != "ein/power/set" || string(m.Payload) != "OFF" {
+		t.Errorf("Expected power OFF, got %+v", m)
+	}
+}
+
+func TestWill(t *testing.T) {
+	b := newBroker(t)
+	watcher := dial(t, Options{Broker: b.url(), ClientID: "home"})
+	watcher.Subscribe("ein/availability")
+	// Wait for the subscription to land.
+	watcher.Publish(Message{Topic: "ein/availability", Payload: []byte("ready")})
+	read(t, watcher)
+
+	will := &Message{Topic: "ein/availability", Payload: []byte("offline"), Retain: true}
+	face := dial(t, Options{Broker: b.url(), ClientID: "face", Will: will, KeepAlive: time.Second})
+	face.conn.Close()
+	if m := read(t, watcher); string(m.Payload) != "offline" {
+		t.Errorf("Expected the will once the client dropped, got %+v", m)
+	}
+}
+
+func TestPingTimeout(t *testing.T) {
+	b := newBroker(t)
+	face := dial(t, Options{Broker: b.url(), ClientID: "face", KeepAlive: 100 * time.Millisecond})
+	face.Subscribe("ein/power/set")
+	// PINGRESPs are only seen while reading, as clients keep doing.
+	messages, errs := make(chan Message), make(chan error, 1)
+	go func() {
+		for {
+			m, err := face.ReadMessage()
+			if err != nil {
+				errs <- err
+				return
+			}
+			messages <- m
+		}
+	}()
+
+	home := dial(t, Options{Broker: b.url(), ClientID: "home"})
+	time.Sleep(300 * time.Millisecond)
+	home.Publish(Message{Topic: "ein/power/set", Payload: []byte("OFF")})
+	select {
+	case m := <-messages:
+		if string(m.Payload) != "OFF" {
+			t.Errorf("Expected power OFF, got %+v", m)
+		}
+	case err := <-errs:
+		t.Fatalf("Expected answered pings to keep the connection up, got %v", err)
+	}
+
+	b.deaf.Store(true)
+	if err := <-errs; !errors.Is(err, ErrPingTimeout) {
+		t.Errorf("Expected %v, got %v", ErrPingTimeout, err)
+	}
+}
+
+func TestConnectBody(t *testing.T) {
+	body := connectBody(Options{ClientID: "ein", Username: "user", Password: "pass"}, 30*time.Second)
+	if flags := body[7]; flags != 0xc2 {
+		t.Errorf("Expected user name, password and clean session flags, got %#x", flags)
+	}
+	if keepAlive := binary.BigEndian.Uint16(body[8:]); keepAlive != 30 {
+		t.Errorf("Expected a 30s keep alive, got %d", keepAlive)
+	}
+	if _, err := Dial(context.Background(), Options{Broker: "http://localhost"}); err == nil {
+		t.Errorf("Expected http:// to be refused")
+	}
+	if _, err := Dial(context.Background(), Options{Broker: "tcp://localhost", Password: "pass"}); err == nil {
+		t.Errorf("Expected a password without a user name to be refused")
+	}
+}
+
+func TestReadPacketTooLarge(t *testing.T) {
+	packet := appendLength([]byte{packetPublish << 4}, MaxPacketSize+1)
+	if _, _, err := readPacket(bufio.NewReader(strings.NewReader(string(packet)))); err == nil {
+		t.Errorf("Expected a packet over %d bytes to be refused", MaxPacketSize)
+	}
+}
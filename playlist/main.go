@@ -0,0 +1,242 @@
+// Package playlist plays a directory of GIFs one after the other, scaled to
+// the matrix.
+package playlist
+
+import (
+	"einclient/engine/objects"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Options configure how a Playlist plays its GIFs.
+type Options struct {
+	// Scale is how GIFs are scaled to the matrix.
+	Scale ScaleOptions
+	// NoResize plays GIFs at their own size, cropped to the matrix.
+	NoResize bool
+	// Shuffle plays the GIFs in a new random order on every pass.
+	Shuffle bool
+	// Repeat is how many times GIFs missing from the order file play.
+	Repeat int
+	// Gap holds the last frame of every GIF a bit longer.
+	Gap time.Duration
+}
+
+// Entry is a GIF of the playlist.
+type Entry struct {
+	Path   string
+	Repeat int
+}
+
+// Playlist cycles through the GIFs of a directory, it implements
+// rgbmatrix.Animation.
+type Playlist struct {
+	Dir     string
+	Options Options
+
+	rand    *rand.Rand
+	entries []Entry
+	entry   int
+	sprite  *objects.Sprite
+	frames  []image.Image
+	frame   int
+	plays   int
+}
+
+// New scans dir for GIFs, it fails when there are none.
+func New(dir string, opts Options) (*Playlist, error) {
+	if opts.Repeat < 1 {
+		opts.Repeat = 1
+	}
+	p := &Playlist{
+		Dir:     dir,
+		Options: opts,
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	if err := p.rewind(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Next returns the next frame and a channel firing when its delay is over.
+func (p *Playlist) Next() (image.Image, <-chan time.Time, error) {
+	img, delay, err := p.next()
+	if err != nil {
+		return nil, nil, err
+	}
+	return img, time.After(delay), nil
+}
+
+func (p *Playlist) next() (image.Image, time.Duration, error) {
+	failures := 0
+	for p.sprite == nil {
+		if p.entry >= len(p.entries) {
+			if err := p.rewind(); err != nil {
+				return nil, 0, err
+			}
+		}
+		entry := p.entries[p.entry]
+		if err := p.load(entry); err != nil {
+			fmt.Printf("Error loading GIF %s: %v\n", entry.Path, err)
+			p.entry++
+			failures++
+			if failures >= len(p.entries) {
+				return nil, 0, fmt.Errorf("no playable GIFs in %s", p.Dir)
+			}
+		}
+	}
+
+	img, delay := p.frames[p.frame], p.sprite.Delays[p.frame]
+	p.frame++
+	if p.frame < len(p.frames) {
+		return img, delay, nil
+	}
+
+	p.frame = 0
+	p.plays--
+	if p.plays <= 0 {
+		// Drop the frames so the GIF can be freed before the next one loads.
+		p.sprite, p.frames = nil, nil
+		p.entry++
+		delay += p.Options.Gap
+	}
+	return img, delay, nil
+}
+
+// rewind starts a new pass over the directory, picking up added or removed
+// GIFs.
+func (p *Playlist) rewind() error {
+	entries, err := Scan(p.Dir, p.Options.Repeat)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("no GIFs found in %s", p.Dir)
+	}
+	if p.Options.Shuffle {
+		p.rand.Shuffle(len(entries), func(i, j int) {
+			entries[i], entries[j] = entries[j], entries[i]
+		})
+	}
+	p.entries = entries
+	p.entry = 0
+	return nil
+}
+
+func (p *Playlist) load(entry Entry) error {
+	var sprite *objects.Sprite
+	var err error
+	if p.Options.NoResize {
+		// Full size GIFs are large, they aren't kept once played.
+		sprite, err = objects.DecodeSprite(entry.Path)
+	} else {
+		sprite, err = LoadScaled(entry.Path, p.Options.Scale)
+	}
+	if err != nil {
+		return err
+	}
+	if len(sprite.Frames) == 0 {
+		return errors.New("GIF has no frames")
+	}
+
+	p.sprite = sprite
+	p.frames = sprite.Frames
+	p.frame = 0
+	p.plays = entry.Repeat * plays(sprite.LoopCount)
+	return nil
+}
+
+// plays is how many times a GIF runs through its frames, GIFs looping
+// forever play once before the next one.
+func plays(loopCount int) int {
+	if loopCount <= 0 {
+		return 1
+	}
+	return loopCount + 1
+}
+
+// Scan lists the GIFs in dir and its subfolders, skipping hidden folders like
+// the .scaled caches. GIFs listed in the order file come first, the others
+// follow sorted by path with the given repeat count.
+func Scan(dir string, repeat int) ([]Entry, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.EqualFold(filepath.Ext(path), ".gif") {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	ordered, err := ReadOrder(filepath.Join(dir, OrderFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	found := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		found[path] = true
+	}
+	listed := make(map[string]bool, len(ordered))
+	var entries []Entry
+	for _, entry := range ordered {
+		entry.Path = filepath.Join(dir, entry.Path)
+		if !found[entry.Path] {
+			fmt.Printf("Skipping %s from %s, it doesn't exist\n", entry.Path, OrderFile)
+			continue
+		}
+		listed[entry.Path] = true
+		entries = append(entries, entry)
+	}
+	for _, path := range paths {
+		if !listed[path] {
+			entries = append(entries, Entry{Path: path, Repeat: repeat})
+		}
+	}
+	return entries, nil
+}
+
+// fit scales frame to fit width x height keeping its aspect ratio, centered
+// on black.
+func fit(frame image.Image, width, height int, scale func(image.Image, int, int) image.Image) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.Black, image.Point{}, draw.Src)
+
+	bounds := frame.Bounds()
+	w, h := width, bounds.Dy()*width/bounds.Dx()
+	if h > height {
+		w, h = bounds.Dx()*height/bounds.Dy(), height
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	scaled := scale(frame, w, h)
+	offset := image.Pt((width-w)/2, (height-h)/2)
+	draw.Draw(dst, image.Rectangle{Min: offset, Max: offset.Add(image.Pt(w, h))}, scaled, scaled.Bounds().Min, draw.Over)
+	return dst
+}
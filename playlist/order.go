@@ -0,0 +1,50 @@
+package playlist
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// OrderFile lists GIFs in the order they should play, one path relative to
+// the playlist directory per line, optionally followed by how many times it
+// repeats. Empty lines and lines starting with # are ignored:
+//
+//	# nyan first, three times
+//	nyan/nyan.gif 3
+//	moon.gif
+const OrderFile = "order.txt"
+
+// ReadOrder parses an order file.
+func ReadOrder(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		entry := Entry{Path: fields[0], Repeat: 1}
+		switch len(fields) {
+		case 1:
+		case 2:
+			repeat, err := strconv.Atoi(fields[1])
+			if err != nil || repeat < 1 {
+				return nil, fmt.Errorf("%s:%d: repeat must be a positive integer, got %q", path, line, fields[1])
+			}
+			entry.Repeat = repeat
+		default:
+			return nil, fmt.Errorf("%s:%d: expected a path and an optional repeat count", path, line)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
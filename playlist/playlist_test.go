@@ -0,0 +1,232 @@
+package playlist
+
+import (
+	"image"
+	"image/color"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// writeTestGIF writes a GIF of size x size with one frame per delay.
+func writeTestGIF(t *testing.T, path string, size int, delays []int, loopCount int) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	anim := &gif.GIF{LoopCount: loopCount}
+	for idx, delay := range delays {
+		frame := image.NewPaletted(image.Rect(0, 0, size, size), color.Palette{color.Black, color.White})
+		frame.SetColorIndex(idx%size, 0, 1)
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, delay)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create GIF: %v", err)
+	}
+	defer f.Close()
+	if err := gif.EncodeAll(f, anim); err != nil {
+		t.Fatalf("Failed to encode GIF: %v", err)
+	}
+}
+
+func TestScan(t *testing.T) {
+	dir := t.TempDir()
+	writeTestGIF(t, filepath.Join(dir, "b.gif"), 4, []int{10}, 0)
+	writeTestGIF(t, filepath.Join(dir, "a.gif"), 4, []int{10}, 0)
+	writeTestGIF(t, filepath.Join(dir, "nyan", "nyan.gif"), 4, []int{10}, 0)
+	writeTestGIF(t, filepath.Join(dir, ScaledDir, "a.gif"), 4, []int{10}, 0)
+	order := "# favourites\nnyan/nyan.gif 3\n\nmissing.gif\n"
+	if err := os.WriteFile(filepath.Join(dir, OrderFile), []byte(order), 0644); err != nil {
+		t.Fatalf("Failed to write order file: %v", err)
+	}
+
+	entries, err := Scan(dir, 2)
+	if err != nil {
+		t.Fatalf("Failed to scan: %v", err)
+	}
+	expected := []Entry{
+		{Path: filepath.Join(dir, "nyan", "nyan.gif"), Repeat: 3},
+		{Path: filepath.Join(dir, "a.gif"), Repeat: 2},
+		{Path: filepath.Join(dir, "b.gif"), Repeat: 2},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("Expected %v, got %v", expected, entries)
+	}
+}
+
+func TestReadOrderErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), OrderFile)
+	if err := os.WriteFile(path, []byte("a.gif twice\n"), 0644); err != nil {
+		t.Fatalf("Failed to write order file: %v", err)
+	}
+	if _, err := ReadOrder(path); err == nil {
+		t.Errorf("Expected an error for a non numeric repeat count")
+	}
+}
+
+func TestPlaylistNext(t *testing.T) {
+	dir := t.TempDir()
+	// a.gif loops forever so it plays once per repeat, b.gif plays twice.
+	writeTestGIF(t, filepath.Join(dir, "a.gif"), 8, []int{10, 0}, 0)
+	writeTestGIF(t, filepath.Join(dir, "b.gif"), 8, []int{5, 5}, 1)
+	if err := os.WriteFile(filepath.Join(dir, OrderFile), []byte("a.gif 2\n"), 0644); err != nil {
+		t.Fatalf("Failed to write order file: %v", err)
+	}
+
+	p, err := New(dir, Options{Scale: ScaleOptions{Width: 4, Height: 4}, Gap: time.Second})
+	if err != nil {
+		t.Fatalf("Failed to create playlist: %v", err)
+	}
+	expected := []time.Duration{
+		100 * time.Millisecond, 100 * time.Millisecond,
+		100 * time.Millisecond, 100*time.Millisecond + time.Second,
+		50 * time.Millisecond, 50 * time.Millisecond,
+		50 * time.Millisecond, 50*time.Millisecond + time.Second,
+		100 * time.Millisecond,
+	}
+	for idx, delay := range expected {
+		img, got, err := p.next()
+		if err != nil {
+			t.Fatalf("Failed to get frame %d: %v", idx, err)
+		}
+		if got != delay {
+			t.Errorf("Expected frame %d to last %v, got %v", idx, delay, got)
+		}
+		if img.Bounds() != image.Rect(0, 0, 4, 4) {
+			t.Errorf("Expected frame %d to be scaled to 4x4, got %v", idx, img.Bounds())
+		}
+	}
+}
+
+func TestPlaylistNoResize(t *testing.T) {
+	dir := t.TempDir()
+	writeTestGIF(t, filepath.Join(dir, "a.gif"), 8, []int{10, 10}, 0)
+	writeTestGIF(t, filepath.Join(dir, "b.gif"), 8, []int{10}, 0)
+
+	p, err := New(dir, Options{NoResize: true})
+	if err != nil {
+		t.Fatalf("Failed to create playlist: %v", err)
+	}
+	for idx := 0; idx < 2; idx++ {
+		img, _, err := p.next()
+		if err != nil {
+			t.Fatalf("Failed to get frame %d: %v", idx, err)
+		}
+		if img.Bounds() != image.Rect(0, 0, 8, 8) {
+			t.Errorf("Expected frame %d at its own 8x8 size, got %v", idx, img.Bounds())
+		}
+	}
+	if p.sprite != nil || p.frames != nil {
+		t.Errorf("Expected the frames of a.gif to be dropped once it played")
+	}
+}
+
+func TestLoadScaledCache(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "wide.gif")
+	writeTestGIF(t, path, 16, []int{10, 20}, 0)
+	legacy := filepath.Join(dir, ScaledDir, "wide.gif")
+	writeTestGIF(t, legacy, 4, []int{10}, 0)
+
+	opts := ScaleOptions{Width: 8, Height: 4, Filter: FilterBox}
+	sprite, err := LoadScaled(path, opts)
+	if err != nil {
+		t.Fatalf("Failed to scale: %v", err)
+	}
+	if len(sprite.Frames) != 2 || sprite.Delays[1] != 200*time.Millisecond {
+		t.Errorf("Expected 2 frames keeping their delays, got %d frames and %v", len(sprite.Frames), sprite.Delays)
+	}
+	if sprite.Frames[0].Bounds() != image.Rect(0, 0, 8, 4) {
+		t.Errorf("Expected frames of 8x4, got %v", sprite.Frames[0].Bounds())
+	}
+	// The square GIF is letterboxed in the middle of the wide matrix.
+	_, _, _, a := sprite.Frames[0].At(0, 0).RGBA()
+	if a == 0 {
+		t.Errorf("Expected the letterbox to be opaque black")
+	}
+
+	cache, err := ScaledPath(path, opts)
+	if err != nil {
+		t.Fatalf("Failed to hash: %v", err)
+	}
+	if _, err := os.Stat(cache); err != nil {
+		t.Fatalf("Expected the scaled GIF to be cached: %v", err)
+	}
+	if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+		t.Errorf("Expected the unhashed copy to be removed, got %v", err)
+	}
+
+	// Another geometry or an edited GIF gets a new copy replacing the old one.
+	other, _ := ScaledPath(path, ScaleOptions{Width: 16, Height: 16, Filter: FilterBox})
+	if other == cache {
+		t.Errorf("Expected the geometry to change the cache path")
+	}
+	writeTestGIF(t, path, 16, []int{10, 20, 30}, 0)
+	if _, err := LoadScaled(path, opts); err != nil {
+		t.Fatalf("Failed to scale the edited GIF: %v", err)
+	}
+	edited, _ := ScaledPath(path, opts)
+	if edited == cache {
+		t.Errorf("Expected the content to change the cache path")
+	}
+	if _, err := os.Stat(cache); !os.IsNotExist(err) {
+		t.Errorf("Expected the outdated copy to be removed, got %v", err)
+	}
+}
+
+func TestQuantize(t *testing.T) {
+	gradient := image.NewRGBA(image.Rect(0, 0, 64, 4))
+	for x := 0; x < 64; x++ {
+		for y := 0; y < 4; y++ {
+			gradient.Set(x, y, color.RGBA{uint8(x * 4), uint8(255 - x*4), 128, 255})
+		}
+	}
+
+	exact := quantize(gradient, 256, DitherNone)
+	if len(exact.Palette) != 64 {
+		t.Errorf("Expected the 64 colors to be kept, got %d", len(exact.Palette))
+	}
+	if c := exact.At(10, 0); c != gradient.At(10, 0) {
+		t.Errorf("Expected an exact color, got %v", c)
+	}
+
+	for _, dither := range []Dither{DitherNone, DitherOrdered, DitherFloydSteinberg} {
+		reduced := quantize(gradient, 8, dither)
+		if len(reduced.Palette) != 8 {
+			t.Errorf("Expected 8 colors with %s dithering, got %d", dither, len(reduced.Palette))
+		}
+		// Averaged over a row the quantized gradient stays close to the source.
+		var want, got int
+		for x := 0; x < 64; x++ {
+			r, _, _, _ := gradient.At(x, 1).RGBA()
+			q, _, _, _ := reduced.At(x, 1).RGBA()
+			want += int(r >> 8)
+			got += int(q >> 8)
+		}
+		if diff := (want - got) / 64; diff > 16 || diff < -16 {
+			t.Errorf("Expected %s dithering to keep the mean red close to %d, got %d", dither, want/64, got/64)
+		}
+	}
+}
+
+func TestBoxResize(t *testing.T) {
+	checker := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 4; y++ {
+			if (x+y)%2 == 0 {
+				checker.Set(x, y, color.White)
+			} else {
+				checker.Set(x, y, color.Black)
+			}
+		}
+	}
+	r, _, _, _ := boxResize(checker, 2, 2).At(1, 1).RGBA()
+	if r>>8 < 126 || r>>8 > 128 {
+		t.Errorf("Expected a checkerboard to average to grey, got %d", r>>8)
+	}
+}
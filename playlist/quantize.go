@@ -0,0 +1,211 @@
+package playlist
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"sort"
+)
+
+// bayer8 is the 8x8 ordered dithering threshold matrix.
+var bayer8 = [8][8]int{
+	{0, 32, 8, 40, 2, 34, 10, 42},
+	{48, 16, 56, 24, 50, 18, 58, 26},
+	{12, 44, 4, 36, 14, 46, 6, 38},
+	{60, 28, 52, 20, 62, 30, 54, 22},
+	{3, 35, 11, 43, 1, 33, 9, 41},
+	{51, 19, 59, 27, 49, 17, 57, 25},
+	{15, 47, 7, 39, 13, 45, 5, 37},
+	{63, 31, 55, 23, 61, 29, 53, 21},
+}
+
+// quantize converts a frame to a palette of at most colors colors. Frames
+// with few enough colors keep them exactly, others get a median cut palette
+// and are dithered.
+func quantize(frame image.Image, colors int, dither Dither) *image.Paletted {
+	bounds := frame.Bounds()
+	histogram := make(map[color.RGBA]int)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			histogram[color.RGBAModel.Convert(frame.At(x, y)).(color.RGBA)]++
+		}
+	}
+
+	dst := image.NewPaletted(bounds, nil)
+	if len(histogram) <= colors {
+		for c := range histogram {
+			dst.Palette = append(dst.Palette, c)
+		}
+		sort.Slice(dst.Palette, func(i, j int) bool {
+			return rgbKey(dst.Palette[i].(color.RGBA)) < rgbKey(dst.Palette[j].(color.RGBA))
+		})
+		draw.Draw(dst, bounds, frame, bounds.Min, draw.Src)
+		return dst
+	}
+
+	dst.Palette = medianCut(histogram, colors)
+	switch dither {
+	case DitherFloydSteinberg:
+		draw.FloydSteinberg.Draw(dst, bounds, frame, bounds.Min)
+	case DitherOrdered:
+		orderedDither(dst, frame, len(dst.Palette))
+	default:
+		draw.Draw(dst, bounds, frame, bounds.Min, draw.Src)
+	}
+	return dst
+}
+
+func rgbKey(c color.RGBA) uint32 {
+	return uint32(c.R)<<24 | uint32(c.G)<<16 | uint32(c.B)<<8 | uint32(c.A)
+}
+
+// orderedDither offsets every pixel by the Bayer threshold before picking
+// the closest palette color, the offset shrinks as the palette grows.
+func orderedDither(dst *image.Paletted, src image.Image, colors int) {
+	spread := 256 / cubeRoot(colors)
+	bounds := dst.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.RGBAModel.Convert(src.At(x, y)).(color.RGBA)
+			offset := (bayer8[y&7][x&7] - 32) * spread / 64
+			c.R = clampChannel(int(c.R) + offset)
+			c.G = clampChannel(int(c.G) + offset)
+			c.B = clampChannel(int(c.B) + offset)
+			dst.SetColorIndex(x, y, uint8(dst.Palette.Index(c)))
+		}
+	}
+}
+
+func cubeRoot(n int) int {
+	root := 1
+	for (root+1)*(root+1)*(root+1) <= n {
+		root++
+	}
+	return root
+}
+
+func clampChannel(v int) uint8 {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return uint8(v)
+}
+
+// colorBox is a set of colors median cut splits along its widest channel.
+type colorBox struct {
+	colors []color.RGBA
+	counts []int
+}
+
+func (b *colorBox) widest() (channel int, width int) {
+	for ch := 0; ch < 3; ch++ {
+		lo, hi := 255, 0
+		for _, c := range b.colors {
+			v := channelOf(c, ch)
+			if v < lo {
+				lo = v
+			}
+			if v > hi {
+				hi = v
+			}
+		}
+		if hi-lo > width {
+			channel, width = ch, hi-lo
+		}
+	}
+	return channel, width
+}
+
+// average is the pixel weighted mean color of the box.
+func (b *colorBox) average() color.RGBA {
+	var r, g, bl, a, total int
+	for idx, c := range b.colors {
+		n := b.counts[idx]
+		r += int(c.R) * n
+		g += int(c.G) * n
+		bl += int(c.B) * n
+		a += int(c.A) * n
+		total += n
+	}
+	return color.RGBA{uint8(r / total), uint8(g / total), uint8(bl / total), uint8(a / total)}
+}
+
+func channelOf(c color.RGBA, channel int) int {
+	switch channel {
+	case 0:
+		return int(c.R)
+	case 1:
+		return int(c.G)
+	}
+	return int(c.B)
+}
+
+// medianCut builds a palette of n colors by repeatedly splitting the box
+// with the widest channel at its median pixel.
+func medianCut(histogram map[color.RGBA]int, n int) color.Palette {
+	root := &colorBox{}
+	for c, count := range histogram {
+		root.colors = append(root.colors, c)
+		root.counts = append(root.counts, count)
+	}
+	boxes := []*colorBox{root}
+	for len(boxes) < n {
+		split, channel, best := -1, 0, 0
+		for idx, box := range boxes {
+			if len(box.colors) < 2 {
+				continue
+			}
+			if ch, width := box.widest(); width > best {
+				split, channel, best = idx, ch, width
+			}
+		}
+		if split < 0 {
+			break
+		}
+		low, high := splitBox(boxes[split], channel)
+		boxes[split] = low
+		boxes = append(boxes, high)
+	}
+
+	p := make(color.Palette, len(boxes))
+	for idx, box := range boxes {
+		p[idx] = box.average()
+	}
+	return p
+}
+
+func splitBox(box *colorBox, channel int) (*colorBox, *colorBox) {
+	order := make([]int, len(box.colors))
+	total := 0
+	for idx := range order {
+		order[idx] = idx
+		total += box.counts[idx]
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return channelOf(box.colors[order[i]], channel) < channelOf(box.colors[order[j]], channel)
+	})
+
+	// Split at the median pixel, keeping at least one color on each side.
+	cut, seen := 1, 0
+	for idx, i := range order[:len(order)-1] {
+		seen += box.counts[i]
+		cut = idx + 1
+		if seen*2 >= total {
+			break
+		}
+	}
+
+	low, high := &colorBox{}, &colorBox{}
+	for idx, i := range order {
+		side := low
+		if idx >= cut {
+			side = high
+		}
+		side.colors = append(side.colors, box.colors[i])
+		side.counts = append(side.counts, box.counts[i])
+	}
+	return low, high
+}
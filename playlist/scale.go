@@ -0,0 +1,291 @@
+package playlist
+
+import (
+	"crypto/sha256"
+	"einclient/engine/objects"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nfnt/resize"
+)
+
+// ScaledDir is the cache folder, next to each GIF, holding copies scaled to
+// the matrix.
+const ScaledDir = ".scaled"
+
+// Filter is the resampling filter used to scale GIFs.
+type Filter string
+
+const (
+	FilterNearest Filter = "nearest"
+	FilterBox     Filter = "box"
+	FilterLanczos Filter = "lanczos"
+)
+
+// Dither is how colors missing from a scaled frame's palette are
+// approximated.
+type Dither string
+
+const (
+	DitherNone           Dither = "none"
+	DitherOrdered        Dither = "ordered"
+	DitherFloydSteinberg Dither = "floyd-steinberg"
+)
+
+// ParseFilter checks a filter name.
+func ParseFilter(name string) (Filter, error) {
+	switch filter := Filter(name); filter {
+	case FilterNearest, FilterBox, FilterLanczos:
+		return filter, nil
+	}
+	return "", fmt.Errorf("unknown filter %q, expected nearest, box or lanczos", name)
+}
+
+// ParseDither checks a dithering name.
+func ParseDither(name string) (Dither, error) {
+	switch dither := Dither(name); dither {
+	case DitherNone, DitherOrdered, DitherFloydSteinberg:
+		return dither, nil
+	}
+	return "", fmt.Errorf("unknown dithering %q, expected none, ordered or floyd-steinberg", name)
+}
+
+// ScaleOptions describe the scaled copy of a GIF, they are part of its cache
+// key along with the GIF content.
+type ScaleOptions struct {
+	// Width and Height are the matrix geometry.
+	Width  int
+	Height int
+	// Filter defaults to Lanczos.
+	Filter Filter
+	// Dither defaults to Floyd–Steinberg.
+	Dither Dither
+	// Colors is the palette size of every frame, at most and by default 256.
+	Colors int
+}
+
+func (o ScaleOptions) withDefaults() ScaleOptions {
+	if o.Filter == "" {
+		o.Filter = FilterLanczos
+	}
+	if o.Dither == "" {
+		o.Dither = DitherFloydSteinberg
+	}
+	if o.Colors <= 0 || o.Colors > 256 {
+		o.Colors = 256
+	}
+	return o
+}
+
+func (o ScaleOptions) scale(img image.Image, width, height int) image.Image {
+	switch o.Filter {
+	case FilterNearest:
+		return resize.Resize(uint(width), uint(height), img, resize.NearestNeighbor)
+	case FilterBox:
+		return boxResize(img, width, height)
+	}
+	return resize.Resize(uint(width), uint(height), img, resize.Lanczos3)
+}
+
+// ScaledPath is where the scaled copy of a GIF is cached, named after a hash
+// of the GIF content and the options so edited GIFs and other geometries get
+// a new copy.
+func ScaledPath(path string, opts ScaleOptions) (string, error) {
+	sum, err := contentHash(path)
+	if err != nil {
+		return "", err
+	}
+	opts = opts.withDefaults()
+	h := sha256.New()
+	h.Write(sum)
+	fmt.Fprintf(h, "%dx%d %s %s %d", opts.Width, opts.Height, opts.Filter, opts.Dither, opts.Colors)
+	key := hex.EncodeToString(h.Sum(nil))[:12]
+
+	stem := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return filepath.Join(filepath.Dir(path), ScaledDir, stem+"-"+key+".gif"), nil
+}
+
+// LoadScaled loads a GIF scaled to the options from its cache, scaling it and
+// replacing outdated copies on a miss.
+func LoadScaled(path string, opts ScaleOptions) (*objects.Sprite, error) {
+	opts = opts.withDefaults()
+	cache, err := ScaledPath(path, opts)
+	if err != nil {
+		return nil, err
+	}
+	if sprite, err := decodeGIF(cache); err == nil {
+		return sprite, nil
+	}
+
+	sprite, err := decodeGIF(path)
+	if err != nil {
+		return nil, err
+	}
+	anim := scaleGIF(sprite, opts)
+	if err := writeGIF(cache, anim); err != nil {
+		fmt.Printf("Error caching scaled GIF %s: %v\n", cache, err)
+	} else {
+		pruneScaled(path, cache)
+	}
+	return objects.SpriteFromGIF(anim), nil
+}
+
+// Preprocess scales every GIF of dir ahead of playing them.
+func Preprocess(dir string, opts ScaleOptions) error {
+	entries, err := Scan(dir, 1)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if _, err := LoadScaled(entry.Path, opts); err != nil {
+			return fmt.Errorf("%s: %w", entry.Path, err)
+		}
+	}
+	return nil
+}
+
+// scaleGIF fits every frame of a sprite to the matrix and quantizes it back
+// to a palette.
+func scaleGIF(sprite *objects.Sprite, opts ScaleOptions) *gif.GIF {
+	anim := &gif.GIF{
+		LoopCount: sprite.LoopCount,
+		Config:    image.Config{Width: opts.Width, Height: opts.Height},
+	}
+	for idx, frame := range sprite.Frames {
+		if frame.Bounds().Size() != image.Pt(opts.Width, opts.Height) {
+			frame = fit(frame, opts.Width, opts.Height, opts.scale)
+		}
+		anim.Image = append(anim.Image, quantize(frame, opts.Colors, opts.Dither))
+		anim.Delay = append(anim.Delay, int((sprite.Delays[idx]+5*time.Millisecond)/(10*time.Millisecond)))
+	}
+	return anim
+}
+
+// boxResize averages the source pixels covered by every destination pixel.
+func boxResize(img image.Image, width, height int) image.Image {
+	bounds := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*bounds.Dy()/height
+		y1 := bounds.Min.Y + ((y+1)*bounds.Dy()+height-1)/height
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/width
+			x1 := bounds.Min.X + ((x+1)*bounds.Dx()+width-1)/width
+			var r, g, b, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r, g, b, a, n = r+cr, g+cg, b+cb, a+ca, n+1
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{uint8(r / n >> 8), uint8(g / n >> 8), uint8(b / n >> 8), uint8(a / n >> 8)})
+		}
+	}
+	return dst
+}
+
+var scaledName = regexp.MustCompile(`^(.*?)(-[0-9a-f]{12})?\.gif$`)
+
+// pruneScaled removes the copies of a GIF other than keep from its cache
+// folder, including unhashed ones left by older versions.
+func pruneScaled(path, keep string) {
+	stem := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	names, err := os.ReadDir(filepath.Dir(keep))
+	if err != nil {
+		return
+	}
+	for _, name := range names {
+		match := scaledName.FindStringSubmatch(name.Name())
+		if match == nil || match[1] != stem || name.Name() == filepath.Base(keep) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(filepath.Dir(keep), name.Name())); err != nil {
+			fmt.Printf("Error removing outdated scaled GIF %s: %v\n", name.Name(), err)
+		}
+	}
+}
+
+type hashEntry struct {
+	size    int64
+	modTime time.Time
+	sum     []byte
+}
+
+var (
+	hashesMu sync.Mutex
+	hashes   = make(map[string]hashEntry)
+)
+
+// contentHash hashes a file, remembering the result until its size or
+// modification time change.
+func contentHash(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	hashesMu.Lock()
+	entry, ok := hashes[path]
+	hashesMu.Unlock()
+	if ok && entry.size == info.Size() && entry.modTime.Equal(info.ModTime()) {
+		return entry.sum, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	entry = hashEntry{size: info.Size(), modTime: info.ModTime(), sum: h.Sum(nil)}
+	hashesMu.Lock()
+	hashes[path] = entry
+	hashesMu.Unlock()
+	return entry.sum, nil
+}
+
+func decodeGIF(path string) (*objects.Sprite, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	g, err := gif.DecodeAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return objects.SpriteFromGIF(g), nil
+}
+
+// writeGIF writes through a temporary file so a crash never leaves a
+// truncated copy in the cache.
+func writeGIF(path string, anim *gif.GIF) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-*.gif")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := gif.EncodeAll(f, anim); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
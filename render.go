@@ -0,0 +1,56 @@
+package main
+
+import (
+	"einclient/engine"
+	"einclient/loop"
+	"einclient/render"
+	"flag"
+	"fmt"
+	"time"
+)
+
+// runRender implements the "render" subcommand, which renders a scene to a
+// file without any matrix attached:
+//
+//	einclient render --scene scenes/ein.yml --duration 5s --fps 20 --out out.gif
+func runRender(args []string) error {
+	flags := flag.NewFlagSet("render", flag.ExitOnError)
+	scene := flags.String("scene", "./scenes/ein.yml", "path to the scene file")
+	duration := flags.Duration("duration", 5*time.Second, "length of the rendered clip")
+	fps := flags.Int("fps", 20, "frames per second")
+	out := flags.String("out", "out.gif", "output file, a pattern like frames/%04d.png writes a PNG sequence")
+	format := flags.String("format", "", "output format: gif, apng or png (sequence), guessed from -out when empty")
+	size := flags.String("size", "frame", "output resolution: frame or matrix")
+	seed := flags.Int64("seed", 0, "random seed used when the scene doesn't set one")
+	flags.Parse(args)
+
+	s, err := engine.ReadScene(*scene)
+	if err != nil {
+		return err
+	}
+	if s.Seed == nil {
+		s.Seed = seed
+	}
+
+	opts := render.Options{Duration: *duration, FPS: *fps}
+	switch *size {
+	case "frame":
+	case "matrix":
+		opts.Width, opts.Height = loop.Width, loop.Height
+	default:
+		return fmt.Errorf("unknown size: %s", *size)
+	}
+
+	frames, err := render.Frames(s, opts)
+	if err != nil {
+		return err
+	}
+	if *format == "" {
+		*format = render.FormatFor(*out)
+	}
+	if err := render.WriteFile(*out, *format, frames, opts.Delay()); err != nil {
+		return err
+	}
+	fmt.Printf("Rendered %d frames to %s\n", len(frames), *out)
+	return nil
+}
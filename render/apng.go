@@ -0,0 +1,128 @@
+package render
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"io"
+	"time"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// WriteAPNG encodes frames as a looping animated PNG. Every frame is written
+// as full 8-bit RGBA so the frames always agree with the IHDR chunk, which the
+// standard library encoder doesn't guarantee as it picks a color type per
+// image.
+func WriteAPNG(w io.Writer, frames []image.Image, delay time.Duration) error {
+	if len(frames) == 0 {
+		return fmt.Errorf("no frames to encode")
+	}
+	bounds := frames[0].Bounds()
+	width, height := uint32(bounds.Dx()), uint32(bounds.Dy())
+
+	if _, err := w.Write(pngSignature); err != nil {
+		return err
+	}
+
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], width)
+	binary.BigEndian.PutUint32(ihdr[4:], height)
+	ihdr[8] = 8 // bit depth
+	ihdr[9] = 6 // color type RGBA
+	if err := writeChunk(w, "IHDR", ihdr); err != nil {
+		return err
+	}
+
+	actl := make([]byte, 8)
+	binary.BigEndian.PutUint32(actl[0:], uint32(len(frames)))
+	binary.BigEndian.PutUint32(actl[4:], 0) // loop forever
+	if err := writeChunk(w, "acTL", actl); err != nil {
+		return err
+	}
+
+	// The delay is stored as a fraction, milliseconds are precise enough.
+	delayNum := uint16(delay / time.Millisecond)
+	var sequence uint32
+	for i, frame := range frames {
+		if frame.Bounds().Dx() != int(width) || frame.Bounds().Dy() != int(height) {
+			return fmt.Errorf("frame %d is %v, expected %dx%d", i, frame.Bounds().Size(), width, height)
+		}
+
+		fctl := make([]byte, 26)
+		binary.BigEndian.PutUint32(fctl[0:], sequence)
+		binary.BigEndian.PutUint32(fctl[4:], width)
+		binary.BigEndian.PutUint32(fctl[8:], height)
+		binary.BigEndian.PutUint16(fctl[20:], delayNum)
+		binary.BigEndian.PutUint16(fctl[22:], 1000)
+		sequence++
+		if err := writeChunk(w, "fcTL", fctl); err != nil {
+			return err
+		}
+
+		data, err := compressFrame(frame)
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			err = writeChunk(w, "IDAT", data)
+		} else {
+			seq := make([]byte, 4)
+			binary.BigEndian.PutUint32(seq, sequence)
+			sequence++
+			err = writeChunk(w, "fdAT", append(seq, data...))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return writeChunk(w, "IEND", nil)
+}
+
+// compressFrame returns the zlib stream of a frame's scanlines, each prefixed
+// with the "none" filter.
+func compressFrame(frame image.Image) ([]byte, error) {
+	bounds := frame.Bounds()
+	nrgba := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(nrgba, nrgba.Bounds(), frame, bounds.Min, draw.Src)
+
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	rowLen := nrgba.Bounds().Dx() * 4
+	for y := 0; y < nrgba.Bounds().Dy(); y++ {
+		if _, err := zw.Write([]byte{0}); err != nil {
+			return nil, err
+		}
+		if _, err := zw.Write(nrgba.Pix[y*nrgba.Stride : y*nrgba.Stride+rowLen]); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeChunk(w io.Writer, name string, data []byte) error {
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header[0:], uint32(len(data)))
+	copy(header[4:], name)
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+	footer := make([]byte, 4)
+	binary.BigEndian.PutUint32(footer, crc.Sum32())
+
+	for _, b := range [][]byte{header, data, footer} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package render
+
+import (
+	"einclient/engine"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fogleman/gg"
+	"github.com/nfnt/resize"
+)
+
+const (
+	FormatGIF         = "gif"
+	FormatAPNG        = "apng"
+	FormatPNGSequence = "png"
+)
+
+// Options describe a headless render of a scene.
+type Options struct {
+	// Duration is the length of the rendered clip.
+	Duration time.Duration
+	// FPS is the number of frames per second, the scene clock advances by
+	// exactly 1/FPS between frames.
+	FPS int
+	// Width and Height scale every frame, zero keeps the scene frame size.
+	Width  uint
+	Height uint
+	// Start is the scene clock time of the first frame.
+	Start time.Time
+}
+
+// Delay is the time between two frames.
+func (o Options) Delay() time.Duration {
+	return time.Second / time.Duration(o.FPS)
+}
+
+// Frame draws scene at clock.Now() onto ctx and returns a copy scaled to
+// width x height, zero keeps the context size. This is what the loop shows on
+// the matrix, so headless renders match the panel.
+func Frame(ctx *gg.Context, scene *engine.Scene, clock engine.Clock, width, height uint) image.Image {
+	scene.Render(ctx, clock)
+	if width == 0 && height == 0 {
+		src := ctx.Image()
+		dst := image.NewRGBA(src.Bounds())
+		draw.Draw(dst, dst.Bounds(), src, src.Bounds().Min, draw.Src)
+		return dst
+	}
+	return resize.Resize(width, height, ctx.Image(), resize.Lanczos2)
+}
+
+// Frames renders the scene on a fixed timestep clock.
+func Frames(scene *engine.Scene, opts Options) ([]image.Image, error) {
+	if opts.FPS <= 0 {
+		return nil, fmt.Errorf("fps must be positive, got %d", opts.FPS)
+	}
+	if scene.Frame.Width <= 0 || scene.Frame.Height <= 0 {
+		return nil, fmt.Errorf("scene frame is %dx%d", scene.Frame.Width, scene.Frame.Height)
+	}
+
+	ctx := gg.NewContext(scene.Frame.Width, scene.Frame.Height)
+	clock := engine.NewFixedStepClock(opts.Start, opts.Delay())
+	count := int(opts.Duration / opts.Delay())
+	if count < 1 {
+		count = 1
+	}
+	frames := make([]image.Image, count)
+	for i := range frames {
+		frames[i] = Frame(ctx, scene, clock, opts.Width, opts.Height)
+	}
+	return frames, nil
+}
+
+// FormatFor guesses the output format from a path: a pattern with a "%" verb
+// is a PNG sequence, ".png" and ".apng" are animated PNGs and anything else is
+// a GIF.
+func FormatFor(path string) string {
+	switch {
+	case strings.Contains(path, "%"):
+		return FormatPNGSequence
+	case strings.EqualFold(filepath.Ext(path), ".png"), strings.EqualFold(filepath.Ext(path), ".apng"):
+		return FormatAPNG
+	}
+	return FormatGIF
+}
+
+// WriteFile writes frames to path in the given format, see FormatFor.
+func WriteFile(path, format string, frames []image.Image, delay time.Duration) error {
+	if format == FormatPNGSequence {
+		return WritePNGSequence(path, frames)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	switch format {
+	case FormatGIF:
+		err = WriteGIF(f, frames, delay)
+	case FormatAPNG:
+		err = WriteAPNG(f, frames, delay)
+	default:
+		err = fmt.Errorf("unknown format: %s", format)
+	}
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// WritePNGSequence writes every frame to its own file, pattern is formatted
+// with the frame index, e.g. "out/frame-%04d.png".
+func WritePNGSequence(pattern string, frames []image.Image) error {
+	for i, frame := range frames {
+		path := fmt.Sprintf(pattern, i)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		if err := png.Encode(f, frame); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteGIF encodes frames as a looping GIF. GIF delays are in hundredths of a
+// second so the delay is rounded to the closest one.
+func WriteGIF(w io.Writer, frames []image.Image, delay time.Duration) error {
+	anim := &gif.GIF{}
+	centiseconds := int((delay + 5*time.Millisecond) / (10 * time.Millisecond))
+	for _, frame := range frames {
+		anim.Image = append(anim.Image, paletted(frame))
+		anim.Delay = append(anim.Delay, centiseconds)
+	}
+	return gif.EncodeAll(w, anim)
+}
+
+// paletted converts a frame to a paletted image, faces usually have few enough
+// colors to keep them exact, otherwise it falls back to a dithered Plan9
+// palette.
+func paletted(frame image.Image) *image.Paletted {
+	bounds := frame.Bounds()
+	p, ok := exactPalette(frame)
+	if !ok {
+		dst := image.NewPaletted(bounds, palette.Plan9)
+		draw.FloydSteinberg.Draw(dst, bounds, frame, bounds.Min)
+		return dst
+	}
+	dst := image.NewPaletted(bounds, p)
+	draw.Draw(dst, bounds, frame, bounds.Min, draw.Src)
+	return dst
+}
+
+// exactPalette collects the colors of a frame, it fails when there are more
+// than a GIF palette can hold.
+func exactPalette(frame image.Image) (color.Palette, bool) {
+	bounds := frame.Bounds()
+	seen := make(map[color.RGBA]bool)
+	var p color.Palette
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.RGBAModel.Convert(frame.At(x, y)).(color.RGBA)
+			if seen[c] {
+				continue
+			}
+			if len(p) == 256 {
+				return nil, false
+			}
+			seen[c] = true
+			p = append(p, c)
+		}
+	}
+	return p, true
+}
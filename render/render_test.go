@@ -0,0 +1,94 @@
+package render
+
+import (
+	"bytes"
+	"einclient/engine"
+	"encoding/binary"
+	"image/gif"
+	"image/png"
+	"testing"
+	"time"
+)
+
+func loadScene(t *testing.T) *engine.Scene {
+	scene, err := engine.ReadScene("../scenes/ein.yml")
+	if err != nil {
+		t.Fatalf("Failed to load scene: %v", err)
+	}
+	seed := int64(1)
+	scene.Seed = &seed
+	return scene
+}
+
+func TestFrames(t *testing.T) {
+	frames, err := Frames(loadScene(t), Options{Duration: time.Second, FPS: 10, Width: 64, Height: 64})
+	if err != nil {
+		t.Fatalf("Failed to render frames: %v", err)
+	}
+	if len(frames) != 10 {
+		t.Errorf("Expected 10 frames, got %d", len(frames))
+	}
+	if size := frames[0].Bounds().Size(); size.X != 64 || size.Y != 64 {
+		t.Errorf("Expected 64x64 frames, got %v", size)
+	}
+}
+
+func TestWriteGIF(t *testing.T) {
+	frames, err := Frames(loadScene(t), Options{Duration: time.Second, FPS: 20})
+	if err != nil {
+		t.Fatalf("Failed to render frames: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := WriteGIF(&buf, frames, 50*time.Millisecond); err != nil {
+		t.Fatalf("Failed to write GIF: %v", err)
+	}
+	decoded, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatalf("Failed to decode GIF: %v", err)
+	}
+	if len(decoded.Image) != 20 || decoded.Delay[0] != 5 {
+		t.Errorf("Expected 20 frames of 5cs, got %d frames of %dcs", len(decoded.Image), decoded.Delay[0])
+	}
+}
+
+func TestWriteAPNG(t *testing.T) {
+	frames, err := Frames(loadScene(t), Options{Duration: time.Second, FPS: 4, Width: 64, Height: 64})
+	if err != nil {
+		t.Fatalf("Failed to render frames: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := WriteAPNG(&buf, frames, 250*time.Millisecond); err != nil {
+		t.Fatalf("Failed to write APNG: %v", err)
+	}
+
+	// Decoders without APNG support must still see the first frame.
+	if _, err := png.Decode(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("Failed to decode APNG as PNG: %v", err)
+	}
+
+	chunks := make(map[string]int)
+	data := buf.Bytes()[len(pngSignature):]
+	for len(data) >= 12 {
+		length := binary.BigEndian.Uint32(data)
+		chunks[string(data[4:8])]++
+		data = data[12+length:]
+	}
+	if chunks["acTL"] != 1 || chunks["fcTL"] != 4 || chunks["IDAT"] != 1 || chunks["fdAT"] != 3 {
+		t.Errorf("Unexpected chunks %v", chunks)
+	}
+}
+
+func TestFormatFor(t *testing.T) {
+	cases := map[string]string{
+		"out.gif":           FormatGIF,
+		"out.png":           FormatAPNG,
+		"out.apng":          FormatAPNG,
+		"frames/%04d.png":   FormatPNGSequence,
+		"without-extension": FormatGIF,
+	}
+	for path, expected := range cases {
+		if format := FormatFor(path); format != expected {
+			t.Errorf("FormatFor(%q) = %s, expected %s", path, format, expected)
+		}
+	}
+}
@@ -74,4 +74,11 @@ type Matrix interface {
 	Apply([]color.Color) error
 	Render() error
 	Close() error
+	// SetBrightness sets the brightness in percent, from 0 to 100, from the
+	// next Render.
+	SetBrightness(percent int)
+	Brightness() int
+	// SetPower blanks the matrix when off, frames set meanwhile are dropped.
+	SetPower(on bool)
+	Power() bool
 }
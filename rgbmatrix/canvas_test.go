@@ -1,6 +1,7 @@
 package rgbmatrix
 
 import (
+	"einclient/rgbmatrix/internal/panel"
 	"image/color"
 	"testing"
 
@@ -90,8 +91,10 @@ func (s *CanvasSuite) TestClose(c *C) {
 }
 
 type MatrixMock struct {
-	called map[string]interface{}
-	colors []color.Color
+	called     map[string]interface{}
+	colors     []color.Color
+	brightness int
+	off        bool
 }
 
 func NewMatrixMock() *MatrixMock {
@@ -137,3 +140,19 @@ func (m *MatrixMock) Close() error {
 	m.called["Close"] = true
 	return nil
 }
+
+func (m *MatrixMock) SetBrightness(percent int) {
+	m.brightness = panel.ClampBrightness(percent)
+}
+
+func (m *MatrixMock) Brightness() int {
+	return m.brightness
+}
+
+func (m *MatrixMock) SetPower(on bool) {
+	m.off = !on
+}
+
+func (m *MatrixMock) Power() bool {
+	return !m.off
+}
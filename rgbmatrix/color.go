@@ -0,0 +1,180 @@
+package rgbmatrix
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// NeutralTemperature is the color temperature in kelvin that leaves colors
+// as they are.
+const NeutralTemperature = 6500
+
+// ColorConfig calibrates the colors sent to the panel, the zero value leaves
+// them as they are.
+type ColorConfig struct {
+	// Gamma per red, green and blue channel, applied to colors in the 0..1
+	// range. 0 counts as 1, linear.
+	Gamma [3]float64
+	// WhitePoint are the gains per channel, lowering the brighter channels of
+	// a panel batch until white looks white. 0 counts as 1.
+	WhitePoint [3]float64
+	// Temperature warms (below 6500) or cools (above) the white in kelvin.
+	// 0 counts as NeutralTemperature.
+	Temperature int
+}
+
+// ColorPipeline maps the 16-bit channels of colors to the 8-bit channels of
+// the panel through a lookup table per channel.
+type ColorPipeline struct {
+	lut [3][]uint8
+}
+
+// NewColorPipeline builds the lookup tables of config.
+func NewColorPipeline(config ColorConfig) *ColorPipeline {
+	p := &ColorPipeline{}
+	temperature := temperatureGains(config.Temperature)
+	for ch := range p.lut {
+		gamma, gain := config.Gamma[ch], config.WhitePoint[ch]
+		if gamma <= 0 {
+			gamma = 1
+		}
+		if gain <= 0 {
+			gain = 1
+		}
+		gain *= temperature[ch]
+		lut := make([]uint8, 1<<16)
+		for v := range lut {
+			out := math.Pow(float64(v)/0xffff, gamma) * gain
+			lut[v] = uint8(math.Round(math.Min(out, 1) * 255))
+		}
+		p.lut[ch] = lut
+	}
+	return p
+}
+
+// Convert returns c as the panel should show it.
+func (p *ColorPipeline) Convert(c color.Color) color.RGBA {
+	if c == nil {
+		return color.RGBA{A: 255}
+	}
+	r, g, b, _ := c.RGBA()
+	return color.RGBA{p.lut[0][r], p.lut[1][g], p.lut[2][b], 255}
+}
+
+// temperatureGains returns the channel gains of the white of a black body at
+// kelvin, relative to NeutralTemperature. It follows Tanner Helland's fit of
+// the black body colors.
+func temperatureGains(kelvin int) [3]float64 {
+	if kelvin <= 0 || kelvin == NeutralTemperature {
+		return [3]float64{1, 1, 1}
+	}
+	white, neutral := blackBody(float64(kelvin)), blackBody(NeutralTemperature)
+	var gains [3]float64
+	for ch := range gains {
+		gains[ch] = white[ch] / neutral[ch]
+	}
+	return gains
+}
+
+func blackBody(kelvin float64) [3]float64 {
+	t := math.Max(10, math.Min(kelvin, 40000)) / 100
+	var r, g, b float64
+	if t <= 66 {
+		r = 255
+		g = 99.4708025861*math.Log(t) - 161.1195681661
+	} else {
+		r = 329.698727446 * math.Pow(t-60, -0.1332047592)
+		g = 288.1221695283 * math.Pow(t-60, -0.0755148492)
+	}
+	switch {
+	case t >= 66:
+		b = 255
+	case t <= 19:
+		b = 0
+	default:
+		b = 138.5177312231*math.Log(t-10) - 305.0447927307
+	}
+	clamp := func(v float64) float64 { return math.Max(0, math.Min(v, 255)) / 255 }
+	return [3]float64{clamp(r), clamp(g), clamp(b)}
+}
+
+// calibratedMatrix is a Matrix whose colors go through a ColorPipeline first,
+// so the real matrix and the emulator show the same colors.
+type calibratedMatrix struct {
+	Matrix
+	pipeline *ColorPipeline
+}
+
+func (m *calibratedMatrix) Set(position int, c color.Color) {
+	m.Matrix.Set(position, m.pipeline.Convert(c))
+}
+
+func (m *calibratedMatrix) Apply(leds []color.Color) error {
+	for position, l := range leds {
+		m.Set(position, l)
+	}
+	return m.Render()
+}
+
+// CalibrationPattern draws a test pattern to tune ColorConfig with: gray,
+// red, green and blue ramps in 16 steps, then white, the sRGB gray of half
+// its light and a black and white checkerboard. The checkerboard gives half
+// the light of white too, so it matches the gray once the gamma is right.
+func CalibrationPattern(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	ramps := []color.RGBA{{255, 255, 255, 255}, {255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255}}
+	bands := len(ramps) + 1
+	for y := 0; y < height; y++ {
+		band := y * bands / height
+		for x := 0; x < width; x++ {
+			if band < len(ramps) {
+				step := uint32(x * 16 / width)
+				ramp := ramps[band]
+				scale := func(v uint8) uint8 { return uint8(uint32(v) * step / 15) }
+				img.SetRGBA(x, y, color.RGBA{scale(ramp.R), scale(ramp.G), scale(ramp.B), 255})
+				continue
+			}
+			var c color.RGBA
+			switch x * 3 / width {
+			case 0:
+				c = color.RGBA{255, 255, 255, 255}
+			case 1:
+				c = color.RGBA{188, 188, 188, 255}
+			default:
+				if (x+y)%2 == 0 {
+					c = color.RGBA{255, 255, 255, 255}
+				} else {
+					c = color.RGBA{0, 0, 0, 255}
+				}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// parseChannels parses one value for all channels or one per channel,
+// "2.2" or "2.2,2.0,2.4".
+func parseChannels(s string) ([3]float64, error) {
+	var values [3]float64
+	fields := strings.Split(s, ",")
+	if len(fields) != 1 && len(fields) != 3 {
+		return values, fmt.Errorf("expected one value or three, got %q", s)
+	}
+	for i := range values {
+		field := fields[0]
+		if len(fields) == 3 {
+			field = fields[i]
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil || v <= 0 {
+			return values, fmt.Errorf("invalid value %q, expected a positive number", field)
+		}
+		values[i] = v
+	}
+	return values, nil
+}
@@ -0,0 +1,78 @@
+package rgbmatrix
+
+import (
+	"image/color"
+
+	. "gopkg.in/check.v1"
+)
+
+type ColorSuite struct{}
+
+var _ = Suite(&ColorSuite{})
+
+func (s *ColorSuite) TestIdentity(c *C) {
+	p := NewColorPipeline(ColorConfig{})
+
+	c.Assert(p.Convert(color.RGBA{12, 128, 255, 255}), Equals, color.RGBA{12, 128, 255, 255})
+	// 16-bit channels are rounded, not truncated.
+	c.Assert(p.Convert(color.RGBA64{0x01ff, 0, 0, 0xffff}).R, Equals, uint8(2))
+	c.Assert(p.Convert(nil), Equals, color.RGBA{A: 255})
+}
+
+func (s *ColorSuite) TestGammaAndWhitePoint(c *C) {
+	p := NewColorPipeline(ColorConfig{
+		Gamma:      [3]float64{2.2, 1, 1},
+		WhitePoint: [3]float64{1, 0.5, 1},
+	})
+
+	out := p.Convert(color.RGBA{188, 255, 255, 255})
+	c.Assert(out.R, Equals, uint8(130))
+	c.Assert(out.G, Equals, uint8(128))
+	c.Assert(out.B, Equals, uint8(255))
+}
+
+func (s *ColorSuite) TestTemperature(c *C) {
+	neutral := NewColorPipeline(ColorConfig{Temperature: NeutralTemperature})
+	c.Assert(neutral.Convert(color.White), Equals, color.RGBA{255, 255, 255, 255})
+
+	warm := NewColorPipeline(ColorConfig{Temperature: 3000}).Convert(color.White)
+	c.Assert(warm.R, Equals, uint8(255))
+	c.Assert(warm.G < warm.R && warm.B < warm.G, Equals, true)
+
+	cool := NewColorPipeline(ColorConfig{Temperature: 9000}).Convert(color.White)
+	c.Assert(cool.R < cool.B, Equals, true)
+}
+
+func (s *ColorSuite) TestCalibratedMatrix(c *C) {
+	m := NewMatrixMock()
+	calibrated := &calibratedMatrix{Matrix: m, pipeline: NewColorPipeline(ColorConfig{WhitePoint: [3]float64{1, 1, 0.5}})}
+	calibrated.Apply([]color.Color{color.White})
+
+	c.Assert(m.colors[0], Equals, color.RGBA{255, 255, 128, 255})
+	c.Assert(m.called["Render"], Equals, true)
+}
+
+func (s *ColorSuite) TestCalibrationPattern(c *C) {
+	img := CalibrationPattern(64, 64)
+
+	c.Assert(img.RGBAAt(0, 0), Equals, color.RGBA{0, 0, 0, 255})
+	c.Assert(img.RGBAAt(63, 0), Equals, color.RGBA{255, 255, 255, 255})
+	c.Assert(img.RGBAAt(63, 16), Equals, color.RGBA{255, 0, 0, 255})
+	c.Assert(img.RGBAAt(32, 60), Equals, color.RGBA{188, 188, 188, 255})
+	c.Assert(img.RGBAAt(60, 60) != img.RGBAAt(61, 60), Equals, true)
+}
+
+func (s *ColorSuite) TestParseChannels(c *C) {
+	values, err := parseChannels("2.2")
+	c.Assert(err, IsNil)
+	c.Assert(values, Equals, [3]float64{2.2, 2.2, 2.2})
+
+	values, err = parseChannels("1, 0.85,0.9")
+	c.Assert(err, IsNil)
+	c.Assert(values, Equals, [3]float64{1, 0.85, 0.9})
+
+	for _, bad := range []string{"", "1,2", "0", "-1,1,1", "warm"} {
+		_, err = parseChannels(bad)
+		c.Assert(err, NotNil, Commentf("%q", bad))
+	}
+}
@@ -1,11 +1,13 @@
 package emulator
 
 import (
+	"einclient/rgbmatrix/internal/panel"
 	"fmt"
 	"image"
 	"image/color"
 	"os"
 	"sync"
+	"sync/atomic"
 
 	"golang.org/x/exp/shiny/driver"
 	"golang.org/x/exp/shiny/screen"
@@ -31,6 +33,9 @@ type Emulator struct {
 	wg   sync.WaitGroup
 
 	isReady bool
+
+	brightness atomic.Int32
+	off        atomic.Bool
 }
 
 func NewEmulator(w, h, pixelPitch int, autoInit bool) *Emulator {
@@ -42,6 +47,7 @@ func NewEmulator(w, h, pixelPitch int, autoInit bool) *Emulator {
 		Margin:                  10,
 	}
 	e.updatePixelPitchForGutter(pixelPitch / e.PixelPitchToGutterRatio)
+	e.brightness.Store(100)
 
 	if autoInit {
 		e.Init()
@@ -174,7 +180,7 @@ func (e *Emulator) Apply(leds []color.Color) error {
 	var c color.Color
 	for col := 0; col < e.Width; col++ {
 		for row := 0; row < e.Height; row++ {
-			c = e.At(col + (row * e.Width))
+			c = e.dim(e.At(col + (row * e.Width)))
 			e.w.Fill(e.ledRect(col, row), c, screen.Over)
 		}
 	}
@@ -199,6 +205,42 @@ func (e *Emulator) Set(position int, c color.Color) {
 	e.leds[position] = color.RGBAModel.Convert(c)
 }
 
+// SetBrightness dims the LEDs like the panel does, percent is from 1 to 100.
+func (e *Emulator) SetBrightness(percent int) {
+	e.brightness.Store(int32(panel.ClampBrightness(percent)))
+}
+
+func (e *Emulator) Brightness() int {
+	return int(e.brightness.Load())
+}
+
+// SetPower turns the LEDs off, they show black until it's on again.
+func (e *Emulator) SetPower(on bool) {
+	e.off.Store(!on)
+}
+
+func (e *Emulator) Power() bool {
+	return !e.off.Load()
+}
+
+// dim applies the brightness and power to an LED color.
+func (e *Emulator) dim(c color.Color) color.Color {
+	percent := uint32(e.brightness.Load())
+	if e.off.Load() {
+		percent = 0
+	}
+	if percent == 100 {
+		return c
+	}
+	r, g, b, a := c.RGBA()
+	return color.RGBA64{
+		R: uint16(r * percent / 100),
+		G: uint16(g * percent / 100),
+		B: uint16(b * percent / 100),
+		A: uint16(a),
+	}
+}
+
 func (e *Emulator) Close() error {
 	return nil
 }
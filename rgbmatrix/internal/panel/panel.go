@@ -0,0 +1,14 @@
+// Package panel holds what the panel and its emulator share.
+package panel
+
+// ClampBrightness keeps percent in the 1..100 range of the matrices, the
+// library raises 0 to 1 so the panel only goes dark through SetPower.
+func ClampBrightness(percent int) int {
+	if percent < 1 {
+		return 1
+	}
+	if percent > 100 {
+		return 100
+	}
+	return percent
+}
@@ -0,0 +1,11 @@
+package panel
+
+import "testing"
+
+func TestClampBrightness(t *testing.T) {
+	for percent, want := range map[int]int{-5: 1, 0: 1, 1: 1, 40: 40, 100: 100, 250: 100} {
+		if got := ClampBrightness(percent); got != want {
+			t.Errorf("Expected %d for %d, got %d", want, percent, got)
+		}
+	}
+}
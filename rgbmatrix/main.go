@@ -42,9 +42,11 @@ import (
 	"fmt"
 	"image/color"
 	"os"
+	"sync/atomic"
 	"unsafe"
 
 	"einclient/rgbmatrix/emulator"
+	"einclient/rgbmatrix/internal/panel"
 )
 
 var (
@@ -52,31 +54,55 @@ var (
 	cols                     = flag.Int("led-cols", 64, "number of columns supported")
 	parallel                 = flag.Int("led-parallel", 1, "number of daisy-chained panels")
 	chain                    = flag.Int("led-chain", 1, "number of displays daisy-chained")
-	brightness               = flag.Int("brightness", 100, "brightness (0-100)")
+	brightness               = flag.Int("brightness", 100, "brightness (1-100)")
 	hardware_mapping         = flag.String("led-gpio-mapping", "regular", "Name of GPIO mapping used.")
 	show_refresh             = flag.Bool("led-show-refresh", false, "Show refresh rate.")
 	inverse_colors           = flag.Bool("led-inverse", false, "Switch if your matrix has inverse colors on.")
 	disable_hardware_pulsing = flag.Bool("led-no-hardware-pulse", false, "Don't use hardware pin-pulse generation.")
+	temperature              = flag.Int("led-temperature", NeutralTemperature, "color temperature of white in kelvin")
+	gamma                    [3]float64
+	white_point              [3]float64
 )
 
 func init() {
-	flag.Parse()
+	flag.Func("led-gamma", "gamma of all channels or of red, green and blue, e.g. 2.2 or 2.2,2.0,2.4", func(s string) (err error) {
+		gamma, err = parseChannels(s)
+		return err
+	})
+	flag.Func("led-white-point", "gains of all channels or of red, green and blue, e.g. 1,0.85,0.9", func(s string) (err error) {
+		white_point, err = parseChannels(s)
+		return err
+	})
 }
 
 // DefaultConfig default WS281x configuration
 var DefaultConfig = HardwareConfig{
-	Rows:                   *rows,
-	Cols:                   *cols,
-	ChainLength:            *chain,
-	Parallel:               *parallel,
-	PWMBits:                11,
-	PWMLSBNanoseconds:      130,
-	Brightness:             *brightness,
-	ScanMode:               Progressive,
-	DisableHardwarePulsing: *disable_hardware_pulsing,
-	InverseColors:          *inverse_colors,
-	ShowRefreshRate:        *show_refresh,
-	HardwareMapping:        *hardware_mapping,
+	Rows:              64,
+	Cols:              64,
+	ChainLength:       1,
+	Parallel:          1,
+	PWMBits:           11,
+	PWMLSBNanoseconds: 130,
+	Brightness:        100,
+	ScanMode:          Progressive,
+	HardwareMapping:   "regular",
+}
+
+// FlagConfig returns DefaultConfig with the values of the -led-* and
+// -brightness flags, call it after flag.Parse.
+func FlagConfig() HardwareConfig {
+	config := DefaultConfig
+	config.Rows = *rows
+	config.Cols = *cols
+	config.ChainLength = *chain
+	config.Parallel = *parallel
+	config.Brightness = *brightness
+	config.DisableHardwarePulsing = *disable_hardware_pulsing
+	config.InverseColors = *inverse_colors
+	config.ShowRefreshRate = *show_refresh
+	config.HardwareMapping = *hardware_mapping
+	config.Color = ColorConfig{Gamma: gamma, WhitePoint: white_point, Temperature: *temperature}
+	return config
 }
 
 // HardwareConfig rgb-led-matrix configuration
@@ -118,9 +144,13 @@ type HardwareConfig struct {
 
 	// Name of GPIO mapping used
 	HardwareMapping string
+
+	// Color calibrates the colors of both the panel and the emulator.
+	Color ColorConfig
 }
 
-func (c *HardwareConfig) geometry() (width, height int) {
+// Geometry returns the width and the height of the chained panels
+func (c *HardwareConfig) Geometry() (width, height int) {
 	return c.Cols * c.ChainLength, c.Rows * c.Parallel
 }
 
@@ -173,6 +203,9 @@ type RGBLedMatrix struct {
 	matrix *C.struct_RGBLedMatrix
 	buffer *C.struct_LedCanvas
 	leds   []C.uint32_t
+	// blank is swapped in instead of leds while off.
+	blank []C.uint32_t
+	off   atomic.Bool
 }
 
 const MatrixEmulatorENV = "MATRIX_EMULATOR"
@@ -189,23 +222,28 @@ func NewRGBLedMatrix(config *HardwareConfig) (c Matrix, err error) {
 		}
 	}()
 
+	pipeline := NewColorPipeline(config.Color)
 	if isMatrixEmulator() {
-		return buildMatrixEmulator(config), nil
+		return &calibratedMatrix{Matrix: buildMatrixEmulator(config), pipeline: pipeline}, nil
 	}
 
-	w, h := config.geometry()
+	w, h := config.Geometry()
 	m := C.led_matrix_create_from_options(config.toC(), nil, nil)
 	b := C.led_matrix_create_offscreen_canvas(m)
-	c = &RGBLedMatrix{
-		Config: config,
-		width:  w, height: h,
-		matrix: m,
-		buffer: b,
-		leds:   make([]C.uint32_t, w*h),
-	}
 	if m == nil {
 		return nil, fmt.Errorf("unable to allocate memory")
 	}
+	c = &calibratedMatrix{
+		Matrix: &RGBLedMatrix{
+			Config: config,
+			width:  w, height: h,
+			matrix: m,
+			buffer: b,
+			leds:   make([]C.uint32_t, w*h),
+			blank:  make([]C.uint32_t, w*h),
+		},
+		pipeline: pipeline,
+	}
 
 	return c, nil
 }
@@ -219,8 +257,10 @@ func isMatrixEmulator() bool {
 }
 
 func buildMatrixEmulator(config *HardwareConfig) Matrix {
-	w, h := config.geometry()
-	return emulator.NewEmulator(w, h, emulator.DefaultPixelPitch, true)
+	w, h := config.Geometry()
+	e := emulator.NewEmulator(w, h, emulator.DefaultPixelPitch, true)
+	e.SetBrightness(config.Brightness)
+	return e
 }
 
 // Initialize initialize library, must be called once before other functions are
@@ -245,13 +285,17 @@ func (c *RGBLedMatrix) Apply(leds []color.Color) error {
 
 // Render update the display with the data from the LED buffer
 func (c *RGBLedMatrix) Render() error {
-	w, h := c.Config.geometry()
+	w, h := c.Config.Geometry()
+	leds := c.leds
+	if c.off.Load() {
+		leds = c.blank
+	}
 
 	C.led_matrix_swap(
 		c.matrix,
 		c.buffer,
 		C.int(w), C.int(h),
-		(*C.uint32_t)(unsafe.Pointer(&c.leds[0])),
+		(*C.uint32_t)(unsafe.Pointer(&leds[0])),
 	)
 
 	c.leds = make([]C.uint32_t, w*h)
@@ -269,6 +313,27 @@ func (c *RGBLedMatrix) Set(position int, color color.Color) {
 	c.leds[position] = C.uint32_t(colorToUint32(color))
 }
 
+// SetBrightness sets the brightness of the panel in percent, the library
+// applies it to the pixels of the next Render.
+func (c *RGBLedMatrix) SetBrightness(percent int) {
+	C.led_matrix_set_brightness(c.matrix, C.uint8_t(panel.ClampBrightness(percent)))
+}
+
+// Brightness returns the brightness of the panel in percent
+func (c *RGBLedMatrix) Brightness() int {
+	return int(C.led_matrix_get_brightness(c.matrix))
+}
+
+// SetPower turns the panel on or off, it renders black while off
+func (c *RGBLedMatrix) SetPower(on bool) {
+	c.off.Store(!on)
+}
+
+// Power returns whether the panel is on
+func (c *RGBLedMatrix) Power() bool {
+	return !c.off.Load()
+}
+
 // Close finalizes the ws281x interface
 func (c *RGBLedMatrix) Close() error {
 	C.led_matrix_delete(c.matrix)
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"einclient/playlist"
+	"einclient/rgbmatrix"
+	"flag"
+	"fmt"
+)
+
+// runScale implements the "scale" subcommand, which fills the .scaled caches
+// ahead of playing the GIFs so the matrix doesn't wait on them:
+//
+//	einclient scale --gifs ./gifs --filter box --dither ordered --colors 64
+func runScale(args []string) error {
+	config := rgbmatrix.FlagConfig()
+	width, height := config.Geometry()
+
+	flags := flag.NewFlagSet("scale", flag.ExitOnError)
+	dir := flags.String("gifs", "./gifs", "directory containing GIFs to scale")
+	flags.IntVar(&width, "width", width, "width to scale to, the matrix width by default")
+	flags.IntVar(&height, "height", height, "height to scale to, the matrix height by default")
+	filter := flags.String("filter", "lanczos", "scaling filter: nearest, box or lanczos")
+	dither := flags.String("dither", "floyd-steinberg", "dithering: none, ordered or floyd-steinberg")
+	colors := flags.Int("colors", 256, "palette size, at most 256")
+	flags.Parse(args)
+
+	opts := playlist.ScaleOptions{Width: width, Height: height, Colors: *colors}
+	var err error
+	if opts.Filter, err = playlist.ParseFilter(*filter); err != nil {
+		return err
+	}
+	if opts.Dither, err = playlist.ParseDither(*dither); err != nil {
+		return err
+	}
+	if err := playlist.Preprocess(*dir, opts); err != nil {
+		return err
+	}
+	fmt.Printf("Scaled the GIFs in %s to %dx%d\n", *dir, width, height)
+	return nil
+}
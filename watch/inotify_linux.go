@@ -0,0 +1,124 @@
+//go:build linux
+
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// inotifyMask catches in place writes as well as files replaced by a rename
+// or deleted and created again, which is how most editors save.
+const inotifyMask = unix.IN_MODIFY | unix.IN_CLOSE_WRITE | unix.IN_ATTRIB |
+	unix.IN_CREATE | unix.IN_DELETE | unix.IN_MOVED_TO | unix.IN_MOVED_FROM
+
+// inotify watches the directories of the files, files come and go when they
+// are replaced but their directory stays.
+type inotify struct {
+	fd     int
+	file   *os.File
+	notify func()
+
+	mu    sync.Mutex
+	dirs  map[string]int
+	wds   map[int]string
+	files map[string]bool
+}
+
+func newInotify(notify func()) (backend, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	b := &inotify{
+		fd: fd,
+		// A non blocking descriptor makes reads go through the runtime
+		// poller, so closing the file interrupts them.
+		file:   os.NewFile(uintptr(fd), "inotify"),
+		notify: notify,
+		dirs:   make(map[string]int),
+		wds:    make(map[int]string),
+		files:  make(map[string]bool),
+	}
+	go b.read()
+	return b, nil
+}
+
+func (b *inotify) watch(paths []string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	files := make(map[string]bool, len(paths))
+	dirs := make(map[string]bool)
+	for _, path := range paths {
+		files[path] = true
+		dirs[filepath.Dir(path)] = true
+	}
+
+	for dir := range dirs {
+		if _, ok := b.dirs[dir]; ok {
+			continue
+		}
+		wd, err := unix.InotifyAddWatch(b.fd, dir, inotifyMask)
+		if err != nil {
+			return &os.PathError{Op: "watch", Path: dir, Err: err}
+		}
+		b.dirs[dir] = wd
+		b.wds[wd] = dir
+	}
+	for dir, wd := range b.dirs {
+		if dirs[dir] {
+			continue
+		}
+		unix.InotifyRmWatch(b.fd, uint32(wd))
+		delete(b.dirs, dir)
+		delete(b.wds, wd)
+	}
+	b.files = files
+	return nil
+}
+
+func (b *inotify) read() {
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		n, err := b.file.Read(buf)
+		if err != nil {
+			return
+		}
+
+		changed := false
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + unix.SizeofInotifyEvent
+			name := string(trimNull(buf[nameStart : nameStart+int(event.Len)]))
+			offset = nameStart + int(event.Len)
+
+			b.mu.Lock()
+			dir, ok := b.wds[int(event.Wd)]
+			if ok && b.files[filepath.Join(dir, name)] {
+				changed = true
+			}
+			b.mu.Unlock()
+		}
+		if changed {
+			b.notify()
+		}
+	}
+}
+
+func trimNull(name []byte) []byte {
+	for idx, c := range name {
+		if c == 0 {
+			return name[:idx]
+		}
+	}
+	return name
+}
+
+func (b *inotify) close() error {
+	return b.file.Close()
+}
@@ -0,0 +1,215 @@
+// Package watch notices changes to a set of files, with inotify where it is
+// available and by polling otherwise.
+package watch
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultInterval = time.Second
+	DefaultDebounce = 100 * time.Millisecond
+)
+
+// Options configure a Watcher.
+type Options struct {
+	// Poll forces polling even when inotify is available.
+	Poll bool
+	// Interval between polls, DefaultInterval when zero.
+	Interval time.Duration
+	// Debounce is how long the files must stay quiet before a change is
+	// reported, so an editor saving in several writes reloads once.
+	Debounce time.Duration
+}
+
+// Watcher reports changes to the files it watches on Changes. Replacing a
+// file, like editors saving through a temporary file and a rename, counts as
+// a change.
+type Watcher struct {
+	opts    Options
+	backend backend
+	raw     chan struct{}
+	changes chan struct{}
+	done    chan struct{}
+}
+
+// backend notifies raw whenever one of the watched files may have changed.
+type backend interface {
+	watch(paths []string) error
+	close() error
+}
+
+// New watches paths until ctx is done, Changes is closed then.
+func New(ctx context.Context, opts Options, paths ...string) (*Watcher, error) {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	if opts.Debounce <= 0 {
+		opts.Debounce = DefaultDebounce
+	}
+	w := &Watcher{
+		opts:    opts,
+		raw:     make(chan struct{}, 1),
+		changes: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+
+	if !opts.Poll {
+		b, err := newInotify(w.notify)
+		if err != nil {
+			fmt.Printf("Falling back to polling every %v: %v\n", opts.Interval, err)
+		} else {
+			w.backend = b
+		}
+	}
+	if w.backend == nil {
+		w.backend = newPoller(opts.Interval, w.notify)
+	}
+	if err := w.Watch(paths...); err != nil {
+		w.backend.close()
+		return nil, err
+	}
+
+	go w.run(ctx)
+	return w, nil
+}
+
+// Changes receives once per burst of changes.
+func (w *Watcher) Changes() <-chan struct{} {
+	return w.changes
+}
+
+// Done is closed once the watcher stopped.
+func (w *Watcher) Done() <-chan struct{} {
+	return w.done
+}
+
+// Watch replaces the watched files.
+func (w *Watcher) Watch(paths ...string) error {
+	absolute := make([]string, 0, len(paths))
+	for _, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		absolute = append(absolute, abs)
+	}
+	return w.backend.watch(absolute)
+}
+
+func (w *Watcher) notify() {
+	select {
+	case w.raw <- struct{}{}:
+	default:
+	}
+}
+
+// run debounces the raw notifications of the backend.
+func (w *Watcher) run(ctx context.Context) {
+	defer close(w.done)
+	defer close(w.changes)
+	defer w.backend.close()
+
+	timer := time.NewTimer(w.opts.Debounce)
+	timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-w.raw:
+			timer.Reset(w.opts.Debounce)
+		case <-timer.C:
+			select {
+			case w.changes <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// poller stats the watched files at an interval, a file changes when its
+// size or modification time does, or when it appears or disappears.
+type poller struct {
+	notify func()
+	stop   chan struct{}
+
+	mu    sync.Mutex
+	state map[string]fileState
+}
+
+type fileState struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+func statFile(path string) fileState {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{}
+	}
+	return fileState{exists: true, size: info.Size(), modTime: info.ModTime()}
+}
+
+func newPoller(interval time.Duration, notify func()) *poller {
+	p := &poller{
+		notify: notify,
+		stop:   make(chan struct{}),
+		state:  make(map[string]fileState),
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				p.poll()
+			}
+		}
+	}()
+	return p
+}
+
+func (p *poller) watch(paths []string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	state := make(map[string]fileState, len(paths))
+	for _, path := range paths {
+		if previous, ok := p.state[path]; ok {
+			state[path] = previous
+			continue
+		}
+		state[path] = statFile(path)
+	}
+	p.state = state
+	return nil
+}
+
+func (p *poller) poll() {
+	p.mu.Lock()
+	changed := false
+	for path, previous := range p.state {
+		current := statFile(path)
+		if current != previous {
+			p.state[path] = current
+			changed = true
+		}
+	}
+	p.mu.Unlock()
+	if changed {
+		p.notify()
+	}
+}
+
+func (p *poller) close() error {
+	close(p.stop)
+	return nil
+}
@@ -0,0 +1,104 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testDebounce = 20 * time.Millisecond
+
+// expectChange waits for one debounced change and checks no second one
+// follows.
+func expectChange(t *testing.T, w *Watcher, what string) {
+	t.Helper()
+	select {
+	case <-w.Changes():
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Expected a change after %s", what)
+	}
+	select {
+	case <-w.Changes():
+		t.Errorf("Expected a single change after %s", what)
+	case <-time.After(5 * testDebounce):
+	}
+}
+
+func testWatcher(t *testing.T, opts Options) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "scene.yml")
+	other := filepath.Join(dir, "other.yml")
+	if err := os.WriteFile(path, []byte("a"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w, err := New(ctx, opts, path)
+	if err != nil {
+		t.Fatalf("Failed to watch: %v", err)
+	}
+
+	// An editor saving in several writes.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	for _, chunk := range []string{"b", "cd", "efg"} {
+		f.WriteString(chunk)
+		time.Sleep(testDebounce / 4)
+	}
+	f.Close()
+	expectChange(t, w, "several writes")
+
+	// Saving through a temporary file renamed over the original.
+	tmp := filepath.Join(dir, ".scene.yml.tmp")
+	if err := os.WriteFile(tmp, []byte("renamed"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatalf("Failed to rename: %v", err)
+	}
+	expectChange(t, w, "an atomic rename")
+
+	// Files that aren't watched are ignored until they are.
+	if err := os.WriteFile(other, []byte("other"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	select {
+	case <-w.Changes():
+		t.Errorf("Expected changes to unwatched files to be ignored")
+	case <-time.After(5*testDebounce + opts.Interval):
+	}
+	if err := w.Watch(path, other); err != nil {
+		t.Fatalf("Failed to watch: %v", err)
+	}
+	if err := os.WriteFile(other, []byte("included"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	expectChange(t, w, "writing an added file")
+
+	cancel()
+	select {
+	case <-w.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the watcher to stop with its context")
+	}
+	if _, ok := <-w.Changes(); ok {
+		t.Errorf("Expected Changes to be closed")
+	}
+}
+
+func TestInotify(t *testing.T) {
+	b, err := newInotify(func() {})
+	if err != nil {
+		t.Skipf("inotify isn't available: %v", err)
+	}
+	b.close()
+	testWatcher(t, Options{Debounce: testDebounce})
+}
+
+func TestPoll(t *testing.T) {
+	testWatcher(t, Options{Poll: true, Interval: 10 * time.Millisecond, Debounce: testDebounce})
+}
@@ -0,0 +1,187 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// upgrade turns an HTTP request into the server end of a connection, for
+// stand-in servers.
+func upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") || key == "" {
+		http.Error(w, "expected a WebSocket handshake", http.StatusBadRequest)
+		return nil, errors.New("not a WebSocket handshake")
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "can't upgrade this connection", http.StatusInternalServerError)
+		return nil, errors.New("response writer can't be hijacked")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", acceptKey(key))
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &Conn{conn: conn, reader: rw.Reader}, nil
+}
+
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// echoServer sends back every message it gets, after a ping.
+func echoServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrade(w, r)
+		if err != nil {
+			t.Errorf("Failed to upgrade: %v", err)
+			return
+		}
+		defer conn.Close()
+		for {
+			message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.writeFrame(opPing, []byte("hi")); err != nil {
+				return
+			}
+			if err := conn.WriteMessage(message); err != nil {
+				return
+			}
+		}
+	}))
+}
+
+func dialServer(t *testing.T, server *httptest.Server, pingInterval time.Duration) *Conn {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http"), nil, pingInterval)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	return conn
+}
+
+func TestEcho(t *testing.T) {
+	server := echoServer(t)
+	defer server.Close()
+	conn := dialServer(t, server, PingInterval)
+	defer conn.Close()
+
+	for _, message := range []string{`{"state": "thinking"}`, strings.Repeat("x", 70000)} {
+		if err := conn.WriteMessage([]byte(message)); err != nil {
+			t.Fatalf("Failed to write: %v", err)
+		}
+		got, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("Failed to read: %v", err)
+		}
+		if string(got) != message {
+			t.Errorf("Expected the %d byte message back, got %d bytes", len(message), len(got))
+		}
+	}
+}
+
+func TestFragmentsAndClose(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrade(w, r)
+		if err != nil {
+			return
+		}
+		// The first part of a fragmented message doesn't have FIN set.
+		conn.conn.Write([]byte{opText, 3, 'a', 'b', 'c'})
+		conn.conn.Write([]byte{0x80 | opContinuation, 3, 'd', 'e', 'f'})
+		conn.Close()
+	}))
+	defer server.Close()
+	conn := dialServer(t, server, PingInterval)
+
+	message, err := conn.ReadMessage()
+	if err != nil || string(message) != "abcdef" {
+		t.Fatalf("Expected abcdef, got %q, %v", message, err)
+	}
+	if _, err := conn.ReadMessage(); err != io.EOF {
+		t.Errorf("Expected io.EOF once closed, got %v", err)
+	}
+}
+
+func TestDialErrors(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	ctx := context.Background()
+	if _, err := Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http"), nil); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Expected the handshake to fail with 404, got %v", err)
+	}
+	if _, err := Dial(ctx, server.URL, nil); err == nil {
+		t.Errorf("Expected http:// to be refused")
+	}
+}
+
+func TestPings(t *testing.T) {
+	// The server answers pings while it reads but only speaks after a while.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			conn.WriteMessage([]byte("late"))
+		}()
+		for {
+			if _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+	conn := dialServer(t, server, 10*time.Millisecond)
+	defer conn.Close()
+
+	message, err := conn.ReadMessage()
+	if err != nil || string(message) != "late" {
+		t.Errorf("Expected the pongs to keep the connection up, got %q, %v", message, err)
+	}
+}
+
+func TestReadTimeout(t *testing.T) {
+	// The server neither reads nor closes, so pings go unanswered.
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := upgrade(w, r); err != nil {
+			return
+		}
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+	conn := dialServer(t, server, 10*time.Millisecond)
+	defer conn.Close()
+
+	var netErr net.Error
+	if _, err := conn.ReadMessage(); !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("Expected a timeout from a silent server, got %v", err)
+	}
+}